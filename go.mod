@@ -1,3 +1,5 @@
 module github.com/jonathanhecl/subtitle-processor
 
-go 1.15
+go 1.16
+
+require golang.org/x/text v0.13.0
@@ -3,8 +3,15 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jonathanhecl/subtitle-processor/subtitles"
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
 )
 
 // Version information
@@ -17,11 +24,28 @@ func main() {
 	// Display version information
 	fmt.Printf("Subtitle Processor v%d.%d\n", version["major"], version["minor"])
 
+	// Run the command given in the arguments, if any
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "detect":
+			os.Exit(detect(os.Args[2:]))
+		case "sync":
+			os.Exit(sync(os.Args[2:]))
+		default:
+			fmt.Println("Unknown command:", os.Args[1])
+			fmt.Println("Usage: subtitle-processor [detect <file>... | sync <input> <output> <cue>=<time>... | sync <input> <output> <audio.wav>]")
+			os.Exit(2)
+		}
+	}
+
 	// Example 1: Loading and displaying SRT subtitle information
 	fmt.Println("\n=== SRT Subtitle Example ===")
 	srtSubtitle := subtitles.Subtitle{}
 	srtSubtitle.Verbose = true
-	srtSubtitle.LoadFile("./demo.srt")
+	if err := srtSubtitle.LoadFile("./demo.srt"); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
 
 	fmt.Println("Filename:", srtSubtitle.Filename)
 	fmt.Println("Format:", srtSubtitle.Format)
@@ -40,7 +64,10 @@ func main() {
 	fmt.Println("\n=== SSA Subtitle Example ===")
 	ssaSubtitle := subtitles.Subtitle{}
 	ssaSubtitle.Verbose = true
-	ssaSubtitle.LoadFile("./demo.ssa")
+	if err := ssaSubtitle.LoadFile("./demo.ssa"); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
 
 	fmt.Println("Filename:", ssaSubtitle.Filename)
 	fmt.Println("Format:", ssaSubtitle.Format)
@@ -56,3 +83,119 @@ func main() {
 		}
 	*/
 }
+
+// detect prints the formats detected for each file, from the highest to the lowest confidence,
+// with the content probe and the extension match of each one. The first format reading the
+// content is the one used when loading the file.
+// Returns the exit status: 1 if a file can't be read or has no format, 0 otherwise.
+func detect(filenames []string) int {
+	if len(filenames) == 0 {
+		fmt.Println("Usage: subtitle-processor detect <file>...")
+		return 2
+	}
+
+	status := 0
+	for _, filename := range filenames {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Println("Error:", err)
+			status = 1
+			continue
+		}
+
+		fmt.Println("\n" + filename)
+		candidates := subtitles.DetectFormat(data, filename)
+		if len(candidates) == 0 {
+			fmt.Println("  No format recognizes the content")
+			status = 1
+			continue
+		}
+		for i, c := range candidates {
+			fmt.Printf("  %d. %s\n", i+1, c)
+		}
+	}
+	return status
+}
+
+// sync retimes the cues of the input file from the correct start times of some of its cues,
+// given as <cue>=<time> with the index of the cue starting at 1 and the time as hh:mm:ss,mmm
+// or a Go duration (e.g., 1m30.5s), or to the speech of a PCM WAV file, and saves the result
+// to the output file in the same format.
+// Returns the exit status: 2 for invalid arguments, 1 if the files can't be synced, 0 otherwise.
+func sync(args []string) int {
+	if len(args) < 3 {
+		fmt.Println("Usage: subtitle-processor sync <input> <output> <cue>=<time>...")
+		fmt.Println("       subtitle-processor sync <input> <output> <audio.wav>")
+		fmt.Println("Example: subtitle-processor sync in.srt out.srt 1=00:00:12,500 850=01:41:03,200")
+		return 2
+	}
+
+	audio := len(args) == 3 && strings.EqualFold(filepath.Ext(args[2]), ".wav")
+	var points []subtitles.SyncPoint
+	for k := 2; k < len(args) && !audio; k++ {
+		point, err := parseSyncPoint(args[k])
+		if err != nil {
+			fmt.Println("Error:", err)
+			return 2
+		}
+		points = append(points, point)
+	}
+
+	sub := subtitles.Subtitle{}
+	if err := sub.LoadFile(args[0]); err != nil {
+		fmt.Println("Error:", err)
+		return 1
+	}
+	var dropped []models.ModelItemSubtitle
+	var err error
+	if audio {
+		// Search the drift of the speed changes between frame rates too
+		var result subtitles.AudioSync
+		result, dropped, err = sub.SyncAudio(args[2], subtitles.AudioSyncOptions{MaxDrift: 0.05})
+		if err == nil {
+			fmt.Printf("Offset %v, scale %.6f, %.0f%% of the cue time over speech\n", result.Offset, result.Scale, result.Coverage*100)
+		}
+	} else {
+		dropped, err = sub.Sync(points...)
+	}
+	if err != nil {
+		fmt.Println("Error:", err)
+		return 1
+	}
+	if err = sub.SaveFile(args[1]); err != nil {
+		fmt.Println("Error:", err)
+		return 1
+	}
+
+	fmt.Printf("Synced %d cues of %s to %s\n", len(sub.Lines), args[0], args[1])
+	if len(dropped) > 0 {
+		fmt.Printf("Dropped %d cues ending before the start\n", len(dropped))
+	}
+	return 0
+}
+
+// parseSyncPoint parses a sync point given as <cue>=<time>, with the time as hh:mm:ss,mmm,
+// hh:mm:ss.mmm or a Go duration (e.g., 1m30.5s).
+func parseSyncPoint(s string) (point subtitles.SyncPoint, err error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		return point, fmt.Errorf("invalid sync point %q, expected <cue>=<time>", s)
+	}
+	if point.Cue, err = strconv.Atoi(strings.TrimSpace(parts[0])); err != nil {
+		return point, fmt.Errorf("invalid cue index in sync point %q", s)
+	}
+
+	value := strings.TrimSpace(parts[1])
+	if res := regexp.MustCompile(`^(\d+):(\d{2}):(\d{2})(?:[.,](\d{1,3}))?$`).FindStringSubmatch(value); res != nil {
+		h, _ := strconv.Atoi(res[1])
+		m, _ := strconv.Atoi(res[2])
+		sec, _ := strconv.Atoi(res[3])
+		ms, _ := strconv.Atoi((res[4] + "000")[:3])
+		point.Time = time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec)*time.Second + time.Duration(ms)*time.Millisecond
+		return point, nil
+	}
+	if point.Time, err = time.ParseDuration(value); err != nil {
+		return point, fmt.Errorf("invalid time in sync point %q", s)
+	}
+	return point, nil
+}
@@ -0,0 +1,262 @@
+package subtitles
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+)
+
+// Parameters of the alignment of a subtitle to a reference subtitle.
+const (
+	alignWindow        = 3                      // Maximum cues between two matched cues, plus one
+	alignSkipCost      = 0.4                    // Cost of a cue left unmatched
+	alignDurationShare = 0.25                   // Share of the durations in the cost of a match, the rest is the interval to the previous match
+	alignGoodCost      = 0.25                   // Maximum cost of a good match, counted in the confidence
+	alignTolerance     = time.Second            // Maximum difference between the offsets of the matches of a segment
+	alignMinMatches    = 3                      // Minimum matches of a segment, the matches of shorter ones join the previous segment
+	alignMinInterval   = 500 * time.Millisecond // Interval under which the differences of intervals are not relative
+	alignBand          = 200                    // Maximum distance between a reference cue matched and the position of the cue in the reference, in cues
+	alignMaxCells      = 1 << 24                // Maximum cells of the dynamic programming (8 bytes each)
+)
+
+// AlignSegment is a run of consecutive cues of a subtitle moved by the same offset to align
+// them to a reference subtitle.
+type AlignSegment struct {
+	First   int           // Index of the first cue of the segment in Lines, starting at 1
+	Last    int           // Index of the last cue of the segment in Lines
+	Offset  time.Duration // Offset added to the times of the cues
+	Matches int           // Number of cues of the segment matched to cues of the reference
+}
+
+// Alignment is the alignment of a subtitle to a reference subtitle.
+type Alignment struct {
+	Confidence float64        // Confidence of the alignment, between 0 (none) and 1 (every cue matched well)
+	Matches    int            // Number of cues matched to cues of the reference
+	Segments   []AlignSegment // Segments of cues with their offsets, in order
+}
+
+// alignMatch is a cue of the subtitle matched to a cue of the reference.
+type alignMatch struct {
+	cue, ref int     // Index of the cues, starting at 0
+	cost     float64 // Cost of the match, between 0 and 1
+}
+
+// FindAlignment finds the offsets that align the cues of a subtitle to the cues of a correctly
+// timed reference subtitle, such as the same film in another language, without changing the
+// subtitle. The cues are matched by the rhythm of their start times (the intervals between
+// matched cues) and their durations, with dynamic programming, so the alignment is found
+// whatever the offset of the subtitle, and cues merged, split or missing in one of the
+// subtitles are skipped. The matches are grouped into segments of cues with the same offset,
+// which follow the cuts and the drift of the subtitle. A cue is only matched to the reference
+// cues within 200 cues of its position in the reference (e.g., the cue 500 of 1000 to the cues
+// 300 to 700 of a reference of 1000 cues).
+// Returns an error matching ErrEmpty if a subtitle has no cues, and ErrNoAlignment if no cue
+// matches the reference or the subtitle has too many cues to align (about 40000).
+func (sub *Subtitle) FindAlignment(ref *Subtitle) (ret Alignment, err error) {
+	if len(sub.Lines) == 0 || len(ref.Lines) == 0 {
+		return ret, fmt.Errorf("%w: no cues to align", ErrEmpty)
+	}
+	if len(sub.Lines) > alignMaxCells/(2*alignBand+1) {
+		return ret, fmt.Errorf("%w: too many cues to align (%d)", ErrNoAlignment, len(sub.Lines))
+	}
+
+	matches := alignCues(sub.Lines, ref.Lines)
+	good := 0
+	for _, m := range matches {
+		if m.cost <= alignGoodCost {
+			good++
+		}
+	}
+	if len(matches) == 0 || good == 0 {
+		return ret, ErrNoAlignment
+	}
+	ret.Matches = len(matches)
+	ret.Confidence = 2 * float64(good) / float64(len(sub.Lines)+len(ref.Lines))
+	ret.Segments = alignSegments(sub.Lines, ref.Lines, matches)
+	return ret, nil
+}
+
+// Align retimes the cues of a subtitle to a correctly timed reference subtitle, adding the
+// offset of its segment (see FindAlignment) to each cue. The cues ending at zero or before are
+// removed from Lines and returned. Returns the alignment found, with the segments indexing the
+// cues before the retiming.
+func (sub *Subtitle) Align(ref *Subtitle) (ret Alignment, dropped []models.ModelItemSubtitle, err error) {
+	if ret, err = sub.FindAlignment(ref); err != nil {
+		return ret, nil, err
+	}
+
+	// Shift from the last segment, so the cues dropped don't move the cues of the next ones
+	for k := len(ret.Segments) - 1; k >= 0; k-- {
+		s := ret.Segments[k]
+		dropped = append(sub.ShiftSelection(s.Offset, Selection{First: s.First, Last: s.Last}), dropped...)
+	}
+	return ret, dropped, nil
+}
+
+// alignCues matches the cues to the reference cues with the least total cost: the cost of each
+// match compares the interval since the previous match and the durations of the cues, and
+// every cue left unmatched costs alignSkipCost. Each cue is only matched to the reference cues
+// within alignBand cues of its position in the reference. Returns the matches in order.
+func alignCues(lines []models.ModelItemSubtitle, ref []models.ModelItemSubtitle) []alignMatch {
+	n, m := len(lines), len(ref)
+
+	// The row of each cue holds the band of reference cues from first(i): the cell of the cue
+	// i matched to the reference cue j is i*width+j-first(i)
+	width := 2*alignBand + 1
+	first := func(i int) int {
+		return int(int64(i)*int64(m)/int64(n)) - alignBand
+	}
+	cell := func(i, j int) int {
+		if k := j - first(i); j >= 0 && j < m && k >= 0 && k < width {
+			return i*width + k
+		}
+		return -1
+	}
+
+	// cost[cell(i, j)] is the least cost of the matches ending with the cue i matched to the
+	// reference cue j, and back the cell of the previous match (-1 if none)
+	cost := make([]float32, n*width)
+	back := make([]int32, n*width)
+	for i := 0; i < n; i++ {
+		for k := 0; k < width; k++ {
+			j := first(i) + k
+			if j < 0 || j >= m {
+				cost[i*width+k] = float32(math.Inf(1))
+				continue
+			}
+			match := alignDurationShare * alignDurationCost(lines[i], ref[j])
+			best, from := float64(i+j)*alignSkipCost+match, int32(-1)
+			for pi := i - 1; pi >= 0 && pi >= i-alignWindow; pi-- {
+				for pj := j - 1; pj >= 0 && pj >= j-alignWindow; pj-- {
+					p := cell(pi, pj)
+					if p < 0 {
+						continue
+					}
+					c := float64(cost[p]) + float64(i-pi-1+j-pj-1)*alignSkipCost + match +
+						(1-alignDurationShare)*alignIntervalCost(lines[i].Start-lines[pi].Start, ref[j].Start-ref[pj].Start)
+					if c < best {
+						best, from = c, int32(p)
+					}
+				}
+			}
+			cost[i*width+k], back[i*width+k] = float32(best), from
+		}
+	}
+
+	// End with the match of least cost once the cues left after it are skipped
+	end, best := -1, math.Inf(1)
+	for k := range cost {
+		i, j := k/width, first(k/width)+k%width
+		if c := float64(cost[k]) + float64(n-1-i+m-1-j)*alignSkipCost; c < best {
+			end, best = k, c
+		}
+	}
+
+	var ret []alignMatch
+	for k := end; k >= 0; k = int(back[k]) {
+		ret = append(ret, alignMatch{cue: k / width, ref: first(k/width) + k%width})
+	}
+	for a, b := 0, len(ret)-1; a < b; a, b = a+1, b-1 {
+		ret[a], ret[b] = ret[b], ret[a]
+	}
+
+	// The cost of each match alone, the interval since the previous match and the durations
+	for k := range ret {
+		c := alignDurationShare * alignDurationCost(lines[ret[k].cue], ref[ret[k].ref])
+		if k > 0 {
+			c += (1 - alignDurationShare) * alignIntervalCost(lines[ret[k].cue].Start-lines[ret[k-1].cue].Start, ref[ret[k].ref].Start-ref[ret[k-1].ref].Start)
+		}
+		ret[k].cost = c
+	}
+	return ret
+}
+
+// alignIntervalCost compares two intervals between cues: 0 if equal, up to 1 for intervals
+// differing by as much as the longest one.
+func alignIntervalCost(a, b time.Duration) float64 {
+	longest := a
+	if b > longest {
+		longest = b
+	}
+	if longest < alignMinInterval {
+		longest = alignMinInterval
+	}
+	return math.Min(1, math.Abs(float64(a-b))/float64(longest))
+}
+
+// alignDurationCost compares the durations of two cues like alignIntervalCost.
+func alignDurationCost(a, b models.ModelItemSubtitle) float64 {
+	return alignIntervalCost(a.End-a.Start, b.End-b.Start)
+}
+
+// alignSegments groups the consecutive matches with offsets within alignTolerance of each
+// other into segments, joining the segments of less than alignMinMatches matches to the
+// previous one, and returns the segments covering every cue with the median offset of their
+// matches.
+func alignSegments(lines []models.ModelItemSubtitle, ref []models.ModelItemSubtitle, matches []alignMatch) (ret []AlignSegment) {
+	offset := func(m alignMatch) time.Duration {
+		return ref[m.ref].Start - lines[m.cue].Start
+	}
+
+	// Group the matches by offset, against the median of the group so far
+	var groups [][]alignMatch
+	for _, m := range matches {
+		if k := len(groups) - 1; k >= 0 && absDuration(offset(m)-alignMedian(groups[k], offset)) <= alignTolerance {
+			groups[k] = append(groups[k], m)
+			continue
+		}
+		groups = append(groups, []alignMatch{m})
+	}
+
+	// Join the short groups, which are mismatches rather than cuts, to the previous group
+	var joined [][]alignMatch
+	for _, g := range groups {
+		k := len(joined) - 1
+		switch {
+		case k < 0:
+			joined = append(joined, g)
+		case len(g) < alignMinMatches:
+			joined[k] = append(joined[k], g...)
+		case len(joined[k]) < alignMinMatches:
+			// Only the first group can be short: drop its mismatches, as the first segment
+			// starts at the first cue anyway
+			joined[k] = g
+		default:
+			joined = append(joined, g)
+		}
+	}
+
+	// Each segment starts at its first match, the first one at the first cue
+	for k, g := range joined {
+		s := AlignSegment{First: g[0].cue + 1, Last: len(lines), Offset: alignMedian(g, offset), Matches: len(g)}
+		if k == 0 {
+			s.First = 1
+		}
+		if k+1 < len(joined) {
+			s.Last = joined[k+1][0].cue
+		}
+		ret = append(ret, s)
+	}
+	return ret
+}
+
+// alignMedian returns the median offset of the matches.
+func alignMedian(matches []alignMatch, offset func(alignMatch) time.Duration) time.Duration {
+	offsets := make([]time.Duration, len(matches))
+	for k, m := range matches {
+		offsets[k] = offset(m)
+	}
+	sort.Slice(offsets, func(a, b int) bool { return offsets[a] < offsets[b] })
+	return offsets[len(offsets)/2]
+}
+
+// absDuration returns the absolute value of a duration.
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,477 @@
+package subtitles
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+)
+
+/*
+WAV Format Specification (as read for the audio synchronization):
+
+A RIFF file of chunks, each with a 4 byte identifier, a 32-bit little-endian size and the data,
+padded to an even size:
+
+	"RIFF" <size> "WAVE"
+	"fmt " <size> <format:2> <channels:2> <sample rate:4> <byte rate:4> <block align:2> <bits:2> [...]
+	"data" <size> <samples interleaved by channel>
+
+The formats read are PCM (1) with 8-bit unsigned or 16, 24 and 32-bit signed samples,
+IEEE float (3) with 32 and 64-bit samples, and the extensible format (0xFFFE) with a PCM or
+IEEE float sub-format. The other chunks (e.g., "LIST") are skipped.
+*/
+
+// Parameters of the voice activity detection and the audio synchronization.
+const (
+	audioFrame          = 10 * time.Millisecond  // Duration of the energy frames
+	audioMinContrast    = 6.0                    // Minimum difference in dB between the speech and the noise floor
+	audioContrastShare  = 0.4                    // Share of the difference between the speech level and the noise floor above which a frame is speech
+	audioMaxPause       = 200 * time.Millisecond // Pauses in speech shorter than this are speech
+	audioMinSpeech      = 100 * time.Millisecond // Speech shorter than this is noise
+	audioMaxOffset      = time.Minute            // Default maximum offset searched
+	audioCoarseStep     = 10                     // Frames between the offsets of the coarse search
+	audioDriftStep      = 0.0001                 // Step of the drifts searched (0.36s per hour)
+	audioMaxFineDrift   = 0.002                  // Maximum drift searched by steps, larger ones are frame rate changes
+	audioSilenceDecibel = -100.0                 // Energy in dB of digital silence
+)
+
+// audioFrameRates are the frame rates whose speed changes are searched as drifts.
+var audioFrameRates = []float64{24000.0 / 1001, 24, 25, 30000.0 / 1001, 30}
+
+// SpeechRegion is a region of an audio file with speech.
+type SpeechRegion struct {
+	Start time.Duration // Start time of the speech
+	End   time.Duration // End time of the speech
+}
+
+// AudioSyncOptions are the options of the synchronization of a subtitle to an audio file.
+// The zero AudioSyncOptions searches offsets of up to a minute, without drift.
+type AudioSyncOptions struct {
+	MaxOffset time.Duration // Maximum offset searched, earlier or later (a minute if zero)
+	MaxDrift  float64       // Maximum linear drift searched, as a fraction of the time (e.g., 0.001 for 3.6s per hour, 0.05 for the speed changes of 23.976/25 fps), no drift if zero
+}
+
+// AudioSync is the synchronization of a subtitle to the speech of an audio file: a time t of
+// the subtitle becomes t * Scale + Offset.
+type AudioSync struct {
+	Offset   time.Duration // Offset added to the times
+	Scale    float64       // Scale of the times for the drift, 1 if none
+	Coverage float64       // Fraction of the time of the cues over speech once synchronized, between 0 and 1
+	Speech   time.Duration // Total duration of the speech detected in the audio
+}
+
+// DetectSpeech reads a PCM WAV file (see the WAV format specification above) and returns the
+// regions with speech, from the energy of its frames of 10ms above the noise floor.
+// Returns an error matching ErrUnsupportedAudio if the content is not a WAV file readable.
+func DetectSpeech(r io.Reader) ([]SpeechRegion, error) {
+	energies, err := readWAVEnergies(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []SpeechRegion
+	speech := detectSpeech(energies)
+	for i := 0; i < len(speech); i++ {
+		if !speech[i] {
+			continue
+		}
+		start := i
+		for i < len(speech) && speech[i] {
+			i++
+		}
+		ret = append(ret, SpeechRegion{Start: time.Duration(start) * audioFrame, End: time.Duration(i) * audioFrame})
+	}
+	return ret, nil
+}
+
+// FindAudioSync finds the offset, and the linear drift up to MaxDrift, that best align the cues
+// of a subtitle to the speech of a PCM WAV file, without changing the subtitle. The speech is
+// detected like DetectSpeech, and the alignment is the one with the most cue time over speech
+// and the least cue time over silence. The drifts are searched by steps of 0.01% up to 0.2%,
+// and as the speed changes between the frame rates 23.976, 24, 25, 29.97 and 30 beyond.
+// Returns an error matching ErrEmpty if the subtitle has no cues, ErrUnsupportedAudio if the
+// file is not a WAV file readable, and ErrNoAlignment if the audio has no speech.
+func (sub *Subtitle) FindAudioSync(filename string, opts AudioSyncOptions) (ret AudioSync, err error) {
+	if len(sub.Lines) == 0 {
+		return ret, fmt.Errorf("%w: no cues to sync", ErrEmpty)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return ret, wrapFileError(err)
+	}
+	defer f.Close()
+	energies, err := readWAVEnergies(f)
+	if err != nil {
+		return ret, err
+	}
+
+	// Count the speech frames before each frame, to count the speech of any range at once
+	speech := detectSpeech(energies)
+	counts := make([]int, len(speech)+1)
+	for i, s := range speech {
+		counts[i+1] = counts[i]
+		if s {
+			counts[i+1]++
+		}
+	}
+	if counts[len(speech)] == 0 {
+		return ret, fmt.Errorf("%w: no speech in the audio", ErrNoAlignment)
+	}
+	ret.Speech = time.Duration(counts[len(speech)]) * audioFrame
+
+	maxOffset := opts.MaxOffset
+	if maxOffset <= 0 {
+		maxOffset = audioMaxOffset
+	}
+	maxFrames := int(maxOffset / audioFrame)
+
+	// Search every drift with the coarse offsets, then the best one and its neighbors with
+	// every offset around the best coarse one
+	scales := audioScales(opts.MaxDrift)
+	best := audioCandidate{score: math.MinInt64}
+	for _, scale := range scales {
+		frames := audioCueFrames(sub.Lines, scale)
+		for offset := -maxFrames; offset <= maxFrames; offset += audioCoarseStep {
+			best = best.max(audioCandidate{scale: scale, offset: offset, score: audioScore(frames, offset, counts)})
+		}
+	}
+	coarse := best
+	for _, scale := range []float64{coarse.scale - audioDriftStep, coarse.scale, coarse.scale + audioDriftStep} {
+		if math.Abs(scale-1) > opts.MaxDrift+audioDriftStep/2 {
+			continue
+		}
+		frames := audioCueFrames(sub.Lines, scale)
+		for offset := coarse.offset - audioCoarseStep; offset <= coarse.offset+audioCoarseStep; offset++ {
+			best = best.max(audioCandidate{scale: scale, offset: offset, score: audioScore(frames, offset, counts)})
+		}
+	}
+
+	ret.Offset, ret.Scale = time.Duration(best.offset)*audioFrame, best.scale
+	total, covered := 0, 0
+	for _, cue := range audioCueFrames(sub.Lines, best.scale) {
+		total += cue[1] - cue[0]
+		covered += audioSpeech(cue[0]+best.offset, cue[1]+best.offset, counts)
+	}
+	if total > 0 {
+		ret.Coverage = float64(covered) / float64(total)
+	}
+	return ret, nil
+}
+
+// SyncAudio retimes the cues of a subtitle to the speech of a PCM WAV file with the offset and
+// drift found by FindAudioSync. The times before zero are clamped to zero, and the cues ending
+// at zero or before are removed from Lines and returned.
+func (sub *Subtitle) SyncAudio(filename string, opts AudioSyncOptions) (ret AudioSync, dropped []models.ModelItemSubtitle, err error) {
+	if ret, err = sub.FindAudioSync(filename, opts); err != nil {
+		return ret, nil, err
+	}
+	dropped = sub.retime(Selection{}, func(t time.Duration) time.Duration {
+		return time.Duration(math.Round(float64(t)*ret.Scale)) + ret.Offset
+	})
+	return ret, dropped, nil
+}
+
+// audioCandidate is a scale and an offset in frames of the cues, with the score of the
+// alignment they give.
+type audioCandidate struct {
+	scale  float64
+	offset int
+	score  int
+}
+
+// max returns the candidate with the highest score, or the one with the smallest drift and
+// offset if equal.
+func (c audioCandidate) max(other audioCandidate) audioCandidate {
+	if other.score != c.score {
+		if other.score > c.score {
+			return other
+		}
+		return c
+	}
+	if d, o := math.Abs(other.scale-1), math.Abs(c.scale-1); d != o {
+		if d < o {
+			return other
+		}
+		return c
+	}
+	if absInt(other.offset) < absInt(c.offset) {
+		return other
+	}
+	return c
+}
+
+// audioScales returns the scales of the drifts searched up to maxDrift: the steps of
+// audioDriftStep up to audioMaxFineDrift, and the speed changes between frame rates beyond.
+func audioScales(maxDrift float64) []float64 {
+	ret := []float64{1}
+	fine := math.Min(maxDrift, audioMaxFineDrift)
+	for k := 1; float64(k)*audioDriftStep <= fine+audioDriftStep/2; k++ {
+		ret = append(ret, 1+float64(k)*audioDriftStep, 1-float64(k)*audioDriftStep)
+	}
+	for _, from := range audioFrameRates {
+		for _, to := range audioFrameRates {
+			if scale := from / to; math.Abs(scale-1) > fine && math.Abs(scale-1) <= maxDrift {
+				ret = append(ret, scale)
+			}
+		}
+	}
+	return ret
+}
+
+// audioCueFrames returns the start and end frames of the cues with their times scaled.
+func audioCueFrames(lines []models.ModelItemSubtitle, scale float64) [][2]int {
+	ret := make([][2]int, len(lines))
+	for i := range lines {
+		ret[i][0] = int(math.Round(float64(lines[i].Start) * scale / float64(audioFrame)))
+		ret[i][1] = int(math.Round(float64(lines[i].End) * scale / float64(audioFrame)))
+	}
+	return ret
+}
+
+// audioScore scores the cues moved by offset frames: one point for each frame of a cue over
+// speech, less one for each frame over silence or outside the audio.
+func audioScore(frames [][2]int, offset int, counts []int) (score int) {
+	for _, cue := range frames {
+		if cue[1] > cue[0] {
+			score += 2*audioSpeech(cue[0]+offset, cue[1]+offset, counts) - (cue[1] - cue[0])
+		}
+	}
+	return score
+}
+
+// audioSpeech returns the number of speech frames from the frame start to the frame end
+// (excluded), with the speech frames counted before each frame in counts.
+func audioSpeech(start, end int, counts []int) int {
+	last := len(counts) - 1
+	start, end = clampInt(start, 0, last), clampInt(end, 0, last)
+	if end <= start {
+		return 0
+	}
+	return counts[end] - counts[start]
+}
+
+// detectSpeech returns whether each frame of energies (mean squares of the samples) is speech:
+// above the noise floor (the 10th percentile of the energies in dB) by audioContrastShare of the
+// difference with the speech level (the 90th percentile), and audioMinContrast dB at least.
+// The short pauses are speech, and the short speech is noise.
+func detectSpeech(energies []float64) []bool {
+	speech := make([]bool, len(energies))
+	if len(energies) == 0 {
+		return speech
+	}
+
+	decibels := make([]float64, len(energies))
+	for i, e := range energies {
+		decibels[i] = audioSilenceDecibel
+		if e > 0 {
+			decibels[i] = math.Max(audioSilenceDecibel, 10*math.Log10(e))
+		}
+	}
+	sorted := append([]float64(nil), decibels...)
+	sort.Float64s(sorted)
+	floor, level := sorted[len(sorted)/10], sorted[len(sorted)*9/10]
+	threshold := floor + math.Max(audioMinContrast, (level-floor)*audioContrastShare)
+	for i, db := range decibels {
+		speech[i] = db > threshold
+	}
+
+	// Fill the short pauses, then remove the short speech
+	fillRuns(speech, false, int(audioMaxPause/audioFrame))
+	fillRuns(speech, true, int(audioMinSpeech/audioFrame))
+	return speech
+}
+
+// fillRuns inverts the runs of value shorter than maxLength frames between runs of the other
+// value.
+func fillRuns(frames []bool, value bool, maxLength int) {
+	for i := 0; i < len(frames); i++ {
+		if frames[i] != value {
+			continue
+		}
+		start := i
+		for i < len(frames) && frames[i] == value {
+			i++
+		}
+		if start > 0 && i < len(frames) && i-start < maxLength {
+			for k := start; k < i; k++ {
+				frames[k] = !value
+			}
+		}
+	}
+}
+
+// wavFormat is the format of the samples of a WAV file.
+type wavFormat struct {
+	float      bool // Whether the samples are IEEE floats, or PCM integers
+	channels   int
+	sampleRate int
+	blockAlign int // Bytes of a sample of every channel
+	bits       int // Bits of a sample of a channel
+}
+
+// wavFormatSize is the size of the format chunk of the extensible format, the largest one read.
+const wavFormatSize = 40
+
+// readWAVEnergies reads a PCM WAV file and returns the energy (mean square of the samples,
+// between 0 and 1) of its frames of audioFrame, with the channels mixed. The samples are read
+// a frame at a time, so the file is never loaded whole.
+func readWAVEnergies(r io.Reader) ([]float64, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(br, header); err != nil || string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("%w: not a WAV file", ErrUnsupportedAudio)
+	}
+
+	var format *wavFormat
+	for {
+		chunk := make([]byte, 8)
+		if _, err := io.ReadFull(br, chunk); err != nil {
+			return nil, fmt.Errorf("%w: no data chunk", ErrUnsupportedAudio)
+		}
+		id, size := string(chunk[0:4]), int64(binary.LittleEndian.Uint32(chunk[4:8]))
+
+		switch id {
+		case "fmt ":
+			// Read the fields of the extensible format at most, whatever the size of the chunk
+			data := make([]byte, size)
+			if size > wavFormatSize {
+				data = make([]byte, wavFormatSize)
+			}
+			if _, err := io.ReadFull(br, data); err != nil {
+				return nil, fmt.Errorf("%w: truncated format chunk", ErrUnsupportedAudio)
+			}
+			if _, err := io.CopyN(io.Discard, br, size-int64(len(data))); err != nil {
+				return nil, fmt.Errorf("%w: truncated format chunk", ErrUnsupportedAudio)
+			}
+			var err error
+			if format, err = parseWAVFormat(data); err != nil {
+				return nil, err
+			}
+		case "data":
+			if format == nil {
+				return nil, fmt.Errorf("%w: data chunk before the format chunk", ErrUnsupportedAudio)
+			}
+			// The size of streamed files is unknown: read until the end
+			if size == 0 || size == math.MaxUint32 {
+				size = math.MaxInt64
+			}
+			return readWAVSamples(io.LimitReader(br, size), format)
+		default:
+			if _, err := io.CopyN(io.Discard, br, size+size%2); err != nil {
+				return nil, fmt.Errorf("%w: no data chunk", ErrUnsupportedAudio)
+			}
+			continue
+		}
+		if size%2 == 1 {
+			_, _ = br.ReadByte()
+		}
+	}
+}
+
+// parseWAVFormat parses the format chunk of a WAV file.
+func parseWAVFormat(data []byte) (*wavFormat, error) {
+	if len(data) < 16 {
+		return nil, fmt.Errorf("%w: truncated format chunk", ErrUnsupportedAudio)
+	}
+	code := binary.LittleEndian.Uint16(data[0:2])
+	format := &wavFormat{
+		channels:   int(binary.LittleEndian.Uint16(data[2:4])),
+		sampleRate: int(binary.LittleEndian.Uint32(data[4:8])),
+		blockAlign: int(binary.LittleEndian.Uint16(data[12:14])),
+		bits:       int(binary.LittleEndian.Uint16(data[14:16])),
+	}
+
+	// The extensible format has the format code in the first bytes of its sub-format
+	if code == 0xFFFE && len(data) >= 26 {
+		code = binary.LittleEndian.Uint16(data[24:26])
+	}
+	switch {
+	case code == 1 && (format.bits == 8 || format.bits == 16 || format.bits == 24 || format.bits == 32):
+	case code == 3 && (format.bits == 32 || format.bits == 64):
+		format.float = true
+	default:
+		return nil, fmt.Errorf("%w: format %d with %d bits", ErrUnsupportedAudio, code, format.bits)
+	}
+	if format.channels < 1 || format.sampleRate < 1 || format.blockAlign < format.channels*format.bits/8 {
+		return nil, fmt.Errorf("%w: invalid format", ErrUnsupportedAudio)
+	}
+	return format, nil
+}
+
+// readWAVSamples reads the samples of a WAV file and returns the energy of its frames.
+func readWAVSamples(r io.Reader, format *wavFormat) (energies []float64, err error) {
+	samplesPerFrame := int(int64(format.sampleRate) * int64(audioFrame) / int64(time.Second))
+	if samplesPerFrame < 1 {
+		samplesPerFrame = 1
+	}
+	buf := make([]byte, samplesPerFrame*format.blockAlign)
+	width := format.bits / 8
+	for {
+		n, err := io.ReadFull(r, buf)
+		samples := n / format.blockAlign
+		if samples > 0 {
+			sum := 0.0
+			for s := 0; s < samples; s++ {
+				block := buf[s*format.blockAlign:]
+				mixed := 0.0
+				for c := 0; c < format.channels; c++ {
+					mixed += wavSample(block[c*width:(c+1)*width], format)
+				}
+				mixed /= float64(format.channels)
+				sum += mixed * mixed
+			}
+			energies = append(energies, sum/float64(samples))
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return energies, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// wavSample converts a sample of a channel to a value between -1 and 1.
+func wavSample(b []byte, format *wavFormat) float64 {
+	if format.float {
+		if format.bits == 64 {
+			return math.Float64frombits(binary.LittleEndian.Uint64(b))
+		}
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	}
+	switch format.bits {
+	case 8:
+		return (float64(b[0]) - 128) / 128
+	case 16:
+		return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+	case 24:
+		return float64(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)>>8) / (1 << 23)
+	}
+	return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+}
+
+// clampInt returns v limited to the range from min to max.
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// absInt returns the absolute value of an integer.
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
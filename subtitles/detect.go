@@ -0,0 +1,80 @@
+package subtitles
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/format"
+)
+
+// extensionWeight is the part of the confidence of a detected format given by the file
+// extension; the content probe of the format gives the rest.
+const extensionWeight = 0.1
+
+// FormatCandidate is a format detected for subtitle content, with the confidence of the
+// detection and where it comes from.
+type FormatCandidate struct {
+	Format     format.Format // Detected format
+	Confidence float64       // Confidence of the detection, between 0 and 1
+	Probe      float64       // Confidence of the content probe of the format, between 0 and 1
+	Extension  bool          // Whether the extension of the filename is an extension of the format
+}
+
+// String returns the name and the confidence of the format, and where the confidence comes
+// from (e.g., "SRT 0.91 (content 0.90, extension)").
+func (c FormatCandidate) String() string {
+	ret := fmt.Sprintf("%s %.2f (content %.2f", c.Format.Name(), c.Confidence, c.Probe)
+	if c.Extension {
+		ret += ", extension"
+	}
+	return ret + ")"
+}
+
+// DetectFormat returns the registered formats recognizing the content of a subtitle file,
+// from the highest to the lowest confidence. The confidence is 90% the content probe of the
+// format and 10% whether the extension of the filename (which may be empty) is one of the
+// format, so the extension breaks the ties between the formats recognizing the content, but
+// never selects a format that doesn't. Binary formats probe the raw data, and the other
+// formats the text decoded from its detected character encoding.
+// Returns nil if no format recognizes the content.
+func DetectFormat(data []byte, filename string) []FormatCandidate {
+	ret := rankFormats(string(data), filename, true)
+	probe := Subtitle{}
+	if content, _, _, err := probe.decode(data); err == nil {
+		ret = append(ret, rankFormats(content, filename, false)...)
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Confidence > ret[j].Confidence
+	})
+	return ret
+}
+
+// rankFormats returns the registered binary or text formats recognizing the content, from the
+// highest to the lowest confidence, with the extension of the filename in the confidence.
+func rankFormats(content string, filename string, binary bool) (ret []FormatCandidate) {
+	ext := filepath.Ext(filename)
+	for _, f := range format.Formats() {
+		if format.IsBinary(f) != binary {
+			continue
+		}
+		probe := f.Probe(content)
+		if probe <= 0 {
+			continue
+		}
+		candidate := FormatCandidate{Format: f, Confidence: probe * (1 - extensionWeight), Probe: probe}
+		for _, e := range f.Extensions() {
+			if len(ext) > 0 && strings.EqualFold(e, ext) {
+				candidate.Extension = true
+				candidate.Confidence += extensionWeight
+				break
+			}
+		}
+		ret = append(ret, candidate)
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Confidence > ret[j].Confidence
+	})
+	return ret
+}
@@ -0,0 +1,190 @@
+package subtitles
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// Byte order marks of the Unicode encodings.
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// Most frequent characters of Chinese and Japanese texts, used to score the multi-byte
+// encodings. A wrong decoding produces random characters that rarely belong to these sets.
+const (
+	commonSimplified  = "的一是不了人我在有他这中大来上个国到说们为子和你地出道也时年得就那要下以生会自着去之过家学对可她里后小么心多天而能好都然没日于起还发成事只作当想看文无开手十用主行方又如前所本见经头面公同三已老从动两长知民样现分将外但身些与高意进把法此实回二理美点月明其种声全工己话儿者向情部正名定女问力机给等几很业最间新什打便位因重被走电四第门相次东政海口使教西再平真听世气信北少关并内加化由却代军产入先山五太水万市眼体别处总才场师书比住员九笑性通目华报立马命张活难神数件安表原车白应路期叫死常提感金何更反合放做系计或司利受光王果亲界及今京务制解各任至清物台象记边共风战干接它许八特觉望直服毛林题建南度统色字请交爱让认算论百吃义科怎元社术结六功指思非流每青管夫连远资队跟带花快条院变联言权往展该领传近留红治决周保达办运武半候七必城父强步完革深区即求品士转量空甚众技轻程告江语英基派满式李息写呢识极令黄德收脸钱党倒未持取设始版双历越史商千片容研像找友孩站广改议形委早房音火际则首单据导影失拿网香似斯专石若兵弟谁校读志飞观争究包组造落视济喜离虽坐集编宝谈府拉黑且随格尽剑讲布杀微怕母调局根曾准团段终乐切级克精哪官示冷域读"
+	commonTraditional = "的一是不了人我在有他這中大來上個國到說們為子和你地出道也時年得就那要下以生會自著去之過家學對可她裡後小麼心多天而能好都然沒日於起還發成事只作當想看文無開手十用主行方又如前所本見經頭面公同三已老從動兩長知民樣現分將外但身些與高意進把法此實回二理美點月明其種聲全工己話兒者向情部正名定女問力機給等幾很業最間新什打便位因重被走電四第門相次東政海口使教西再平真聽世氣信北少關並內加化由卻代軍產入先山五太水萬市眼體別處總才場師書比住員九笑性通目華報立馬命張活難神數件安表原車白應路期叫死常提感金何更反合放做系計或司利受光王果親界及今京務制解各任至清物台象記邊共風戰乾接它許八特覺望直服毛林題建南度統色字請交愛讓認算論百吃義科怎元社術結六功指思非流每青管夫連遠資隊跟帶花快條院變聯言權往展該領傳近留紅治決周保達辦運武半候七必城父強步完革深區即求品士轉量空甚眾技輕程告江語英基派滿式李息寫呢識極令黃德收臉錢黨倒未持取設始版雙歷越史商千片容研像找友孩站廣改議形委早房音火際則首單據導影失拿網香似斯專石若兵弟誰校讀志飛觀爭究包組造落視濟喜離雖坐集編寶談府拉黑且隨格盡劍講布殺微怕母調局根曾準團段終樂切級克精哪官示冷域"
+	commonKorean      = "이다는의에가을하고를지한서기사로리도나수어자대시아있해전게정그들보일인니만라면요주내여제상부구우러거소장없것은안나와과까요서요래했었습니다네데무말너우리저내가그래요좋아알았어뭐왜지금여기거기정말다시하나두세생각사람모든오늘내일시간때문에우리는저는진짜빨리잠깐괜찮아그냥계속아니야어디누구언제어떻게이제벌써같아먼저좀더많이조금없어있어해요했어할게"
+	commonJapanese    = "のはにをたがでてとしれさいるかなもこっりまらすあくうおきんだったけよつめせそみわえやねへむろほゆひふちずじどばびぶべぼぱぴぷぺぽアイウエオカキクケコサシスセソタチツテトナニヌネノハヒフヘホマミムメモヤユヨラリルレロワヲンー日本人大年一中出会見行時事自社者地業方新場員立開手力問代明動京目通言理体田主題意不作用度強公持野以思家世多正安院心界教文元重近考画海売知道集別物使品計死特私始朝運終台広住無真有口少町料工建空急止送切転研足究楽起着店病質待試族銀早映親験英医仕去味写字答夜音注帰古歌買悪図週室歩風紙黒花春赤青館屋色走秋夏習駅洋旅服夕借曜飲肉貸堂鳥飯勉冬昼茶弟牛魚兄犬妹姉漢"
+)
+
+// DetectEncoding returns the name of the character encoding of raw subtitle content:
+// "UTF-8", "UTF-16LE" or "UTF-16BE" when the content has a byte order mark, matches
+// the byte patterns of UTF-16 or is valid UTF-8; "Shift_JIS", "GB18030", "Big5" or "EUC-KR" when
+// the content decodes to common Japanese, Chinese or Korean characters; "windows-1252" when the
+// content uses its 0x80-0x9F characters; and "ISO-8859-1" otherwise.
+func DetectEncoding(raw []byte) string {
+	// Byte order marks
+	switch {
+	case bytes.HasPrefix(raw, bomUTF8):
+		return "UTF-8"
+	case bytes.HasPrefix(raw, bomUTF16LE):
+		return "UTF-16LE"
+	case bytes.HasPrefix(raw, bomUTF16BE):
+		return "UTF-16BE"
+	}
+
+	// UTF-16 without byte order mark has zero bytes in the ASCII characters
+	if name := detectUTF16(raw); len(name) > 0 {
+		return name
+	}
+	if utf8.Valid(raw) {
+		return "UTF-8"
+	}
+
+	// Multi-byte encodings, scored by the ratio of common characters in the decoded text
+	best, bestScore := "", 0.0
+	for _, candidate := range []struct {
+		name   string
+		common string
+	}{
+		{"Shift_JIS", commonJapanese},
+		{"GB18030", commonSimplified},
+		{"Big5", commonTraditional},
+		{"EUC-KR", commonKorean},
+	} {
+		if score := scoreEncoding(raw, candidate.name, candidate.common); score > bestScore {
+			best, bestScore = candidate.name, score
+		}
+	}
+	if bestScore >= 0.2 {
+		return best
+	}
+
+	// Single-byte encodings
+	for _, b := range raw {
+		if b >= 0x80 && b <= 0x9F {
+			return "windows-1252"
+		}
+	}
+	return "ISO-8859-1"
+}
+
+// detectUTF16 returns "UTF-16LE" or "UTF-16BE" when most of the odd or even bytes of the
+// content are zero, as in mostly ASCII text encoded in UTF-16, or an empty string otherwise.
+func detectUTF16(raw []byte) string {
+	if len(raw) < 4 || len(raw)%2 != 0 {
+		return ""
+	}
+	even, odd := 0, 0
+	for i := 0; i < len(raw); i += 2 {
+		if raw[i] == 0 {
+			even++
+		}
+		if raw[i+1] == 0 {
+			odd++
+		}
+	}
+	pairs := len(raw) / 2
+	switch {
+	case odd*10 >= pairs*6 && even*10 < pairs:
+		return "UTF-16LE"
+	case even*10 >= pairs*6 && odd*10 < pairs:
+		return "UTF-16BE"
+	}
+	return ""
+}
+
+// scoreEncoding decodes the content with the named encoding and returns the ratio of
+// non-ASCII characters that belong to the common characters, or 0 if the content is
+// not valid in the encoding.
+func scoreEncoding(raw []byte, name string, common string) float64 {
+	content, err := DecodeText(raw, name)
+	if err != nil || strings.ContainsRune(content, utf8.RuneError) {
+		return 0
+	}
+	total, hits := 0, 0
+	for _, r := range content {
+		if r < utf8.RuneSelf {
+			continue
+		}
+		total++
+		if strings.ContainsRune(common, r) {
+			hits++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(hits) / float64(total)
+}
+
+// lookupEncoding returns the encoding with the given name (e.g., "windows-1252",
+// "ISO-8859-15", "Shift_JIS", "UTF-16LE"), case insensitive.
+func lookupEncoding(name string) (encoding.Encoding, error) {
+	switch strings.ToUpper(strings.Replace(name, "_", "-", -1)) {
+	case "", "UTF-8", "UTF8":
+		return unicode.UTF8, nil
+	case "UTF-16LE", "UTF16LE":
+		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), nil
+	case "UTF-16BE", "UTF16BE", "UTF-16", "UTF16":
+		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), nil
+	}
+	enc, err := ianaindex.IANA.Encoding(name)
+	if err != nil || enc == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedEncoding, name)
+	}
+	return enc, nil
+}
+
+// DecodeText converts raw content in the named character encoding to UTF-8.
+// The byte order mark of the content, if any, is kept as U+FEFF.
+func DecodeText(raw []byte, name string) (content string, err error) {
+	enc, err := lookupEncoding(name)
+	if err != nil {
+		return "", err
+	}
+	decoded, _, err := transform.Bytes(enc.NewDecoder(), raw)
+	if err != nil {
+		return "", fmt.Errorf("decoding %s: %w", name, err)
+	}
+	return string(decoded), nil
+}
+
+// EncodeText converts UTF-8 content to the named character encoding, starting with
+// a byte order mark when bom is true and the encoding is UTF-8 or UTF-16.
+// Returns an error if the content has characters that the encoding cannot represent.
+func EncodeText(content string, name string, bom bool) (raw []byte, err error) {
+	enc, err := lookupEncoding(name)
+	if err != nil {
+		return nil, err
+	}
+	content = strings.TrimPrefix(content, "\ufeff")
+	raw, _, err = transform.Bytes(enc.NewEncoder(), []byte(content))
+	if err != nil {
+		return nil, fmt.Errorf("encoding %s: %w", name, err)
+	}
+
+	// Add the byte order mark of the Unicode encodings
+	if bom {
+		switch strings.ToUpper(strings.Replace(name, "_", "-", -1)) {
+		case "", "UTF-8", "UTF8":
+			raw = append(append([]byte{}, bomUTF8...), raw...)
+		case "UTF-16LE", "UTF16LE":
+			raw = append(append([]byte{}, bomUTF16LE...), raw...)
+		case "UTF-16BE", "UTF16BE", "UTF-16", "UTF16":
+			raw = append(append([]byte{}, bomUTF16BE...), raw...)
+		}
+	}
+	return raw, nil
+}
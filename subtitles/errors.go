@@ -0,0 +1,50 @@
+package subtitles
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// Errors returned by the subtitles package. They can be matched with errors.Is,
+// and the errors of the file system operations are wrapped, so errors.Is(err, fs.ErrNotExist)
+// and errors.As(err, *fs.PathError) also work.
+var (
+	ErrUnsupportedFormat   = errors.New("unsupported subtitle format")    // Content or format name not handled by any registered format
+	ErrNotFound            = errors.New("subtitle file not found")        // Subtitle file does not exist
+	ErrEmpty               = errors.New("empty subtitle content")         // Subtitle content has no data
+	ErrUnsupportedEncoding = errors.New("unsupported character encoding") // Character encoding name not known
+	ErrInvalidFrameRate    = errors.New("invalid frame rate")             // Frame rate not positive
+	ErrInvalidSync         = errors.New("invalid sync points")            // Sync points missing, out of range or out of order
+	ErrNoAlignment         = errors.New("no alignment found")             // Subtitle cues not matching the cues of the reference or the speech of the audio
+	ErrUnsupportedAudio    = errors.New("unsupported audio file")         // Audio file not a PCM WAV file
+)
+
+// sentinelError wraps an error so it matches both a sentinel error of this package
+// and the wrapped error.
+type sentinelError struct {
+	sentinel error // Sentinel error of this package
+	err      error // Wrapped error
+}
+
+// Error returns the message of the sentinel error followed by the wrapped error.
+func (e *sentinelError) Error() string {
+	return e.sentinel.Error() + ": " + e.err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *sentinelError) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether the target is the sentinel error.
+func (e *sentinelError) Is(target error) bool {
+	return target == e.sentinel
+}
+
+// wrapFileError classifies an error of a file operation, wrapping missing files with ErrNotFound.
+func wrapFileError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return &sentinelError{sentinel: ErrNotFound, err: err}
+	}
+	return err
+}
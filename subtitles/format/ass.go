@@ -0,0 +1,706 @@
+package format
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+)
+
+/*
+Advanced SubStation Alpha (ASS v4.00+) Format Specification:
+
+The file is split into sections ([Script Info], [V4+ Styles], [Events], ...).
+The [V4+ Styles] and [Events] sections start with a Format: line that names
+the columns of the following Style: and Dialogue: lines, so the columns are
+mapped by name instead of by position. The Text column is always the last one
+and may contain commas.
+
+Example ASS Format:
+[Script Info]
+; Script generated by Aegisub
+Title: Neon Genesis Evangelion - Episode 26
+ScriptType: v4.00+
+PlayResX: 640
+PlayResY: 480
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Default,Arial,28,&H00FFFFFF,&H000000FF,&H00000000,&H80000000,0,0,0,0,100,100,0,0,1,2,2,2,10,10,10,1
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+Dialogue: 0,0:00:01.18,0:00:06.85,Default,Shinji,0,0,0,,Like an angel\Nwith pity on nobody
+Comment: 0,0:00:07.07,0:00:14.40,Default,,0,0,0,,{typesetting note}
+*/
+
+// ssaSection represents a section of a SSA/ASS script with its raw lines.
+type ssaSection struct {
+	Name    string   // Name of the section without brackets (e.g., "Events")
+	Lines   []string // Lines of the section, without the section header
+	Numbers []int    // Line numbers of the lines in the content
+}
+
+// ssaRow represents a Style: or Dialogue: line with its columns mapped by the Format: line.
+type ssaRow struct {
+	Kind   string            // Kind of row (e.g., "Style", "Dialogue", "Comment")
+	Fields map[string]string // Values of the columns by lowercase column name
+	Line   string            // Original line
+	Number int               // Line number in the content
+}
+
+// ssaScript represents a parsed SSA/ASS script.
+type ssaScript struct {
+	Sections     []ssaSection // Sections in file order
+	StyleColumns []string     // Columns of the styles Format: line
+	Styles       []ssaRow     // Rows of the styles section
+	EventColumns []string     // Columns of the events Format: line
+	Events       []ssaRow     // Rows of the events section
+	Format       string       // Name of the format being parsed, for the diagnostics
+	Warnings     []error      // Problems found in the rows that were skipped
+}
+
+// parseSSAScript splits SSA/ASS content into sections and maps the styles and events
+// columns using the Format: line of each section.
+// The name of the format ("SSA" or "ASS") is used in the diagnostics.
+// Returns a *ParseError if the content has no [Script Info] or [Events] section.
+func parseSSAScript(content string, name string) (script ssaScript, err error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+	script.Format = name
+
+	// Split the content into sections, keeping the line numbers of the original content
+	for i, line := range strings.Split(content, "\n") {
+		line = cleanText(line)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			script.Sections = append(script.Sections, ssaSection{Name: line[1 : len(line)-1]})
+			continue
+		}
+		if len(script.Sections) == 0 || line == "" {
+			continue
+		}
+		current := &script.Sections[len(script.Sections)-1]
+		current.Lines = append(current.Lines, line)
+		current.Numbers = append(current.Numbers, i+1)
+	}
+
+	if script.section("Script Info") == nil {
+		return script, &ParseError{Format: name, Reason: "Invalid " + name + ": missing [Script Info] section"}
+	}
+	events := script.section("Events")
+	if events == nil {
+		return script, &ParseError{Format: name, Reason: "Invalid " + name + ": missing [Events] section"}
+	}
+
+	// Map the styles and events columns using their Format: lines
+	if styles := script.stylesSection(); styles != nil {
+		script.StyleColumns, script.Styles = script.parseRows(styles)
+	}
+	script.EventColumns, script.Events = script.parseRows(events)
+	return script, nil
+}
+
+// section returns the section with the given name (case insensitive), or nil if not present.
+func (script *ssaScript) section(name string) *ssaSection {
+	for i := range script.Sections {
+		if strings.EqualFold(script.Sections[i].Name, name) {
+			return &script.Sections[i]
+		}
+	}
+	return nil
+}
+
+// stylesSection returns the [V4+ Styles] or [V4 Styles] section, or nil if not present.
+func (script *ssaScript) stylesSection() *ssaSection {
+	if styles := script.section("V4+ Styles"); styles != nil {
+		return styles
+	}
+	return script.section("V4 Styles")
+}
+
+// isASS reports whether the script is an Advanced SubStation Alpha (v4.00+) script.
+func (script *ssaScript) isASS() bool {
+	if script.section("V4+ Styles") != nil {
+		return true
+	}
+	for _, line := range script.section("Script Info").Lines {
+		key, value := splitSSALine(line)
+		if strings.EqualFold(key, "ScriptType") && strings.EqualFold(value, "v4.00+") {
+			return true
+		}
+	}
+	return false
+}
+
+// splitSSALine splits a "Key: value" line into its key and value.
+func splitSSALine(line string) (key string, value string) {
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 {
+		return strings.TrimSpace(line), ""
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+}
+
+// parseRows maps the rows of a styles or events section using its Format: line.
+// The last column takes the rest of the line, so it may contain commas.
+// Returns the column names as written in the Format: line and the mapped rows.
+// The rows that cannot be mapped are skipped and reported as warnings.
+func (script *ssaScript) parseRows(section *ssaSection) (columns []string, rows []ssaRow) {
+	for n, line := range section.Lines {
+		if strings.HasPrefix(line, ";") {
+			continue
+		}
+		kind, value := splitSSALine(line)
+		if strings.EqualFold(kind, "Format") {
+			columns = nil
+			for _, column := range strings.Split(value, ",") {
+				columns = append(columns, strings.TrimSpace(column))
+			}
+			continue
+		}
+		if len(columns) == 0 || !strings.Contains(line, ":") {
+			script.warn(section.Numbers[n], 0, line, "line before the Format: line of the ["+section.Name+"] section skipped")
+			continue
+		}
+
+		// Keep the original spacing of the last column (the text of the events)
+		value = strings.TrimLeft(strings.SplitN(line, ":", 2)[1], " ")
+		values := strings.SplitN(value, ",", len(columns))
+		if len(values) != len(columns) {
+			script.warn(section.Numbers[n], 0, line, fmt.Sprintf("expected %d columns, got %d", len(columns), len(values)))
+			continue
+		}
+		row := ssaRow{Kind: kind, Fields: map[string]string{}, Line: line, Number: section.Numbers[n]}
+		for i, column := range columns {
+			if i == len(columns)-1 {
+				row.Fields[strings.ToLower(column)] = values[i]
+			} else {
+				row.Fields[strings.ToLower(column)] = strings.TrimSpace(values[i])
+			}
+		}
+		rows = append(rows, row)
+	}
+	return columns, rows
+}
+
+// formatStringSSA2Duration parses a SSA/ASS timestamp (h:mm:ss.cc) and converts it
+// to a time.Duration value. The fraction is scaled by its number of digits.
+func formatStringSSA2Duration(s string) (duration time.Duration, err error) {
+	exp := regexp.MustCompile(`^(\d+):(\d{1,2}):(\d{1,2})(?:[\.,](\d+))?$`)
+	res := exp.FindStringSubmatch(strings.TrimSpace(s))
+	if len(res) != 5 {
+		return duration, errors.New("not time")
+	}
+
+	// Normalize the fraction to milliseconds (e.g., "5" and "50" are 500ms)
+	fraction := (res[4] + "000")[:3]
+	return formatSSA2Duration(res[1], res[2], res[3], fraction), nil
+}
+
+// warn records a problem of the script as a warning.
+func (script *ssaScript) warn(line int, cue int, text string, reason string) {
+	script.Warnings = append(script.Warnings, &ParseError{Format: script.Format, Line: line, Column: 1, Cue: cue, Text: text, Reason: reason})
+}
+
+// readSSAEvents converts the Dialogue events of a parsed script to the internal model.
+// Comment and other events are skipped. Override tags are removed from the text, the
+// styling tags are kept in Spans, the karaoke tags in Words and the original text in RawText.
+// Returns the problems found in the script as warnings.
+func readSSAEvents(script ssaScript) (ret []models.ModelItemSubtitle, warnings []error, err error) {
+	seq := 0
+	for _, event := range script.Events {
+		if !strings.EqualFold(event.Kind, "Dialogue") {
+			continue
+		}
+		if !isSSADialogue(event) {
+			script.warn(event.Number, 0, event.Line, "dialogue with invalid timestamp skipped")
+			continue
+		}
+		start, _ := formatStringSSA2Duration(event.Fields["start"])
+		end, _ := formatStringSSA2Duration(event.Fields["end"])
+		if end < start {
+			script.warn(event.Number, seq+1, event.Line, "end time before start time")
+		}
+		if len(removeSSATags(event.Fields["text"])) == 0 {
+			script.warn(event.Number, seq+1, event.Line, fmt.Sprintf("cue %d has no text", seq+1))
+		}
+
+		// SSA scripts use the Marked column where ASS scripts use Layer
+		layer, ok := event.Fields["layer"]
+		if !ok {
+			layer = event.Fields["marked"]
+		}
+
+		seq++
+		ret = append(ret, models.ModelItemSubtitle{
+			Spans:   parseSSASpans(event.Fields["text"]),
+			Seq:     seq,
+			Start:   start,
+			End:     end,
+			Text:    formatSSAText(event.Fields["text"]),
+			Layer:   layer,
+			Style:   event.Fields["style"],
+			Actor:   event.Fields["name"],
+			MarginL: event.Fields["marginl"],
+			MarginR: event.Fields["marginr"],
+			MarginV: event.Fields["marginv"],
+			Effect:  event.Fields["effect"],
+			RawText: event.Fields["text"],
+			Words:   parseSSAKaraoke(event.Fields["text"], start),
+		})
+	}
+
+	// If no dialogue was found, return an error
+	if len(ret) == 0 {
+		return nil, script.Warnings, &ParseError{Format: script.Format, Reason: "Invalid " + script.Format + ": no dialogues found"}
+	}
+	return ret, script.Warnings, nil
+}
+
+// isSSADialogue reports whether an event is a dialogue read as a subtitle: a Dialogue: line
+// with valid start and end timestamps.
+func isSSADialogue(event ssaRow) bool {
+	if !strings.EqualFold(event.Kind, "Dialogue") {
+		return false
+	}
+	_, errStart := formatStringSSA2Duration(event.Fields["start"])
+	_, errEnd := formatStringSSA2Duration(event.Fields["end"])
+	return errStart == nil && errEnd == nil
+}
+
+// formatSSAText converts the text of a SSA/ASS event to the lines of text of the internal model.
+func formatSSAText(text string) []string {
+	return strings.Split(cleanText(removeSSATags(text)), "\\N")
+}
+
+// removeSSATags removes the override blocks ({\...}) from a SSA/ASS event text
+// and replaces the soft line breaks (\n) and hard spaces (\h) by spaces.
+func removeSSATags(text string) string {
+	text = regexp.MustCompile(`\{[^}]*\}`).ReplaceAllString(text, "")
+	text = strings.Replace(text, "\\n", " ", -1)
+	return strings.Replace(text, "\\h", " ", -1)
+}
+
+// ReadSSAScript parses the script header of SSA/ASS formatted content: the [Script Info]
+// properties, the styles table, the Format: lines, the event lines that are not dialogues
+// read as subtitles (with their position between the dialogues) and any other section, so
+// the script can be written back unchanged.
+// Returns an error if the content is not a valid SSA or ASS format.
+func ReadSSAScript(content string) (script models.ModelScript, err error) {
+	parsed, err := parseSSAScript(content, "SSA")
+	if err != nil {
+		return script, err
+	}
+
+	// Keep the [Script Info] properties and comments in file order
+	for _, line := range parsed.section("Script Info").Lines {
+		if strings.HasPrefix(line, ";") || strings.HasPrefix(line, "!:") || !strings.Contains(line, ":") {
+			script.Info = append(script.Info, models.ModelInfo{Value: line})
+			continue
+		}
+		key, value := splitSSALine(line)
+		script.Info = append(script.Info, models.ModelInfo{Key: key, Value: value})
+	}
+
+	// Keep the styles table with its columns by name
+	if styles := parsed.stylesSection(); styles != nil {
+		script.StylesSection = styles.Name
+	}
+	script.StyleFormat = parsed.StyleColumns
+	for _, row := range parsed.Styles {
+		if !strings.EqualFold(row.Kind, "Style") {
+			continue
+		}
+		style := models.ModelStyle{Name: row.Fields["name"], Fields: map[string]string{}}
+		for _, column := range parsed.StyleColumns {
+			style.Fields[column] = row.Fields[strings.ToLower(column)]
+		}
+		script.Styles = append(script.Styles, style)
+	}
+
+	// Keep the event lines that are not dialogues read as subtitles as they are, after the
+	// number of dialogues before them
+	script.EventFormat = parsed.EventColumns
+	dialogues := map[int]bool{}
+	for _, row := range parsed.Events {
+		if isSSADialogue(row) {
+			dialogues[row.Number] = true
+		}
+	}
+	events := parsed.section("Events")
+	after := 0
+	for n, line := range events.Lines {
+		if dialogues[events.Numbers[n]] {
+			after++
+			continue
+		}
+		if key, _ := splitSSALine(line); strings.EqualFold(key, "Format") {
+			continue
+		}
+		script.OtherEvents = append(script.OtherEvents, models.ModelEvent{Line: line, After: after})
+	}
+
+	// Keep the other sections (e.g., Fonts, Graphics, Aegisub Project Garbage)
+	for _, section := range parsed.Sections {
+		if strings.EqualFold(section.Name, "Script Info") || strings.EqualFold(section.Name, "Events") ||
+			strings.EqualFold(section.Name, script.StylesSection) {
+			continue
+		}
+		script.Sections = append(script.Sections, models.ModelBlock{Kind: section.Name, Text: section.Lines})
+	}
+	return script, nil
+}
+
+// writeSSAScript converts subtitle data from the internal model to SSA/ASS formatted content.
+// The script header of the subtitle is used when present, converted to the variant (SSA or
+// ASS) of the defaults header if needed, otherwise the defaults header is used. Events
+// without margins are written with the given default margin.
+func writeSSAScript(sub *models.Subtitle, defaults string, margin string) (content string) {
+	script := sub.Script
+	target, _ := ReadSSAScript(defaults)
+	if len(script.EventFormat) == 0 {
+		script = target
+	} else if isASSScript(script) != isASSScript(target) {
+		script = convertSSAScript(script, target)
+	}
+
+	// Write the [Script Info] section
+	var b strings.Builder
+	b.WriteString("[Script Info]\n")
+	for _, info := range script.Info {
+		if len(info.Key) == 0 {
+			b.WriteString(info.Value + "\n")
+		} else {
+			b.WriteString(info.Key + ": " + info.Value + "\n")
+		}
+	}
+
+	// Write the styles section
+	defaultStyle := "Default"
+	if len(script.StyleFormat) > 0 {
+		b.WriteString("\n[" + script.StylesSection + "]\n")
+		b.WriteString("Format: " + strings.Join(script.StyleFormat, ", ") + "\n")
+		for _, style := range script.Styles {
+			values := make([]string, len(script.StyleFormat))
+			for i, column := range script.StyleFormat {
+				values[i] = style.Fields[column]
+				if strings.EqualFold(column, "Name") {
+					values[i] = style.Name
+				}
+			}
+			b.WriteString("Style: " + strings.Join(values, ",") + "\n")
+		}
+		if len(script.Styles) > 0 {
+			defaultStyle = script.Styles[0].Name
+		}
+	}
+
+	// Write the [Events] section
+	b.WriteString("\n[Events]\n")
+	b.WriteString("Format: " + strings.Join(script.EventFormat, ", ") + "\n")
+	other := 0
+	for i := range sub.Lines {
+		// Write the other events in their place before the dialogue
+		for ; other < len(script.OtherEvents) && script.OtherEvents[other].After <= i; other++ {
+			b.WriteString(script.OtherEvents[other].Line + "\n")
+		}
+		values := make([]string, len(script.EventFormat))
+		for j, column := range script.EventFormat {
+			values[j] = formatSSAEventField(&sub.Lines[i], column, defaultStyle, margin)
+		}
+		b.WriteString("Dialogue: " + strings.Join(values, ",") + "\n")
+	}
+	for ; other < len(script.OtherEvents); other++ {
+		b.WriteString(script.OtherEvents[other].Line + "\n")
+	}
+
+	// Write the other sections
+	for _, section := range script.Sections {
+		b.WriteString("\n[" + section.Kind + "]\n")
+		for _, line := range section.Text {
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// isASSScript reports whether a script header is an Advanced SubStation Alpha (v4.00+) one.
+func isASSScript(script models.ModelScript) bool {
+	if strings.EqualFold(script.StylesSection, "V4+ Styles") {
+		return true
+	}
+	for _, info := range script.Info {
+		if strings.EqualFold(info.Key, "ScriptType") && strings.EqualFold(info.Value, "v4.00+") {
+			return true
+		}
+	}
+	return false
+}
+
+// convertSSAScript converts a SSA script header to ASS, or an ASS one to SSA, as the target
+// header: the ScriptType property, the styles section and the Format: lines are the ones of
+// the target, and the styles are converted to the columns of the target, with their colors
+// and alignments converted. The columns missing from the script take the values of the
+// first style of the target. The other properties, events and sections are kept.
+func convertSSAScript(script models.ModelScript, target models.ModelScript) models.ModelScript {
+	toASS := isASSScript(target)
+	ret := script
+	ret.StylesSection, ret.StyleFormat, ret.EventFormat = target.StylesSection, target.StyleFormat, target.EventFormat
+
+	// Set the ScriptType of the target, after the leading comments if missing
+	scriptType := "v4.00"
+	if toASS {
+		scriptType = "v4.00+"
+	}
+	ret.Info = nil
+	found := false
+	for _, info := range script.Info {
+		if strings.EqualFold(info.Key, "ScriptType") {
+			info.Value, found = scriptType, true
+		}
+		ret.Info = append(ret.Info, info)
+	}
+	if !found {
+		k := 0
+		for k < len(ret.Info) && len(ret.Info[k].Key) == 0 {
+			k++
+		}
+		ret.Info = append(ret.Info[:k], append([]models.ModelInfo{{Key: "ScriptType", Value: scriptType}}, ret.Info[k:]...)...)
+	}
+
+	// Convert the styles to the columns of the target
+	defaults := map[string]string{}
+	if len(target.Styles) > 0 {
+		defaults = target.Styles[0].Fields
+	}
+	ret.Styles = make([]models.ModelStyle, len(script.Styles))
+	for i, style := range script.Styles {
+		fields := map[string]string{}
+		for column, value := range style.Fields {
+			fields[strings.ToLower(column)] = value
+		}
+		converted := models.ModelStyle{Name: style.Name, Fields: map[string]string{}}
+		for _, column := range target.StyleFormat {
+			key := strings.ToLower(column)
+			value, ok := fields[key]
+			// The outline color of ASS is the tertiary color of SSA
+			if !ok && key == "outlinecolour" {
+				value, ok = fields["tertiarycolour"]
+			} else if !ok && key == "tertiarycolour" {
+				value, ok = fields["outlinecolour"]
+			}
+			switch {
+			case !ok:
+				value = defaults[column]
+			case strings.HasSuffix(key, "colour"):
+				value = convertSSAStyleColor(value, toASS)
+			case key == "alignment":
+				value = convertSSAAlignment(value, toASS)
+			}
+			converted.Fields[column] = value
+		}
+		ret.Styles[i] = converted
+	}
+	return ret
+}
+
+// convertSSAStyleColor converts the color of a style between SSA (a decimal BGR number) and
+// ASS (&HAABBGGRR). Colors that are not valid are returned unchanged.
+func convertSSAStyleColor(color string, toASS bool) string {
+	color = strings.TrimSpace(color)
+	var value int64
+	var err error
+	if hex := strings.Trim(color, "&"); strings.HasPrefix(hex, "H") || strings.HasPrefix(hex, "h") {
+		value, err = strconv.ParseInt(hex[1:], 16, 64)
+	} else {
+		value, err = strconv.ParseInt(color, 10, 64)
+	}
+	if err != nil {
+		return color
+	}
+	if toASS {
+		return fmt.Sprintf("&H%08X", uint32(value))
+	}
+	return strconv.FormatInt(int64(int32(uint32(value))), 10)
+}
+
+// convertSSAAlignment converts the alignment of a style between SSA (1-3 bottom, 5-7 top,
+// 9-11 middle) and ASS (the numeric keypad: 1-3 bottom, 4-6 middle, 7-9 top).
+// Alignments that are not valid are returned unchanged.
+func convertSSAAlignment(alignment string, toASS bool) string {
+	a, err := strconv.Atoi(strings.TrimSpace(alignment))
+	if err != nil {
+		return alignment
+	}
+	switch {
+	case toASS && a >= 5 && a <= 7:
+		a += 2
+	case toASS && a >= 9 && a <= 11:
+		a -= 5
+	case !toASS && a >= 4 && a <= 6:
+		a += 5
+	case !toASS && a >= 7 && a <= 9:
+		a -= 2
+	}
+	return strconv.Itoa(a)
+}
+
+// formatSSAEventField returns the value of a column of the events Format: line for a subtitle line.
+// Empty fields are replaced by the given default style and margin.
+func formatSSAEventField(line *models.ModelItemSubtitle, column string, defaultStyle string, margin string) string {
+	orDefault := func(value string, def string) string {
+		if len(value) == 0 {
+			return def
+		}
+		return value
+	}
+
+	switch strings.ToLower(column) {
+	case "layer":
+		// The Marked values of SSA scripts are not layers
+		if strings.HasPrefix(strings.ToLower(line.Layer), "marked=") {
+			return "0"
+		}
+		return orDefault(line.Layer, "0")
+	case "marked":
+		// The layers of ASS scripts are not Marked values
+		if _, err := strconv.Atoi(line.Layer); err == nil && line.Layer != "0" && line.Layer != "1" {
+			return "0"
+		}
+		return orDefault(line.Layer, "0")
+	case "start":
+		return formatDuration2SSA(line.Start)
+	case "end":
+		return formatDuration2SSA(line.End)
+	case "style":
+		return orDefault(line.Style, defaultStyle)
+	case "name", "actor":
+		return line.Actor
+	case "marginl":
+		return orDefault(line.MarginL, margin)
+	case "marginr":
+		return orDefault(line.MarginR, margin)
+	case "marginv":
+		return orDefault(line.MarginV, margin)
+	case "effect":
+		return line.Effect
+	case "text":
+		// Keep the original text with its override tags while the text is unchanged
+		if len(line.RawText) > 0 && strings.Join(formatSSAText(line.RawText), "\n") == strings.Join(line.Text, "\n") {
+			return line.RawText
+		}
+		// Write the styling of the lines with override tags
+		styled := false
+		spans := make([][]models.ModelSpan, len(line.Text))
+		for i, text := range line.Text {
+			spans[i] = lineSpans(line, i)
+			if spans[i] != nil {
+				styled = true
+			} else {
+				spans[i] = []models.ModelSpan{{Text: cleanText(text)}}
+			}
+		}
+		if styled {
+			return formatSpans2SSA(spans)
+		}
+		cleanedTexts := make([]string, len(line.Text))
+		for i, text := range line.Text {
+			cleanedTexts[i] = cleanText(text)
+		}
+		return strings.Join(cleanedTexts, "\\N")
+	}
+	return ""
+}
+
+// ReadASS parses Advanced SubStation Alpha (v4.00+) formatted subtitle content and
+// converts it to the internal model.
+// Returns a *ParseError if the content is not a valid ASS format.
+func ReadASS(content string) (ret []models.ModelItemSubtitle, err error) {
+	ret, _, err = readASS(content)
+	return ret, err
+}
+
+// readASS parses ASS formatted subtitle content like ReadASS, and also returns the
+// problems found in the script as warnings.
+func readASS(content string) (ret []models.ModelItemSubtitle, warnings []error, err error) {
+	script, err := parseSSAScript(content, "ASS")
+	if err != nil {
+		return nil, nil, err
+	}
+	if !script.isASS() {
+		return nil, nil, &ParseError{Format: "ASS", Reason: "Invalid ASS: missing [V4+ Styles] section or v4.00+ script type"}
+	}
+	return readSSAEvents(script)
+}
+
+// WriteASS converts subtitle data from the internal model to ASS formatted content.
+// The script header read with ReadSSAScript is written back when present, otherwise
+// a standard ASS header with a single Default style is created.
+func WriteASS(sub *models.Subtitle) (content string) {
+	return writeSSAScript(sub, `[Script Info]
+; Script generated by subtitle-processor
+ScriptType: v4.00+
+WrapStyle: 0
+ScaledBorderAndShadow: yes
+PlayResX: 640
+PlayResY: 480
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Default,Arial,28,&H00FFFFFF,&H000000FF,&H00000000,&H80000000,0,0,0,0,100,100,0,0,1,2,2,2,10,10,10,1
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+`, "0")
+}
+
+// probeSSA returns "ASS" for content with the sections of an ASS script, "SSA" for
+// content with the sections of a SSA script, or an empty string otherwise.
+func probeSSA(content string) string {
+	lower := strings.ToLower(content)
+	if !strings.Contains(lower, "[script info]") || !strings.Contains(lower, "[events]") {
+		return ""
+	}
+	if strings.Contains(lower, "[v4+ styles]") || regexp.MustCompile(`(?m)^scripttype:[ \t]*v4\.00\+`).MatchString(lower) {
+		return "ASS"
+	}
+	return "SSA"
+}
+
+// assFormat implements the Format interface for ASS.
+type assFormat struct{}
+
+func init() {
+	Register(assFormat{})
+}
+
+// Name returns the name of the ASS format.
+func (assFormat) Name() string { return "ASS" }
+
+// Extensions returns the file extensions of the ASS format.
+func (assFormat) Extensions() []string { return []string{".ass"} }
+
+// Probe returns the confidence that the content is ASS: the sections of a SSA script
+// with a [V4+ Styles] section or a v4.00+ script type.
+func (assFormat) Probe(content string) float64 {
+	if probeSSA(content) == "ASS" {
+		return 1
+	}
+	return 0
+}
+
+// Read parses ASS content and its script header into sub.
+func (assFormat) Read(content string, sub *models.Subtitle) (err error) {
+	sub.Lines, sub.Warnings, err = readASS(content)
+	if err != nil {
+		return err
+	}
+	sub.Script, err = ReadSSAScript(content)
+	return err
+}
+
+// Write converts the subtitle to ASS content.
+func (assFormat) Write(sub *models.Subtitle) string { return WriteASS(sub) }
@@ -0,0 +1,301 @@
+package format
+
+import (
+	"encoding/csv"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+)
+
+/*
+CSV Format Specification:
+
+A table with a subtitle per row, for spreadsheets, with a header row naming the columns.
+The columns can be in any order, and the unknown columns are ignored:
+
+	seq      Sequence number
+	id       Cue identifier
+	start    Start time (hh:mm:ss.mmm, or a number of milliseconds)
+	end      End time (hh:mm:ss.mmm, or a number of milliseconds)
+	style    Name of the style
+	speaker  Name of the character speaking (the Actor of SSA/ASS)
+	text     Text, with the lines separated by line breaks in a quoted field
+
+The fields are separated by commas, semicolons or tabs. Without a header row, the columns
+are "start,end,speaker,text" unless others are given. Without an end column, each subtitle
+ends at the start of the next one.
+
+Example CSV Format:
+start,end,speaker,text
+00:02:17.440,00:02:20.375,Captain,"Senator, we're making
+our final approach into Coruscant."
+00:02:20.476,00:02:22.501,Senator,"Very good, Lieutenant."
+*/
+
+// csvColumns are the default columns of CSV files.
+var csvColumns = []string{"start", "end", "speaker", "text"}
+
+// ReadCSV parses CSV formatted subtitle content and converts it to the internal model.
+// The header row names the columns; without it, the columns are the given ones, or
+// "start,end,speaker,text" if none. Also returns the columns used.
+// Returns a *ParseError if the content is not a valid CSV format.
+func ReadCSV(content string, columns []string) (ret []models.ModelItemSubtitle, usedColumns []string, err error) {
+	ret, usedColumns, _, err = readCSV(content, columns)
+	return ret, usedColumns, err
+}
+
+// readCSV parses CSV formatted subtitle content like ReadCSV, and also returns the problems
+// found in the rows that were parsed or skipped as warnings.
+func readCSV(content string, columns []string) (ret []models.ModelItemSubtitle, usedColumns []string, warnings []error, err error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+
+	r := csv.NewReader(strings.NewReader(content))
+	r.Comma = csvDelimiter(content)
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	records, err := r.ReadAll()
+	if err != nil {
+		line := 0
+		if parseErr, ok := err.(*csv.ParseError); ok {
+			line = parseErr.Line
+		}
+		return nil, nil, nil, &ParseError{Format: "CSV", Line: line, Reason: "Invalid CSV: " + err.Error()}
+	}
+
+	// The header row names the columns
+	if len(columns) == 0 {
+		columns = csvColumns
+	}
+	if len(records) > 0 && isCSVHeader(records[0]) {
+		columns = records[0]
+		records = records[1:]
+	}
+	for _, name := range columns {
+		usedColumns = append(usedColumns, strings.ToLower(strings.TrimSpace(name)))
+	}
+	index := map[string]int{}
+	for k, name := range usedColumns {
+		if _, ok := index[name]; !ok {
+			index[name] = k
+		}
+	}
+	if _, ok := index["start"]; !ok {
+		return nil, usedColumns, nil, &ParseError{Format: "CSV", Line: 1, Reason: "Invalid CSV: no start column"}
+	}
+	_, hasEnd := index["end"]
+
+	// field returns the trimmed field of a column of the record, or "" if missing
+	field := func(record []string, name string) string {
+		if k, ok := index[name]; ok && k < len(record) {
+			return strings.TrimSpace(record[k])
+		}
+		return ""
+	}
+
+	for i, record := range records {
+		if len(strings.TrimSpace(strings.Join(record, ""))) == 0 {
+			continue
+		}
+		seq := len(ret) + 1
+		raw := strings.Join(record, string(r.Comma))
+
+		start, ok := parseCSVTime(field(record, "start"))
+		if !ok {
+			warnings = append(warnings, &ParseError{Format: "CSV", Cue: seq, Text: raw, Reason: fmt.Sprintf("row %d has an invalid start time", i+1)})
+			continue
+		}
+		item := models.ModelItemSubtitle{Seq: seq, Start: start, ID: field(record, "id"), Style: field(record, "style"), Actor: field(record, "speaker")}
+		if n, errSeq := strconv.Atoi(field(record, "seq")); errSeq == nil && n > 0 {
+			item.Seq = n
+		}
+		if hasEnd {
+			if item.End, ok = parseCSVTime(field(record, "end")); !ok {
+				warnings = append(warnings, &ParseError{Format: "CSV", Cue: seq, Text: raw, Reason: fmt.Sprintf("row %d has an invalid end time", i+1)})
+				continue
+			}
+		}
+		if text := field(record, "text"); len(text) > 0 {
+			for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+				item.Text = append(item.Text, cleanText(line))
+			}
+		}
+
+		if len(item.Text) == 0 {
+			warnings = append(warnings, &ParseError{Format: "CSV", Cue: seq, Text: raw, Reason: fmt.Sprintf("cue %d has no text", seq)})
+		}
+		if hasEnd && item.End < item.Start {
+			warnings = append(warnings, &ParseError{Format: "CSV", Cue: seq, Text: raw, Reason: "end time before start time"})
+		}
+		if len(ret) > 0 && item.Start < ret[len(ret)-1].Start {
+			warnings = append(warnings, &ParseError{Format: "CSV", Cue: seq, Text: raw, Reason: "timestamp out of order"})
+		}
+		ret = append(ret, item)
+	}
+
+	// Without end times, each subtitle ends at the start of the next one
+	if !hasEnd {
+		for i := range ret {
+			ret[i].End = ret[i].Start + tmplayerLastDuration
+			if i+1 < len(ret) {
+				ret[i].End = ret[i+1].Start
+			}
+		}
+	}
+
+	// If no subtitles were found, return an error
+	if len(ret) == 0 {
+		return nil, usedColumns, warnings, &ParseError{Format: "CSV", Reason: "Invalid CSV: no subtitles found"}
+	}
+
+	return ret, usedColumns, warnings, nil
+}
+
+// csvDelimiter returns the field delimiter of CSV content: the most common of comma,
+// semicolon and tab in the first line.
+func csvDelimiter(content string) rune {
+	first := strings.SplitN(content, "\n", 2)[0]
+	delimiter := ','
+	count := strings.Count(first, ",")
+	for _, r := range []rune{';', '\t'} {
+		if n := strings.Count(first, string(r)); n > count {
+			delimiter, count = r, n
+		}
+	}
+	return delimiter
+}
+
+// isCSVHeader reports whether the record is a header row: a field names the start column,
+// and no field is a time. The fields naming unknown columns are allowed.
+func isCSVHeader(record []string) bool {
+	hasStart := false
+	for _, name := range record {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, ok := parseCSVTime(name); ok {
+			return false
+		}
+		hasStart = hasStart || name == "start"
+	}
+	return hasStart
+}
+
+// parseCSVTime converts a CSV time (hh:mm:ss.mmm, mm:ss.mmm or a number of milliseconds)
+// to a time.Duration. Reports whether the time is valid.
+func parseCSVTime(s string) (time.Duration, bool) {
+	if ms, err := strconv.ParseFloat(s, 64); err == nil {
+		if d, ok := parseMilliseconds(ms); ok && ms >= 0 {
+			return d, true
+		}
+		return 0, false
+	}
+	res := regexp.MustCompile(`^(?:(\d+):)?(\d{1,2}):(\d{2})(?:[.,](\d{1,9}))?$`).FindStringSubmatch(s)
+	if len(res) != 5 {
+		return 0, false
+	}
+	return parseTimedBlockTime(res[1:5]), true
+}
+
+// formatDuration2CSV converts a time.Duration to CSV time format string (hh:mm:ss.mmm).
+func formatDuration2CSV(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60, int(d.Milliseconds())%1000)
+}
+
+// WriteCSV converts subtitle data from the internal model to CSV formatted content, with a
+// header row and the CSVColumns of the subtitle ("start,end,speaker,text" if empty).
+// The unknown columns are left empty.
+func WriteCSV(sub *models.Subtitle) (content string) {
+	columns := sub.CSVColumns
+	if len(columns) == 0 {
+		columns = csvColumns
+	}
+
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	_ = w.Write(columns)
+	for i := range sub.Lines {
+		item := &sub.Lines[i]
+		record := make([]string, len(columns))
+		for k, name := range columns {
+			switch strings.ToLower(strings.TrimSpace(name)) {
+			case "seq":
+				record[k] = strconv.Itoa(item.Seq)
+			case "id":
+				record[k] = item.ID
+			case "start":
+				record[k] = formatDuration2CSV(item.Start)
+			case "end":
+				record[k] = formatDuration2CSV(item.End)
+			case "style":
+				record[k] = item.Style
+			case "speaker":
+				record[k] = item.Actor
+			case "text":
+				lines := make([]string, len(item.Text))
+				for j := range item.Text {
+					lines[j] = cleanText(item.Text[j])
+				}
+				record[k] = strings.Join(lines, "\n")
+			}
+		}
+		_ = w.Write(record)
+	}
+	w.Flush()
+	return b.String()
+}
+
+// csvFormat implements the Format interface for CSV.
+type csvFormat struct{}
+
+func init() {
+	Register(csvFormat{})
+}
+
+// Name returns the name of the CSV format.
+func (csvFormat) Name() string { return "CSV" }
+
+// Extensions returns the file extensions of the CSV format.
+func (csvFormat) Extensions() []string { return []string{".csv"} }
+
+// Probe returns the confidence that the content is CSV: a header row naming the start and
+// text columns, or a first row of three fields or more starting with two times.
+func (csvFormat) Probe(content string) float64 {
+	content = strings.TrimPrefix(content, "\ufeff")
+	first := strings.SplitN(strings.TrimLeft(content, "\r\n"), "\n", 2)[0]
+	r := csv.NewReader(strings.NewReader(first))
+	r.Comma = csvDelimiter(first)
+	r.LazyQuotes = true
+	record, err := r.Read()
+	if err != nil || len(record) < 2 {
+		return 0
+	}
+	if isCSVHeader(record) {
+		for _, name := range record {
+			if strings.EqualFold(strings.TrimSpace(name), "text") {
+				return 0.9
+			}
+		}
+	}
+	if len(record) < 3 || !strings.Contains(record[0], ":") || !strings.Contains(record[1], ":") {
+		return 0
+	}
+	if _, ok := parseCSVTime(strings.TrimSpace(record[0])); !ok {
+		return 0
+	}
+	if _, ok := parseCSVTime(strings.TrimSpace(record[1])); !ok {
+		return 0
+	}
+	return 0.5
+}
+
+// Read parses CSV content into sub, with the columns named by the header row or the
+// CSVColumns of the subtitle, and stores the columns used in CSVColumns.
+func (csvFormat) Read(content string, sub *models.Subtitle) (err error) {
+	sub.Lines, sub.CSVColumns, sub.Warnings, err = readCSV(content, sub.CSVColumns)
+	return err
+}
+
+// Write converts the subtitle to CSV content.
+func (csvFormat) Write(sub *models.Subtitle) string { return WriteCSV(sub) }
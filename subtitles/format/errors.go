@@ -0,0 +1,38 @@
+package format
+
+import "fmt"
+
+// ParseError describes a problem found while parsing subtitle content.
+// It is returned as the error of a reader when the content cannot be parsed, and
+// collected in the Warnings of the subtitle for the problems that were skipped.
+type ParseError struct {
+	Format string // Name of the format (e.g., "SRT")
+	Line   int    // Line number in the content, starting at 1 (0 if unknown)
+	Column int    // Column in the line, starting at 1 (0 if unknown)
+	Cue    int    // Index of the cue, starting at 1 (0 if unknown)
+	Text   string // Offending text
+	Reason string // Description of the problem
+}
+
+// Error returns the position, the reason and the offending text of the problem
+// (e.g., `SRT line 14, cue 3: timestamp out of order ("00:01:00,000 --> 00:01:02,000")`).
+func (e *ParseError) Error() string {
+	msg := e.Format
+	if e.Line > 0 {
+		msg += fmt.Sprintf(" line %d", e.Line)
+		if e.Column > 0 {
+			msg += fmt.Sprintf(":%d", e.Column)
+		}
+	}
+	if e.Cue > 0 {
+		if e.Line > 0 {
+			msg += ","
+		}
+		msg += fmt.Sprintf(" cue %d", e.Cue)
+	}
+	msg += ": " + e.Reason
+	if len(e.Text) > 0 {
+		msg += fmt.Sprintf(" (%q)", e.Text)
+	}
+	return msg
+}
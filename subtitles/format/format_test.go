@@ -1,7 +1,10 @@
 package format
 
 import (
+	"bytes"
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -329,3 +332,1363 @@ func TestFormatDuration2SSA(t *testing.T) {
 		}
 	}
 }
+
+// TestVTTReadWrite tests the WebVTT format reading and writing functions
+func TestVTTReadWrite(t *testing.T) {
+	// Test VTT content
+	vttContent := `WEBVTT - Test file
+
+STYLE
+::cue {
+  color: yellow;
+}
+
+NOTE This is a comment
+
+intro
+00:01:30.000 --> 00:01:35.000 line:90% position:50%,center size:80% align:center
+Test line 1
+Test line 2
+
+02:30.500 --> 02:35.753
+Another test line
+
+`
+
+	// Parse the VTT content
+	subtitles, blocks, err := ReadVTT(vttContent)
+	if err != nil {
+		t.Fatalf("Failed to parse VTT content: %v", err)
+	}
+
+	// Verify the parsed data
+	if len(subtitles) != 2 {
+		t.Fatalf("Expected 2 subtitle entries, got %d", len(subtitles))
+	}
+	if len(blocks) != 2 || blocks[0].Kind != "STYLE" || blocks[1].Kind != "NOTE" {
+		t.Errorf("Expected STYLE and NOTE blocks, got %v", blocks)
+	}
+	if !reflect.DeepEqual(blocks[1].Text, []string{"This is a comment"}) {
+		t.Errorf("Expected NOTE text ['This is a comment'], got %v", blocks[1].Text)
+	}
+
+	// Check first subtitle
+	if subtitles[0].ID != "intro" {
+		t.Errorf("Expected identifier 'intro', got '%s'", subtitles[0].ID)
+	}
+	expectedSettings := models.ModelCueSettings{Line: "90%", Position: "50%,center", Size: "80%", Align: "center"}
+	if subtitles[0].Settings != expectedSettings {
+		t.Errorf("Expected settings %v, got %v", expectedSettings, subtitles[0].Settings)
+	}
+	if !reflect.DeepEqual(subtitles[0].Text, []string{"Test line 1", "Test line 2"}) {
+		t.Errorf("Expected text ['Test line 1', 'Test line 2'], got %v", subtitles[0].Text)
+	}
+
+	// Check second subtitle, which uses the short timestamp form
+	expectedStart := 2*time.Minute + 30*time.Second + 500*time.Millisecond
+	if subtitles[1].Start != expectedStart {
+		t.Errorf("Expected start time %v, got %v", expectedStart, subtitles[1].Start)
+	}
+	expectedEnd := 2*time.Minute + 35*time.Second + 753*time.Millisecond
+	if subtitles[1].End != expectedEnd {
+		t.Errorf("Expected end time %v, got %v", expectedEnd, subtitles[1].End)
+	}
+
+	// Test writing VTT content and parse it back
+	writtenContent := WriteVTT(&models.Subtitle{Lines: subtitles, Blocks: blocks})
+	parsedSubtitles, parsedBlocks, err := ReadVTT(writtenContent)
+	if err != nil {
+		t.Fatalf("Failed to parse written VTT content: %v", err)
+	}
+	if !reflect.DeepEqual(parsedSubtitles, subtitles) {
+		t.Errorf("Expected subtitles %v, got %v", subtitles, parsedSubtitles)
+	}
+	if !reflect.DeepEqual(parsedBlocks, blocks) {
+		t.Errorf("Expected blocks %v, got %v", blocks, parsedBlocks)
+	}
+
+	// CRLF line endings are read like LF line endings
+	crlfSubtitles, crlfBlocks, err := ReadVTT(strings.Replace(vttContent, "\n", "\r\n", -1))
+	if err != nil {
+		t.Fatalf("Failed to parse CRLF VTT content: %v", err)
+	}
+	if !reflect.DeepEqual(crlfSubtitles, subtitles) || !reflect.DeepEqual(crlfBlocks, blocks) {
+		t.Errorf("Expected CRLF subtitles %v, got %v", subtitles, crlfSubtitles)
+	}
+
+	// Content without the WEBVTT signature must be rejected
+	if _, _, err := ReadVTT("1\n00:00:01.000 --> 00:00:02.000\nText\n"); err == nil {
+		t.Errorf("Expected error for content without WEBVTT signature")
+	}
+}
+
+// TestASSReadWrite tests the ASS format reading and writing functions
+func TestASSReadWrite(t *testing.T) {
+	// Test ASS content, with columns in a custom order and no \pos tags
+	assContent := `[Script Info]
+; Script generated by Aegisub
+Title: Test
+ScriptType: v4.00+
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Default,Arial,28,&H00FFFFFF,&H000000FF,&H00000000,&H80000000,0,0,0,0,100,100,0,0,1,2,2,2,10,10,10,1
+Style: Signs,Arial,20,&H00FFFFFF,&H000000FF,&H00000000,&H80000000,0,0,0,0,100,100,0,0,1,2,2,8,10,10,10,1
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+Dialogue: 0,0:01:30.00,0:01:35.00,Default,Shinji,0,0,0,,Test line 1, with a comma\NTest line 2
+Comment: 0,0:01:31.00,0:01:32.00,Default,,0,0,0,,This is a comment
+Dialogue: 1,0:02:30.50,0:02:35.75,Signs,,0,0,0,Banner;20,{\i1}Another{\i0} test line
+`
+
+	// Parse the ASS content
+	subtitles, err := ReadASS(assContent)
+	if err != nil {
+		t.Fatalf("Failed to parse ASS content: %v", err)
+	}
+
+	// Verify the parsed data, comments are not subtitles
+	if len(subtitles) != 2 {
+		t.Fatalf("Expected 2 subtitle entries, got %d", len(subtitles))
+	}
+	if !reflect.DeepEqual(subtitles[0].Text, []string{"Test line 1, with a comma", "Test line 2"}) {
+		t.Errorf("Expected text ['Test line 1, with a comma', 'Test line 2'], got %v", subtitles[0].Text)
+	}
+	expectedStart := 2*time.Minute + 30*time.Second + 500*time.Millisecond
+	if subtitles[1].Start != expectedStart {
+		t.Errorf("Expected start time %v, got %v", expectedStart, subtitles[1].Start)
+	}
+	expectedEnd := 2*time.Minute + 35*time.Second + 750*time.Millisecond
+	if subtitles[1].End != expectedEnd {
+		t.Errorf("Expected end time %v, got %v", expectedEnd, subtitles[1].End)
+	}
+	if !reflect.DeepEqual(subtitles[1].Text, []string{"Another test line"}) {
+		t.Errorf("Expected text ['Another test line'], got %v", subtitles[1].Text)
+	}
+
+	// Test writing ASS content and parse it back
+	writtenContent := WriteASS(&models.Subtitle{Lines: subtitles})
+	parsedSubtitles, err := ReadASS(writtenContent)
+	if err != nil {
+		t.Fatalf("Failed to parse written ASS content: %v", err)
+	}
+	if !reflect.DeepEqual(parsedSubtitles, subtitles) {
+		t.Errorf("Expected subtitles %v, got %v", subtitles, parsedSubtitles)
+	}
+
+	// SSA v4 scripts are not ASS scripts
+	if _, err := ReadASS(WriteSSA(&models.Subtitle{Lines: subtitles})); err == nil {
+		t.Errorf("Expected error for SSA v4 content")
+	}
+}
+
+// TestFormatStringSSA2Duration tests the formatStringSSA2Duration function
+func TestFormatStringSSA2Duration(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+	}{
+		{"0:00:01.18", time.Second + 180*time.Millisecond},
+		{"1:02:03.5", time.Hour + 2*time.Minute + 3*time.Second + 500*time.Millisecond},
+		{"0:00:10.123", 10*time.Second + 123*time.Millisecond},
+		{"0:00:10", 10 * time.Second},
+	}
+
+	for _, test := range tests {
+		result, err := formatStringSSA2Duration(test.input)
+		if err != nil || result != test.expected {
+			t.Errorf("formatStringSSA2Duration(%v) = %v, %v; want %v", test.input, result, err, test.expected)
+		}
+	}
+}
+
+// TestASSLosslessRoundTrip tests that the script header and the event fields survive a read and write cycle
+func TestASSLosslessRoundTrip(t *testing.T) {
+	// Test ASS content with custom script info, styles, per-event fields and extra sections
+	assContent := `[Script Info]
+; Script generated by Aegisub
+Title: Test
+ScriptType: v4.00+
+PlayResX: 1920
+PlayResY: 1080
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Main,Open Sans,72,&H00FFFFFF,&H000000FF,&H00000000,&H80000000,-1,0,0,0,100,100,0,0,1,3,1,2,120,120,60,1
+Style: Signs,Arial,48,&H00FFFFFF,&H000000FF,&H00000000,&H80000000,0,0,0,0,100,100,0,0,1,2,0,8,10,10,10,1
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+Comment: 0,0:01:00.00,0:01:01.00,Main,,0,0,0,,opening note
+Dialogue: 0,0:01:30.00,0:01:35.00,Main,Shinji,0,0,0,,Test line 1\NTest line 2
+Comment: 0,0:01:40.00,0:01:41.00,Main,,0,0,0,,between the dialogues
+Dialogue: 0,0:01:bad,0:01:45.00,Main,,0,0,0,,Invalid timestamp
+Dialogue: 5,0:02:30.50,0:02:35.75,Signs,,15,20,25,Banner;20,{\pos(960,100)\fad(200,200)}NERV HQ
+Comment: 0,0:02:31.00,0:02:32.00,Signs,,0,0,0,,typesetting note
+
+[Aegisub Project Garbage]
+Video File: episode26.mkv
+`
+
+	// Parse the ASS content and its script header
+	subtitles, err := ReadASS(assContent)
+	if err != nil {
+		t.Fatalf("Failed to parse ASS content: %v", err)
+	}
+	script, err := ReadSSAScript(assContent)
+	if err != nil {
+		t.Fatalf("Failed to parse ASS script: %v", err)
+	}
+
+	// Verify the per-event fields
+	expected := models.ModelItemSubtitle{
+		Seq:     2,
+		Start:   2*time.Minute + 30*time.Second + 500*time.Millisecond,
+		End:     2*time.Minute + 35*time.Second + 750*time.Millisecond,
+		Text:    []string{"NERV HQ"},
+		Layer:   "5",
+		Style:   "Signs",
+		MarginL: "15",
+		MarginR: "20",
+		MarginV: "25",
+		Effect:  "Banner;20",
+		RawText: `{\pos(960,100)\fad(200,200)}NERV HQ`,
+	}
+	if !reflect.DeepEqual(subtitles[1], expected) {
+		t.Errorf("Expected event %v, got %v", expected, subtitles[1])
+	}
+	if len(script.Styles) != 2 || script.Styles[0].Name != "Main" || script.Styles[0].Fields["Fontname"] != "Open Sans" {
+		t.Errorf("Expected styles Main and Signs, got %v", script.Styles)
+	}
+
+	// The written content must be identical to the original content
+	writtenContent := WriteASS(&models.Subtitle{Lines: subtitles, Script: script})
+	if writtenContent != assContent {
+		t.Errorf("Expected written content:\n%s\ngot:\n%s", assContent, writtenContent)
+	}
+
+	// Edited text is written instead of the original text
+	subtitles[1].Text = []string{"Edited"}
+	writtenContent = WriteASS(&models.Subtitle{Lines: subtitles, Script: script})
+	if !strings.Contains(writtenContent, "Dialogue: 5,0:02:30.50,0:02:35.75,Signs,,15,20,25,Banner;20,Edited\n") {
+		t.Errorf("Expected edited dialogue line, got:\n%s", writtenContent)
+	}
+}
+
+// TestSSAASSConversion tests that the script header is converted when writing ASS as SSA and SSA as ASS
+func TestSSAASSConversion(t *testing.T) {
+	assContent := `[Script Info]
+; Script generated by Aegisub
+Title: Test
+ScriptType: v4.00+
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Signs,Arial,48,&H00FFFFFF,&H000000FF,&H00000000,&H80000000,0,0,0,0,100,100,0,0,1,2,0,8,10,10,10,1
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+Dialogue: 5,0:00:01.00,0:00:02.00,Signs,,0,0,0,,Hello
+`
+	sub := &models.Subtitle{}
+	if err := Lookup("ASS").Read(assContent, sub); err != nil {
+		t.Fatalf("Failed to parse ASS content: %v", err)
+	}
+
+	// ASS to SSA: the SSA sections, script type and columns, with the colors and the alignment converted
+	ssaContent := WriteSSA(sub)
+	for _, expected := range []string{
+		"Title: Test\nScriptType: v4.00\n",
+		"\n[V4 Styles]\nFormat: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, TertiaryColour, BackColour, Bold, Italic, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, AlphaLevel, Encoding\n",
+		"Style: Signs,Arial,48,16777215,255,0,-2147483648,0,0,1,2,0,6,10,10,10,0,1\n",
+		"Format: Marked, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\nDialogue: 0,0:00:01.00,0:00:02.00,Signs,,0,0,0,,Hello\n",
+	} {
+		if !strings.Contains(ssaContent, expected) {
+			t.Errorf("Expected %q in SSA content:\n%s", expected, ssaContent)
+		}
+	}
+	if strings.Contains(ssaContent, "V4+") || strings.Contains(ssaContent, "v4.00+") {
+		t.Errorf("Expected no ASS header in SSA content:\n%s", ssaContent)
+	}
+	if probeSSA(ssaContent) != "SSA" {
+		t.Errorf("Expected the SSA content probed as SSA")
+	}
+
+	// SSA to ASS: the ASS header again, with the colors and the alignment converted back
+	ssaSub := &models.Subtitle{}
+	if err := Lookup("SSA").Read(ssaContent, ssaSub); err != nil {
+		t.Fatalf("Failed to parse SSA content: %v", err)
+	}
+	converted := WriteASS(ssaSub)
+	for _, expected := range []string{
+		"Title: Test\nScriptType: v4.00+\n",
+		"\n[V4+ Styles]\n",
+		"Style: Signs,Arial,48,&H00FFFFFF,&H000000FF,&H00000000,&H80000000,0,0,0,0,100,100,0,0,1,2,0,8,10,10,10,1\n",
+		"Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\nDialogue: 0,0:00:01.00,0:00:02.00,Signs,,0,0,0,,Hello\n",
+	} {
+		if !strings.Contains(converted, expected) {
+			t.Errorf("Expected %q in ASS content:\n%s", expected, converted)
+		}
+	}
+	if probeSSA(converted) != "ASS" {
+		t.Errorf("Expected the ASS content probed as ASS")
+	}
+
+	// A SSA script with Marked= values and without ScriptType gets the ASS script type and layers
+	ssaSub = &models.Subtitle{}
+	if err := Lookup("SSA").Read("[Script Info]\nTitle: Old\n\n[V4 Styles]\n"+
+		"Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, TertiaryColour, BackColour, Bold, Italic, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, AlphaLevel, Encoding\n"+
+		"Style: Default,Arial,28,65535,65535,65535,0,-1,0,1,1,2,10,30,30,30,0,0\n\n[Events]\n"+
+		"Format: Marked, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n"+
+		"Dialogue: Marked=0,0:00:01.00,0:00:02.00,Default,,0000,0000,0000,,Hello\n", ssaSub); err != nil {
+		t.Fatalf("Failed to parse SSA content: %v", err)
+	}
+	converted = WriteASS(ssaSub)
+	if !strings.HasPrefix(converted, "[Script Info]\nScriptType: v4.00+\nTitle: Old\n") ||
+		!strings.Contains(converted, "Style: Default,Arial,28,&H0000FFFF,&H0000FFFF,&H0000FFFF,&H00000000,-1,0,0,0,100,100,0,0,1,1,2,5,30,30,30,0\n") ||
+		!strings.Contains(converted, "Dialogue: 0,0:00:01.00,0:00:02.00,Default,,0000,0000,0000,,Hello\n") {
+		t.Errorf("Unexpected ASS content:\n%s", converted)
+	}
+}
+
+// testFormat is a custom format used to test the registry
+type testFormat struct{}
+
+func (testFormat) Name() string                                    { return "TEST" }
+func (testFormat) Extensions() []string                            { return []string{".tst"} }
+func (testFormat) Read(content string, sub *models.Subtitle) error { return nil }
+func (testFormat) Write(sub *models.Subtitle) string               { return "TEST" }
+func (testFormat) Probe(content string) float64 {
+	if strings.HasPrefix(content, "TEST") {
+		return 1
+	}
+	return 0
+}
+
+// TestRegistry tests the format registry and the detection by confidence
+func TestRegistry(t *testing.T) {
+	// Built-in formats are registered
+	for _, name := range []string{"SRT", "SSA", "ASS", "VTT"} {
+		if Lookup(name) == nil {
+			t.Errorf("Expected format %s to be registered", name)
+		}
+	}
+	if f := LookupExtension("ASS"); f == nil || f.Name() != "ASS" {
+		t.Errorf("Expected format ASS for extension ASS, got %v", f)
+	}
+
+	// Detection picks the highest confidence first
+	tests := []struct {
+		content  string
+		expected []string
+	}{
+		{"WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.000\nText\n", []string{"VTT", "SRT"}},
+		{"1\n00:00:01,000 --> 00:00:02,000\nText\n", []string{"SRT"}},
+		{"[Script Info]\nScriptType: v4.00+\n\n[Events]\n", []string{"ASS", "SSA"}},
+		{"[Script Info]\nScriptType: v4.00\n\n[Events]\n", []string{"SSA"}},
+		{"{1}{1}25\n{25}{50}Text\n", []string{"MicroDVD"}},
+		{"[10][25]Text\n", []string{"MPL2"}},
+		{"<tt xmlns=\"http://www.w3.org/ns/ttml\"><body/></tt>", []string{"TTML"}},
+		{"<SAMI><BODY><SYNC Start=0><P>Text</BODY></SAMI>", []string{"SAMI"}},
+		{"Scenarist_SCC V1.0\n\n00:00:00;00\t942c 942c\n", []string{"SCC"}},
+		{"[INFORMATION]\n[SUBTITLE]\n00:00:01.00,00:00:02.00\nText\n", []string{"SubViewer"}},
+		{"[00:00:01]\nText\n[00:00:02]\n", []string{"SubViewer"}},
+		{"0:00:01.000,0:00:02.000\nText\n", []string{"SBV"}},
+		{"00:00:01:Text\n00:00:02:\n", []string{"TMPlayer"}},
+		{"[ti:Title]\n[00:01.00]Text\n", []string{"LRC"}},
+		{"{\"lines\": [{\"start\": 1000, \"end\": 2000, \"text\": [\"Text\"]}]}", []string{"JSON"}},
+		{"start,end,speaker,text\n00:00:01.000,00:00:02.000,,Text\n", []string{"CSV"}},
+		{"{\"text\": \" Text\", \"segments\": [{\"start\": 1.0, \"end\": 2.0, \"text\": \" Text\"}]}", []string{"Whisper"}},
+		{"[{\"result\": [{\"word\": \"text\", \"start\": 1.0, \"end\": 2.0, \"conf\": 1.0}], \"text\": \"text\"}]", []string{"Whisper"}},
+		{"Unknown", nil},
+	}
+	for _, test := range tests {
+		var names []string
+		for _, f := range Detect(test.content) {
+			names = append(names, f.Name())
+		}
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("Detect(%q) = %v; want %v", test.content, names, test.expected)
+		}
+	}
+
+	// Custom formats can be registered and detected
+	Register(testFormat{})
+	if f := Lookup("test"); f == nil || f.Write(nil) != "TEST" {
+		t.Errorf("Expected custom format TEST to be registered")
+	}
+	if detected := Detect("TEST content"); len(detected) != 1 || detected[0].Name() != "TEST" {
+		t.Errorf("Expected custom format TEST to be detected, got %v", detected)
+	}
+}
+
+// TestParseDiagnostics tests the parse errors and warnings of the readers
+func TestParseDiagnostics(t *testing.T) {
+	// SRT content with a cue without text, an unexpected line and a cue out of order
+	srtContent := `1
+00:01:30,000 --> 00:01:35,000
+
+2
+00:02:30,000 --> 00:02:35,000
+Second line
+garbage
+
+this is not a cue
+
+3
+00:00:10,000 --> 00:00:05,000
+Third line
+`
+	subtitles, warnings, err := readSRT(srtContent)
+	if err != nil {
+		t.Fatalf("Failed to parse SRT content: %v", err)
+	}
+	if len(subtitles) != 3 {
+		t.Errorf("Expected 3 subtitle entries, got %d", len(subtitles))
+	}
+
+	expected := []ParseError{
+		{Format: "SRT", Line: 2, Column: 1, Cue: 1, Text: "00:01:30,000 --> 00:01:35,000", Reason: "cue 1 has no text"},
+		{Format: "SRT", Line: 9, Column: 1, Cue: 3, Text: "this is not a cue", Reason: "unexpected line, expected a sequence number or a timestamp"},
+		{Format: "SRT", Line: 12, Column: 1, Cue: 3, Text: "00:00:10,000 --> 00:00:05,000", Reason: "end time before start time"},
+		{Format: "SRT", Line: 12, Column: 1, Cue: 3, Text: "00:00:10,000 --> 00:00:05,000", Reason: "timestamp out of order"},
+	}
+	if len(warnings) != len(expected) {
+		t.Fatalf("Expected %d warnings, got %d: %v", len(expected), len(warnings), warnings)
+	}
+	for i := range expected {
+		var parseErr *ParseError
+		if !errors.As(warnings[i], &parseErr) || *parseErr != expected[i] {
+			t.Errorf("Warning %d: expected %v, got %v", i, &expected[i], warnings[i])
+		}
+	}
+	if msg := expected[0].Error(); msg != `SRT line 2:1, cue 1: cue 1 has no text ("00:01:30,000 --> 00:01:35,000")` {
+		t.Errorf("Unexpected error message: %s", msg)
+	}
+
+	// Invalid content returns a *ParseError
+	var parseErr *ParseError
+	if _, err := ReadSRT("not a subtitle"); !errors.As(err, &parseErr) || parseErr.Format != "SRT" {
+		t.Errorf("Expected *ParseError for SRT, got %v", err)
+	}
+	if _, err := ReadSSA("[Script Info]\nTitle: x\n"); !errors.As(err, &parseErr) || parseErr.Format != "SSA" {
+		t.Errorf("Expected *ParseError for SSA, got %v", err)
+	}
+
+	// SSA content with a malformed dialogue and an invalid timestamp
+	ssaContent := `[Script Info]
+ScriptType: v4.00
+
+[Events]
+Format: Marked, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+Dialogue: 0,0:00:01.00,0:00:02.00,Default
+Dialogue: 0,0:00:xx.00,0:00:02.00,Default,,0000,0000,0000,,Bad time
+Dialogue: 0,0:00:03.00,0:00:04.00,Default,,0000,0000,0000,,Good line
+`
+	sub := models.Subtitle{}
+	if err := Lookup("SSA").Read(ssaContent, &sub); err != nil {
+		t.Fatalf("Failed to parse SSA content: %v", err)
+	}
+	if len(sub.Lines) != 1 || len(sub.Warnings) != 2 {
+		t.Fatalf("Expected 1 subtitle and 2 warnings, got %d and %v", len(sub.Lines), sub.Warnings)
+	}
+	if !errors.As(sub.Warnings[0], &parseErr) || parseErr.Line != 6 || parseErr.Reason != "expected 10 columns, got 4" {
+		t.Errorf("Unexpected warning: %v", sub.Warnings[0])
+	}
+	if !errors.As(sub.Warnings[1], &parseErr) || parseErr.Line != 7 || parseErr.Reason != "dialogue with invalid timestamp skipped" {
+		t.Errorf("Unexpected warning: %v", sub.Warnings[1])
+	}
+}
+
+func TestRichTextSpans(t *testing.T) {
+	// Test SRT content with styling tags across lines
+	srtContent := "1\n00:00:01,000 --> 00:00:04,000\n<i>Senator, we're <b>making</b>\nour final</i> <font color=\"red\">approach</font>\n\n"
+
+	// Parse the SRT content
+	subtitles, err := ReadSRT(srtContent)
+	if err != nil {
+		t.Fatalf("Failed to parse SRT content: %v", err)
+	}
+	expectedText := []string{"Senator, we're making", "our final approach"}
+	if !reflect.DeepEqual(subtitles[0].Text, expectedText) {
+		t.Errorf("Expected text %v, got %v", expectedText, subtitles[0].Text)
+	}
+	expectedSpans := [][]models.ModelSpan{
+		{{Text: "Senator, we're ", Italic: true}, {Text: "making", Italic: true, Bold: true}},
+		{{Text: "our final", Italic: true}, {Text: " "}, {Text: "approach", Color: "#FF0000"}},
+	}
+	if !reflect.DeepEqual(subtitles[0].Spans, expectedSpans) {
+		t.Errorf("Expected spans %v, got %v", expectedSpans, subtitles[0].Spans)
+	}
+
+	// The styling must survive the conversion SRT -> ASS -> VTT -> SRT
+	assContent := WriteASS(&models.Subtitle{Lines: subtitles})
+	if !strings.Contains(assContent, `{\i1}Senator, we're {\b1}making\N{\b0}our final{\i0} {\c&H0000FF&}approach`) {
+		t.Errorf("Expected ASS override tags, got:\n%s", assContent)
+	}
+	assSubtitles, err := ReadASS(assContent)
+	if err != nil {
+		t.Fatalf("Failed to parse ASS content: %v", err)
+	}
+	if !reflect.DeepEqual(assSubtitles[0].Spans, expectedSpans) {
+		t.Errorf("Expected ASS spans %v, got %v", expectedSpans, assSubtitles[0].Spans)
+	}
+	vttContent := WriteVTT(&models.Subtitle{Lines: assSubtitles})
+	if !strings.Contains(vttContent, "<i>Senator, we're </i><b><i>making</i></b>\n<i>our final</i> <c.red>approach</c>\n") {
+		t.Errorf("Expected VTT tags, got:\n%s", vttContent)
+	}
+	vttSubtitles, _, err := ReadVTT(vttContent)
+	if err != nil {
+		t.Fatalf("Failed to parse VTT content: %v", err)
+	}
+	if !reflect.DeepEqual(vttSubtitles[0].Spans, expectedSpans) {
+		t.Errorf("Expected VTT spans %v, got %v", expectedSpans, vttSubtitles[0].Spans)
+	}
+	srtWritten := WriteSRT(&models.Subtitle{Lines: vttSubtitles})
+	if !strings.Contains(srtWritten, "<i>Senator, we're </i><b><i>making</i></b>\n<i>our final</i> <font color=\"#FF0000\">approach</font>\n") {
+		t.Errorf("Expected SRT tags, got:\n%s", srtWritten)
+	}
+
+	// Edited text is written without the stale styling
+	vttSubtitles[0].Text[1] = "our landing"
+	srtWritten = WriteSRT(&models.Subtitle{Lines: vttSubtitles})
+	if !strings.Contains(srtWritten, "\nour landing\n") {
+		t.Errorf("Expected edited text without tags, got:\n%s", srtWritten)
+	}
+
+	// WebVTT voice tags and entities
+	vttSubtitles, _, err = ReadVTT("WEBVTT\n\n00:01.000 --> 00:02.000\n<v Esme>Tom &amp; Jerry</v>\n")
+	if err != nil {
+		t.Fatalf("Failed to parse VTT content: %v", err)
+	}
+	if vttSubtitles[0].Actor != "Esme" || vttSubtitles[0].Text[0] != "Tom & Jerry" || vttSubtitles[0].Spans != nil {
+		t.Errorf("Expected voice Esme and text \"Tom & Jerry\", got %q %q", vttSubtitles[0].Actor, vttSubtitles[0].Text)
+	}
+
+	// Text between angle brackets that is not a tag is kept
+	subtitles, err = ReadSRT("1\n00:00:01,000 --> 00:00:02,000\n<Music> playing\na < b > c <i>d</i>\n")
+	if err != nil {
+		t.Fatalf("Failed to parse SRT content: %v", err)
+	}
+	expectedText = []string{"<Music> playing", "a < b > c d"}
+	if !reflect.DeepEqual(subtitles[0].Text, expectedText) {
+		t.Errorf("Expected text %q, got %q", expectedText, subtitles[0].Text)
+	}
+	expectedSpans = [][]models.ModelSpan{{{Text: "<Music> playing"}}, {{Text: "a < b > c "}, {Text: "d", Italic: true}}}
+	if !reflect.DeepEqual(subtitles[0].Spans, expectedSpans) {
+		t.Errorf("Expected spans %v, got %v", expectedSpans, subtitles[0].Spans)
+	}
+	vttSubtitles, _, err = ReadVTT("WEBVTT\n\n00:01.000 --> 00:02.000\n<Music> <lang en>playing</lang> <00:01.500>now\n")
+	if err != nil {
+		t.Fatalf("Failed to parse VTT content: %v", err)
+	}
+	if vttSubtitles[0].Text[0] != "<Music> playing now" {
+		t.Errorf("Expected text \"<Music> playing now\", got %q", vttSubtitles[0].Text[0])
+	}
+}
+
+func TestMicroDVDReadWrite(t *testing.T) {
+	// Test MicroDVD content with a frame rate header and control codes
+	microDVDContent := "{1}{1}25\n{25}{50}Hello|{y:i}World\n{75}{100}{Y:b}Bold|Text\n"
+
+	// Parse the MicroDVD content with another frame rate, which the header overrides
+	subtitles, frameRate, err := ReadMicroDVD(microDVDContent, 30)
+	if err != nil {
+		t.Fatalf("Failed to parse MicroDVD content: %v", err)
+	}
+	if frameRate != 25 {
+		t.Errorf("Expected frame rate 25, got %v", frameRate)
+	}
+	if len(subtitles) != 2 {
+		t.Fatalf("Expected 2 subtitles, got %d", len(subtitles))
+	}
+	if subtitles[0].Start != time.Second || subtitles[0].End != 2*time.Second {
+		t.Errorf("Expected 1s --> 2s, got %v --> %v", subtitles[0].Start, subtitles[0].End)
+	}
+	if !reflect.DeepEqual(subtitles[0].Text, []string{"Hello", "World"}) {
+		t.Errorf("Expected text [Hello World], got %v", subtitles[0].Text)
+	}
+	if subtitles[0].Spans[0][0].Italic || !subtitles[0].Spans[1][0].Italic {
+		t.Errorf("Expected only the second line italic, got %v", subtitles[0].Spans)
+	}
+	if !subtitles[1].Spans[0][0].Bold || !subtitles[1].Spans[1][0].Bold {
+		t.Errorf("Expected both lines bold, got %v", subtitles[1].Spans)
+	}
+
+	// Without header, the given frame rate is used
+	subtitles, _, err = ReadMicroDVD("{48}{96}Text\n", 24)
+	if err != nil || subtitles[0].Start != 2*time.Second {
+		t.Errorf("Expected start 2s at 24 fps, got %v (%v)", subtitles[0].Start, err)
+	}
+
+	// Write the subtitles back at 25 fps
+	sub := &models.Subtitle{Lines: []models.ModelItemSubtitle{
+		{Seq: 1, Start: time.Second, End: 2*time.Second + 30*time.Millisecond, Text: []string{"Hello", "World"},
+			Spans: [][]models.ModelSpan{{{Text: "Hello"}}, {{Text: "World", Italic: true, Color: "#FF0000"}}}},
+	}, FrameRate: 25}
+	expectedContent := "{1}{1}25\n{25}{51}Hello|{y:i}{c:$0000FF}World\n"
+	if content := WriteMicroDVD(sub); content != expectedContent {
+		t.Errorf("Expected content:\n%s\ngot:\n%s", expectedContent, content)
+	}
+
+	// The rounding of times between frames is configurable
+	sub.FrameRounding = models.RoundDown
+	if content := WriteMicroDVD(sub); !strings.Contains(content, "{25}{50}") {
+		t.Errorf("Expected end frame rounded down to 50, got:\n%s", content)
+	}
+	sub.FrameRounding = models.RoundUp
+	if content := WriteMicroDVD(sub); !strings.Contains(content, "{25}{51}") {
+		t.Errorf("Expected end frame rounded up to 51, got:\n%s", content)
+	}
+
+	// Frames converted to times are written back to the same frames at 23.976 fps
+	subtitles, _, _ = ReadMicroDVD("{1}{1}23.976\n{24}{1001}Text\n", 0)
+	sub = &models.Subtitle{Lines: subtitles, FrameRate: 23.976, FrameRounding: models.RoundDown}
+	if content := WriteMicroDVD(sub); !strings.Contains(content, "{24}{1001}Text") {
+		t.Errorf("Expected frames 24 and 1001, got:\n%s", content)
+	}
+}
+
+func TestMPL2ReadWrite(t *testing.T) {
+	// Test MPL2 content with an italic line
+	mpl2Content := "[10][25]Hello|/World\n[30][45]Text\n"
+
+	// Parse the MPL2 content
+	subtitles, err := ReadMPL2(mpl2Content)
+	if err != nil {
+		t.Fatalf("Failed to parse MPL2 content: %v", err)
+	}
+	if len(subtitles) != 2 {
+		t.Fatalf("Expected 2 subtitles, got %d", len(subtitles))
+	}
+	if subtitles[0].Start != time.Second || subtitles[0].End != 2500*time.Millisecond {
+		t.Errorf("Expected 1s --> 2.5s, got %v --> %v", subtitles[0].Start, subtitles[0].End)
+	}
+	if !reflect.DeepEqual(subtitles[0].Text, []string{"Hello", "World"}) || !subtitles[0].Spans[1][0].Italic {
+		t.Errorf("Expected text [Hello World] with italic World, got %v %v", subtitles[0].Text, subtitles[0].Spans)
+	}
+
+	// Write the subtitles back
+	writtenContent := WriteMPL2(&models.Subtitle{Lines: subtitles})
+	if writtenContent != mpl2Content {
+		t.Errorf("Expected written content:\n%s\ngot:\n%s", mpl2Content, writtenContent)
+	}
+}
+
+func TestTTMLReadWrite(t *testing.T) {
+	// Test DFXP content with frame-based, offset and tick timing, styles and regions
+	ttmlContent := `<?xml version="1.0" encoding="utf-8"?>
+<tt xmlns="http://www.w3.org/2006/10/ttaf1" xmlns:ttp="http://www.w3.org/2006/10/ttaf1#parameter"
+    xmlns:tts="http://www.w3.org/2006/10/ttaf1#styling" ttp:frameRate="25" ttp:tickRate="10000000" xml:lang="es">
+  <head>
+    <styling>
+      <style xml:id="s1" tts:fontStyle="italic"/>
+      <style xml:id="s2" style="s1" tts:color="#FF0000FF"/>
+    </styling>
+    <layout>
+      <region xml:id="top" tts:origin="10% 10%" tts:extent="80% 20%"/>
+    </layout>
+  </head>
+  <body>
+    <div begin="10s">
+      <p xml:id="c1" begin="00:00:01:12" end="00:00:02.500" region="top">
+        Hola <span tts:fontWeight="bold">mundo</span><br/>
+        <span style="s2">adiós</span>
+      </p>
+      <p begin="50f" dur="20000000t" style="s1">Texto</p>
+    </div>
+  </body>
+</tt>
+`
+
+	// Parse the TTML content
+	subtitles, err := ReadTTML(ttmlContent)
+	if err != nil {
+		t.Fatalf("Failed to parse TTML content: %v", err)
+	}
+	if len(subtitles) != 2 {
+		t.Fatalf("Expected 2 subtitles, got %d", len(subtitles))
+	}
+	expected := models.ModelItemSubtitle{
+		Seq:      1,
+		Start:    11*time.Second + 480*time.Millisecond,
+		End:      12*time.Second + 500*time.Millisecond,
+		Text:     []string{"Hola mundo", "adiós"},
+		ID:       "c1",
+		Settings: models.ModelCueSettings{Region: "top"},
+		Spans: [][]models.ModelSpan{
+			{{Text: "Hola "}, {Text: "mundo", Bold: true}},
+			{{Text: "adiós", Italic: true, Color: "#FF0000"}},
+		},
+	}
+	if !reflect.DeepEqual(subtitles[0], expected) {
+		t.Errorf("Expected subtitle %+v, got %+v", expected, subtitles[0])
+	}
+	if subtitles[1].Start != 12*time.Second || subtitles[1].End != 14*time.Second || subtitles[1].Style != "s1" || !subtitles[1].Spans[0][0].Italic {
+		t.Errorf("Expected italic 12s --> 14s with style s1, got %+v", subtitles[1])
+	}
+
+	// Write an IMSC1 document and parse it back
+	sub := &models.Subtitle{Lines: subtitles, Language: "es"}
+	writtenContent := WriteTTML(sub)
+	for _, expected := range []string{
+		`ttp:profile="http://www.w3.org/ns/ttml/profile/imsc1/text"`,
+		`xml:lang="es"`,
+		`<style xml:id="s1"/>`,
+		`<region xml:id="top"`,
+		`<p xml:id="c1" begin="00:00:11.480" end="00:00:12.500" region="top">Hola <span tts:fontWeight="bold">mundo</span><br/><span tts:fontStyle="italic" tts:color="#FF0000">adiós</span></p>`,
+	} {
+		if !strings.Contains(writtenContent, expected) {
+			t.Errorf("Expected written content to contain %s, got:\n%s", expected, writtenContent)
+		}
+	}
+	reparsed, err := ReadTTML(writtenContent)
+	if err != nil {
+		t.Fatalf("Failed to parse written TTML content: %v", err)
+	}
+	subtitles[1].Settings.Region = "bottom" // cues without region are shown in the bottom region
+	if !reflect.DeepEqual(reparsed, subtitles) {
+		t.Errorf("Expected written subtitles %+v, got %+v", subtitles, reparsed)
+	}
+
+	// Invalid documents are reported
+	if _, err := ReadTTML("<html><body></body></html>"); err == nil {
+		t.Errorf("Expected error for a document without tt element")
+	}
+	var parseErr *ParseError
+	if _, err := ReadTTML("<tt>\n<body><p begin=\"1s\" end=\"2s\">Text</body></tt>"); !errors.As(err, &parseErr) || parseErr.Line != 2 {
+		t.Errorf("Expected parse error at line 2, got %v", err)
+	}
+}
+
+func TestSAMIReadWrite(t *testing.T) {
+	// Test SAMI content with two languages, styling and clearing paragraphs
+	samiContent := `<SAMI>
+<HEAD>
+<TITLE>Test</TITLE>
+<STYLE TYPE="text/css">
+<!--
+P { font-family: Arial; }
+.KRCC { Name: Korean; lang: ko-KR; SAMIType: CC; }
+.ENCC { Name: English; lang: en-US; SAMIType: CC; }
+-->
+</STYLE>
+</HEAD>
+<BODY>
+<SYNC Start=1000><P Class=KRCC>첫 번째<br>줄
+<SYNC Start=1000><P Class=ENCC>First <i>line</i><br>
+  second line
+<SYNC Start=2500><P Class=ENCC>Tom &amp; Jerry
+<SYNC Start=3000><P Class=KRCC>&nbsp;
+<SYNC Start=4000><P Class=ENCC>&nbsp;
+</BODY>
+</SAMI>
+`
+
+	// List the languages
+	languages, err := ReadSAMILanguages(samiContent)
+	if err != nil {
+		t.Fatalf("Failed to list SAMI languages: %v", err)
+	}
+	expectedLanguages := []SAMILanguage{{"KRCC", "Korean", "ko-KR"}, {"ENCC", "English", "en-US"}}
+	if !reflect.DeepEqual(languages, expectedLanguages) {
+		t.Errorf("Expected languages %v, got %v", expectedLanguages, languages)
+	}
+
+	// Parse the first language
+	subtitles, err := ReadSAMI(samiContent, "")
+	if err != nil {
+		t.Fatalf("Failed to parse SAMI content: %v", err)
+	}
+	if len(subtitles) != 1 || !reflect.DeepEqual(subtitles[0].Text, []string{"첫 번째", "줄"}) || subtitles[0].End != 3*time.Second {
+		t.Errorf("Expected Korean subtitle until 3s, got %v", subtitles)
+	}
+
+	// Parse the English language by its tag; each SYNC ends at the next one of the language
+	subtitles, err = ReadSAMI(samiContent, "en-US")
+	if err != nil {
+		t.Fatalf("Failed to parse SAMI content: %v", err)
+	}
+	if len(subtitles) != 2 {
+		t.Fatalf("Expected 2 subtitles, got %d", len(subtitles))
+	}
+	if subtitles[0].Start != time.Second || subtitles[0].End != 2500*time.Millisecond || subtitles[1].End != 4*time.Second {
+		t.Errorf("Expected 1s --> 2.5s --> 4s, got %v", subtitles)
+	}
+	if !reflect.DeepEqual(subtitles[0].Text, []string{"First line", "second line"}) || !subtitles[0].Spans[0][1].Italic {
+		t.Errorf("Expected text [First line second line] with italic line, got %v %v", subtitles[0].Text, subtitles[0].Spans)
+	}
+	if subtitles[1].Text[0] != "Tom & Jerry" {
+		t.Errorf("Expected text \"Tom & Jerry\", got %q", subtitles[1].Text[0])
+	}
+	if _, err := ReadSAMI(samiContent, "fr-FR"); err == nil {
+		t.Errorf("Expected error for a missing language")
+	}
+
+	// Write both languages and parse them back
+	korean, _ := ReadSAMI(samiContent, "KRCC")
+	writtenContent := WriteSAMILanguages([]*models.Subtitle{
+		{Lines: korean, Language: "ko-KR"},
+		{Lines: subtitles, Language: "en-US"},
+	})
+	if !strings.Contains(writtenContent, ".KOKRCC { Name: ko-KR; lang: ko-KR; SAMIType: CC; }") ||
+		!strings.Contains(writtenContent, "<SYNC Start=1000><P Class=ENUSCC>First <i>line</i><br>second line\n") ||
+		!strings.Contains(writtenContent, "<SYNC Start=2500><P Class=ENUSCC>Tom &amp; Jerry\n<SYNC Start=3000><P Class=KOKRCC>&nbsp;\n") {
+		t.Errorf("Unexpected written content:\n%s", writtenContent)
+	}
+	reparsed, err := ReadSAMI(writtenContent, "en-US")
+	if err != nil {
+		t.Fatalf("Failed to parse written SAMI content: %v", err)
+	}
+	if !reflect.DeepEqual(reparsed, subtitles) {
+		t.Errorf("Expected written subtitles %v, got %v", subtitles, reparsed)
+	}
+}
+
+func TestSTLReadWrite(t *testing.T) {
+	// Write subtitles with accented letters, styling and a long text
+	long := strings.Repeat("Long text ", 15)
+	sub := &models.Subtitle{Lines: []models.ModelItemSubtitle{
+		{Seq: 1, Start: time.Second, End: 2*time.Second + 40*time.Millisecond, Text: []string{"Café crème", "Ørsted"}},
+		{Seq: 2, Start: 3 * time.Second, End: 4 * time.Second, Text: []string{"Red italic"},
+			Spans:    [][]models.ModelSpan{{{Text: "Red "}, {Text: "italic", Italic: true, Color: "#FF0000"}}},
+			Settings: models.ModelCueSettings{Align: "left", Line: "5"}},
+		{Seq: 3, Start: 5 * time.Second, End: 6 * time.Second, Text: []string{strings.TrimSpace(long)}},
+	}, Language: "fr-FR"}
+	raw := WriteSTL(sub)
+	if string(raw[0:16]) != "850STL25.011000F" {
+		t.Errorf("Expected GSI block 850STL25.01100F, got %q", raw[0:16])
+	}
+	if len(raw) != stlBlockSize+4*stlTTISize {
+		t.Errorf("Expected 4 TTI blocks, got %d bytes", len(raw))
+	}
+	if !bytes.Contains(raw, []byte{'C', 'a', 'f', 0xC2, 'e'}) || !bytes.Contains(raw, []byte{0xE9, 'r', 's'}) {
+		t.Errorf("Expected ISO 6937 accented letters in the text")
+	}
+
+	// Parse the content back
+	subtitles, frameRate, err := ReadSTL(raw)
+	if err != nil {
+		t.Fatalf("Failed to parse STL content: %v", err)
+	}
+	if frameRate != 25 {
+		t.Errorf("Expected frame rate 25, got %v", frameRate)
+	}
+	if len(subtitles) != 3 {
+		t.Fatalf("Expected 3 subtitles, got %d", len(subtitles))
+	}
+	if subtitles[0].Start != time.Second || subtitles[0].End != 2*time.Second+40*time.Millisecond {
+		t.Errorf("Expected 1s --> 2.04s, got %v --> %v", subtitles[0].Start, subtitles[0].End)
+	}
+	if !reflect.DeepEqual(subtitles[0].Text, []string{"Café crème", "Ørsted"}) || !subtitles[0].Spans[0][0].DoubleHeight {
+		t.Errorf("Expected double height text [Café crème Ørsted], got %v %v", subtitles[0].Text, subtitles[0].Spans)
+	}
+	expectedSpans := [][]models.ModelSpan{{{Text: "Red "}, {Text: "italic", Italic: true, Color: "#FF0000"}}}
+	if !reflect.DeepEqual(subtitles[1].Spans, expectedSpans) {
+		t.Errorf("Expected spans %v, got %v", expectedSpans, subtitles[1].Spans)
+	}
+	if subtitles[1].Settings.Align != "left" || subtitles[1].Settings.Line != "5" {
+		t.Errorf("Expected left aligned row 5, got %+v", subtitles[1].Settings)
+	}
+	if subtitles[2].Text[0] != strings.TrimSpace(long) {
+		t.Errorf("Expected the long text joined from the extension blocks, got %q", subtitles[2].Text[0])
+	}
+
+	// Greek text uses the Greek character code table
+	sub = &models.Subtitle{Lines: []models.ModelItemSubtitle{
+		{Seq: 1, Start: time.Second, End: 2 * time.Second, Text: []string{"Καλημέρα"}},
+	}, FrameRate: 29.97}
+	raw = WriteSTL(sub)
+	if string(raw[3:14]) != "STL30.01103" {
+		t.Errorf("Expected 30 fps and code table 03, got %q", raw[3:14])
+	}
+	if subtitles, _, err = ReadSTL(raw); err != nil || subtitles[0].Text[0] != "Καλημέρα" {
+		t.Errorf("Expected Greek text, got %v (%v)", subtitles, err)
+	}
+
+	// Content without GSI block is invalid
+	if _, _, err := ReadSTL([]byte("1\n00:00:01,000 --> 00:00:02,000\nText\n")); err == nil {
+		t.Errorf("Expected error for content without GSI block")
+	}
+}
+
+func TestSCCReadWrite(t *testing.T) {
+	// Test SCC content with a pop-on caption and a roll-up caption of two rows
+	sccContent := `Scenarist_SCC V1.0
+
+00:00:00;22	9420 9420 94ae 94ae 9452 9452 97a2 97a2 c845 4c4c 4f80 942f 942f
+
+00:00:02;10	942c 942c
+
+00:01:00;02	9425 9425 94ad 94ad 9470 9470 c8e5 ecec ef80
+
+00:01:01;00	94ad 94ad 9470 9470 d7ef f2ec 6480
+
+00:01:02;00	942c 942c
+`
+	subtitles, err := ReadSCC(sccContent)
+	if err != nil {
+		t.Fatalf("Failed to parse SCC content: %v", err)
+	}
+	if len(subtitles) != 3 {
+		t.Fatalf("Expected 3 subtitles, got %d", len(subtitles))
+	}
+
+	// The pop-on caption is shown at the End Of Caption code, 11 frames after the timecode
+	if subtitles[0].Start != formatFrame2Duration(33, sccFrameRate) || subtitles[0].End != formatFrame2Duration(70, sccFrameRate) {
+		t.Errorf("Expected frames 33 --> 70, got %v --> %v", subtitles[0].Start, subtitles[0].End)
+	}
+	if !reflect.DeepEqual(subtitles[0].Text, []string{"HELLO"}) || subtitles[0].Settings.Line != "14" {
+		t.Errorf("Expected text [HELLO] on row 14, got %v on row %s", subtitles[0].Text, subtitles[0].Settings.Line)
+	}
+
+	// The roll-up caption rolls the first row up at the carriage return
+	if subtitles[1].Start != formatFrame2Duration(1800, sccFrameRate) || !reflect.DeepEqual(subtitles[1].Text, []string{"Hello"}) {
+		t.Errorf("Expected [Hello] at frame 1800, got %v at %v", subtitles[1].Text, subtitles[1].Start)
+	}
+	if !reflect.DeepEqual(subtitles[2].Text, []string{"Hello", "World"}) || subtitles[1].End != subtitles[2].Start {
+		t.Errorf("Expected [Hello World] after [Hello], got %v", subtitles[2].Text)
+	}
+
+	// Write subtitles with special characters, styling and a long line
+	sub := &models.Subtitle{Lines: []models.ModelItemSubtitle{
+		{Seq: 1, Start: 5 * time.Second, End: 6 * time.Second, Text: []string{"Señor Ángel ♪", "This line is much longer than thirty-two columns"}},
+		{Seq: 2, Start: 6500 * time.Millisecond, End: 8 * time.Second, Text: []string{"Red italic"},
+			Spans:    [][]models.ModelSpan{{{Text: "Red "}, {Text: "italic", Italic: true, Color: "#FF0000"}}},
+			Settings: models.ModelCueSettings{Align: "left", Line: "2"}},
+	}}
+	writtenContent := WriteSCC(sub)
+	if !strings.HasPrefix(writtenContent, "Scenarist_SCC V1.0\n\n") || !strings.Contains(writtenContent, "\n00:00:08;00\t942c 942c\n") {
+		t.Errorf("Unexpected written content:\n%s", writtenContent)
+	}
+
+	// Parse the content back, with the times rounded to frames
+	reparsed, err := ReadSCC(writtenContent)
+	if err != nil {
+		t.Fatalf("Failed to parse written SCC content: %v", err)
+	}
+	if len(reparsed) != 2 {
+		t.Fatalf("Expected 2 subtitles, got %d", len(reparsed))
+	}
+	for i := range reparsed {
+		start, end := reparsed[i].Start-sub.Lines[i].Start, reparsed[i].End-sub.Lines[i].End
+		if start < -20*time.Millisecond || start > 20*time.Millisecond || end < -20*time.Millisecond || end > 20*time.Millisecond {
+			t.Errorf("Expected %v --> %v, got %v --> %v", sub.Lines[i].Start, sub.Lines[i].End, reparsed[i].Start, reparsed[i].End)
+		}
+	}
+	expectedText := []string{"Señor Ángel ♪", "This line is much longer than", "thirty-two columns"}
+	if !reflect.DeepEqual(reparsed[0].Text, expectedText) {
+		t.Errorf("Expected text %q, got %q", expectedText, reparsed[0].Text)
+	}
+	if !reflect.DeepEqual(reparsed[1].Spans, sub.Lines[1].Spans) || reparsed[1].Settings.Line != "2" {
+		t.Errorf("Expected spans %v on row 2, got %v on row %s", sub.Lines[1].Spans, reparsed[1].Spans, reparsed[1].Settings.Line)
+	}
+
+	// Drop-frame timecodes skip two frames each minute, except every tenth minute
+	if tc := formatSCCTimecode(1800); tc != "00:01:00;02" {
+		t.Errorf("Expected timecode 00:01:00;02, got %s", tc)
+	}
+	if frame := parseSCCTimecode(0, 10, 0, 0, true); frame != 17982 || formatSCCTimecode(frame) != "00:10:00;00" {
+		t.Errorf("Expected frame 17982 for 00:10:00;00, got %d", frame)
+	}
+
+	// A styled line without spaces, wider than a row with its mid-row codes, is cut between characters
+	var spans []models.ModelSpan
+	for i := 0; i < 7; i++ {
+		spans = append(spans, models.ModelSpan{Text: "a", Italic: true, Color: "#FF0000"}, models.ModelSpan{Text: "b"})
+	}
+	styled := models.ModelItemSubtitle{Seq: 1, Start: 5 * time.Second, End: 6 * time.Second, Text: []string{strings.Repeat("ab", 7)}, Spans: [][]models.ModelSpan{spans}}
+	rows := formatSCCRows(&styled)
+	text := ""
+	for _, row := range rows {
+		if len(row) == 0 || sccWidth(row) > sccColumns {
+			t.Errorf("Expected rows of 1 to %d columns, got %d columns", sccColumns, sccWidth(row))
+		}
+		for _, c := range row {
+			text += string(c.char)
+		}
+	}
+	if len(rows) < 2 || text != strings.Repeat("ab", 7) {
+		t.Errorf("Expected the styled line wrapped in rows, got %d rows with %q", len(rows), text)
+	}
+	reparsed, err = ReadSCC(WriteSCC(&models.Subtitle{Lines: []models.ModelItemSubtitle{styled}}))
+	if err != nil || len(reparsed) != 1 {
+		t.Fatalf("Expected the styled line read back, got %v (%v)", reparsed, err)
+	}
+	text = ""
+	for _, line := range reparsed[0].Spans {
+		for _, span := range line {
+			text += span.Text
+		}
+	}
+	// The mid-row codes are shown as spaces
+	if strings.ReplaceAll(text, " ", "") != strings.Repeat("ab", 7) {
+		t.Errorf("Expected the styled text read back, got %q", text)
+	}
+}
+
+func TestSubViewerReadWrite(t *testing.T) {
+	// Test SubViewer 2 content with a header
+	subViewerContent := "[INFORMATION]\n[TITLE]Test\n[END INFORMATION]\n[SUBTITLE]\n[COLF]&HFFFFFF,[STYLE]no,[SIZE]18,[FONT]Arial\n" +
+		"00:00:01.00,00:00:04.50\nHello[br]World\n\n00:00:05.25,00:00:07.00\nSecond\n"
+	subtitles, err := ReadSubViewer(subViewerContent)
+	if err != nil {
+		t.Fatalf("Failed to parse SubViewer content: %v", err)
+	}
+	if len(subtitles) != 2 {
+		t.Fatalf("Expected 2 subtitles, got %d", len(subtitles))
+	}
+	if subtitles[0].Start != time.Second || subtitles[0].End != 4500*time.Millisecond || subtitles[1].Start != 5250*time.Millisecond {
+		t.Errorf("Expected 1s --> 4.5s and 5.25s, got %v --> %v and %v", subtitles[0].Start, subtitles[0].End, subtitles[1].Start)
+	}
+	if !reflect.DeepEqual(subtitles[0].Text, []string{"Hello", "World"}) {
+		t.Errorf("Expected text [Hello World], got %v", subtitles[0].Text)
+	}
+
+	// Write the subtitles back as SubViewer 2
+	writtenContent := WriteSubViewer(&models.Subtitle{Lines: subtitles})
+	if !strings.HasPrefix(writtenContent, "[INFORMATION]\n") || !strings.Contains(writtenContent, "[SUBTITLE]\n") ||
+		!strings.Contains(writtenContent, "\n00:00:01.00,00:00:04.50\nHello[br]World\n\n00:00:05.25,00:00:07.00\nSecond\n\n") {
+		t.Errorf("Unexpected written content:\n%s", writtenContent)
+	}
+
+	// Test SubViewer 1 content, where each text is shown until the next time line
+	subtitles, err = ReadSubViewer("[TITLE]\nTest\n[BEGIN]\n******** START SCRIPT ********\n[00:00:01]\nHello|World\n[00:00:04]\n\n[00:00:05]\nSecond\n[END]\n******** END SCRIPT ********\n")
+	if err != nil {
+		t.Fatalf("Failed to parse SubViewer 1 content: %v", err)
+	}
+	if len(subtitles) != 2 || subtitles[0].End != 4*time.Second || subtitles[1].End != 8*time.Second {
+		t.Errorf("Expected subtitles until 4s and 8s, got %v", subtitles)
+	}
+	if !reflect.DeepEqual(subtitles[0].Text, []string{"Hello", "World"}) {
+		t.Errorf("Expected text [Hello World], got %v", subtitles[0].Text)
+	}
+}
+
+func TestSBVReadWrite(t *testing.T) {
+	sbvContent := "0:00:01.000,0:00:04.500\nHello\nWorld\n\n0:00:05.250,0:00:07.000\nSecond\n"
+	subtitles, err := ReadSBV(sbvContent)
+	if err != nil {
+		t.Fatalf("Failed to parse SBV content: %v", err)
+	}
+	if len(subtitles) != 2 {
+		t.Fatalf("Expected 2 subtitles, got %d", len(subtitles))
+	}
+	if subtitles[0].Start != time.Second || subtitles[0].End != 4500*time.Millisecond {
+		t.Errorf("Expected 1s --> 4.5s, got %v --> %v", subtitles[0].Start, subtitles[0].End)
+	}
+	if !reflect.DeepEqual(subtitles[0].Text, []string{"Hello", "World"}) {
+		t.Errorf("Expected text [Hello World], got %v", subtitles[0].Text)
+	}
+
+	// Write the subtitles back
+	if writtenContent := WriteSBV(&models.Subtitle{Lines: subtitles}); writtenContent != sbvContent+"\n" {
+		t.Errorf("Expected content:\n%s\ngot:\n%s", sbvContent, writtenContent)
+	}
+}
+
+func TestTMPlayerReadWrite(t *testing.T) {
+	// Each line is shown until the next one, and an empty line clears the screen
+	tmplayerContent := "00:00:01:Hello|World\n00:00:04:Second\n00:00:06:\n00:00:10=Last\n"
+	subtitles, err := ReadTMPlayer(tmplayerContent)
+	if err != nil {
+		t.Fatalf("Failed to parse TMPlayer content: %v", err)
+	}
+	if len(subtitles) != 3 {
+		t.Fatalf("Expected 3 subtitles, got %d", len(subtitles))
+	}
+	if subtitles[0].End != 4*time.Second || subtitles[1].End != 6*time.Second || subtitles[2].End != 13*time.Second {
+		t.Errorf("Expected subtitles until 4s, 6s and 13s, got %v", subtitles)
+	}
+	if !reflect.DeepEqual(subtitles[0].Text, []string{"Hello", "World"}) {
+		t.Errorf("Expected text [Hello World], got %v", subtitles[0].Text)
+	}
+
+	// Write the subtitles back, rounding the times to seconds
+	subtitles[2].End = 12600 * time.Millisecond
+	expectedContent := "00:00:01:Hello|World\n00:00:04:Second\n00:00:06:\n00:00:10:Last\n00:00:13:\n"
+	if writtenContent := WriteTMPlayer(&models.Subtitle{Lines: subtitles}); writtenContent != expectedContent {
+		t.Errorf("Expected content:\n%s\ngot:\n%s", expectedContent, writtenContent)
+	}
+}
+
+func TestLRCReadWrite(t *testing.T) {
+	// Test LRC content with ID tags, an offset, repeated lines and enhanced word timing
+	lrcContent := `[ar:Artist]
+[ti:Title]
+[offset:+500]
+[00:12.50]First line
+[00:17.50][00:40.50]Chorus
+[00:21.50]<00:21.50>Word <00:22.00>by <00:22.50>word<00:23.50>
+[00:25.50]
+`
+	subtitles, metadata, err := ReadLRC(lrcContent, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse LRC content: %v", err)
+	}
+	expectedMetadata := []models.ModelInfo{{Key: "ar", Value: "Artist"}, {Key: "ti", Value: "Title"}}
+	if !reflect.DeepEqual(metadata, expectedMetadata) {
+		t.Errorf("Expected metadata %v, got %v", expectedMetadata, metadata)
+	}
+	if len(subtitles) != 4 {
+		t.Fatalf("Expected 4 subtitles, got %d", len(subtitles))
+	}
+
+	// The offset makes the lines sooner, and each line ends at the next one
+	if subtitles[0].Start != 12*time.Second || subtitles[0].End != 17*time.Second {
+		t.Errorf("Expected 12s --> 17s, got %v --> %v", subtitles[0].Start, subtitles[0].End)
+	}
+	if subtitles[3].Text[0] != "Chorus" || subtitles[3].Start != 40*time.Second || subtitles[3].End != 50*time.Second {
+		t.Errorf("Expected repeated Chorus at 40s for at most 10s, got %v", subtitles[3])
+	}
+	expectedWords := []models.ModelWord{
+		{Text: "Word", Start: 21 * time.Second, End: 21500 * time.Millisecond},
+		{Text: "by", Start: 21500 * time.Millisecond, End: 22 * time.Second},
+		{Text: "word", Start: 22 * time.Second, End: 23 * time.Second},
+	}
+	if subtitles[2].Text[0] != "Word by word" || !reflect.DeepEqual(subtitles[2].Words, expectedWords) {
+		t.Errorf("Expected words %v, got %q %v", expectedWords, subtitles[2].Text[0], subtitles[2].Words)
+	}
+
+	// The maximum duration is configurable
+	if subtitles, _, _ := ReadLRC(lrcContent, 2*time.Second); subtitles[0].End != 14*time.Second {
+		t.Errorf("Expected end 14s with a maximum of 2s, got %v", subtitles[0].End)
+	}
+
+	// Write the lyrics back, with the word timing
+	writtenContent := WriteLRC(&models.Subtitle{Lines: subtitles, Metadata: metadata})
+	expectedContent := "[ar:Artist]\n[ti:Title]\n[00:12.00]First line\n[00:17.00]Chorus\n[00:21.00]<00:21.00>Word <00:21.50>by <00:22.00>word<00:23.00>\n[00:25.00]\n[00:40.00]Chorus\n[00:50.00]\n"
+	if writtenContent != expectedContent {
+		t.Errorf("Expected content:\n%s\ngot:\n%s", expectedContent, writtenContent)
+	}
+
+	// The content with only [mm:ss:xx] or [mm:ss] time tags is detected as LRC
+	for content, start := range map[string]time.Duration{
+		"[00:12:50]First line\n[00:17:50]Second line\n": 12500 * time.Millisecond,
+		"[00:12]First line\n[00:17]Second line\n":       12 * time.Second,
+	} {
+		if formats := Detect(content); len(formats) == 0 || formats[0].Name() != "LRC" {
+			t.Errorf("Expected %q detected as LRC, got %v", content, formats)
+		}
+		if subtitles, _, err := ReadLRC(content, 0); err != nil || len(subtitles) != 2 || subtitles[0].Start != start {
+			t.Errorf("Expected %q starting at %v, got %v (%v)", content, start, subtitles, err)
+		}
+	}
+
+	// SSA karaoke tags are written as word timing
+	assContent := "[Script Info]\nScriptType: v4.00+\n\n[Events]\nFormat: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n" +
+		"Dialogue: 0,0:00:01.00,0:00:03.00,Default,,0,0,0,,{\\k50}Ka{\\k30}ra{\\k20}{\\k40}oke\n"
+	karaoke, err := ReadASS(assContent)
+	if err != nil {
+		t.Fatalf("Failed to parse ASS content: %v", err)
+	}
+	writtenContent = WriteLRC(&models.Subtitle{Lines: karaoke})
+	if writtenContent != "[00:01.00]<00:01.00>Ka<00:01.50>ra<00:02.00>oke<00:02.40>\n[00:03.00]\n" {
+		t.Errorf("Unexpected karaoke content:\n%s", writtenContent)
+	}
+}
+
+// TestJSONReadWrite tests the JSON format reading and writing functions
+func TestJSONReadWrite(t *testing.T) {
+	// Test JSON content with optional fields, times in milliseconds and a missing seq
+	jsonContent := `{
+  "language": "en",
+  "metadata": [{"key": "ti", "value": "Title"}],
+  "lines": [
+    {"seq": 1, "start": 1000, "end": 4000.5, "text": ["Hello", "World"], "style": "Default", "speaker": "Alice",
+     "spans": [[{"text": "Hello", "italic": true}], [{"text": "World"}]],
+     "words": [{"text": "Hello", "start": 1000, "end": 2000}, {"text": "World", "start": 2000, "end": 4000}]},
+    {"start": 5000, "end": 6000, "text": ["Bye"], "settings": {"align": "start"}}
+  ]
+}`
+	sub, err := ReadJSON(jsonContent)
+	if err != nil {
+		t.Fatalf("Failed to parse JSON content: %v", err)
+	}
+	if sub.Language != "en" || !reflect.DeepEqual(sub.Metadata, []models.ModelInfo{{Key: "ti", Value: "Title"}}) {
+		t.Errorf("Unexpected language %q or metadata %v", sub.Language, sub.Metadata)
+	}
+	if len(sub.Lines) != 2 {
+		t.Fatalf("Expected 2 subtitles, got %d", len(sub.Lines))
+	}
+	first := sub.Lines[0]
+	if first.Start != time.Second || first.End != 4000500*time.Microsecond || first.Actor != "Alice" || first.Style != "Default" {
+		t.Errorf("Unexpected first subtitle: %+v", first)
+	}
+	if !first.Spans[0][0].Italic || len(first.Words) != 2 || first.Words[1].End != 4*time.Second {
+		t.Errorf("Unexpected spans %v or words %v", first.Spans, first.Words)
+	}
+	if sub.Lines[1].Seq != 2 || sub.Lines[1].Settings.Align != "start" {
+		t.Errorf("Expected seq 2 aligned at start, got %+v", sub.Lines[1])
+	}
+
+	// Write the content back and read it again
+	writtenContent := WriteJSON(&sub)
+	if !strings.Contains(writtenContent, `"start": 1000,`) || !strings.Contains(writtenContent, `"end": 4000.5,`) ||
+		!strings.Contains(writtenContent, `"speaker": "Alice"`) {
+		t.Errorf("Unexpected content:\n%s", writtenContent)
+	}
+	again, err := ReadJSON(writtenContent)
+	if err != nil || !reflect.DeepEqual(again.Lines, sub.Lines) {
+		t.Errorf("Expected the same subtitles after writing, got %v (%v)", again.Lines, err)
+	}
+
+	// Syntax errors have the position of the problem
+	_, err = ReadJSON("{\n  \"lines\": [\n    {\"start\": 1000,}\n  ]\n}")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != 3 {
+		t.Errorf("Expected a parse error at line 3, got %v", err)
+	}
+
+	// Times out of the range of a time.Duration are skipped with a warning
+	sub, warnings, err := readJSON(`{"lines": [{"start": 1e300, "end": 2000, "text": ["Big"]}, {"start": 3000, "end": 4000, "text": ["Ok"], "words": [{"text": "Ok", "start": -1e300, "end": 4000}]}]}`)
+	if err != nil || len(sub.Lines) != 1 || sub.Lines[0].Text[0] != "Ok" || len(sub.Lines[0].Words) != 0 || len(warnings) != 1 {
+		t.Errorf("Expected the cue with an invalid time skipped with a warning, got %v %v (%v)", sub.Lines, warnings, err)
+	}
+}
+
+// TestCSVReadWrite tests the CSV format reading and writing functions
+func TestCSVReadWrite(t *testing.T) {
+	// Test CSV content with a header in another order and a multi-line text
+	csvContent := `text,speaker,start,end
+"Hello, world
+Second line",Alice,00:00:01.000,00:00:04.000
+Bye,Bob,5000,6500
+`
+	subtitles, columns, err := ReadCSV(csvContent, nil)
+	if err != nil {
+		t.Fatalf("Failed to parse CSV content: %v", err)
+	}
+	if !reflect.DeepEqual(columns, []string{"text", "speaker", "start", "end"}) {
+		t.Errorf("Unexpected columns %v", columns)
+	}
+	expected := []models.ModelItemSubtitle{
+		{Seq: 1, Start: time.Second, End: 4 * time.Second, Text: []string{"Hello, world", "Second line"}, Actor: "Alice"},
+		{Seq: 2, Start: 5 * time.Second, End: 6500 * time.Millisecond, Text: []string{"Bye"}, Actor: "Bob"},
+	}
+	if !reflect.DeepEqual(subtitles, expected) {
+		t.Errorf("Expected %v, got %v", expected, subtitles)
+	}
+
+	// The unknown columns of the header are ignored
+	csvContent = "start,end,speaker,text,notes\n00:00:01.000,00:00:02.000,Alice,Hi,check\n"
+	if probe := (csvFormat{}).Probe(csvContent); probe != 0.9 {
+		t.Errorf("Expected probe 0.9 with an unknown column, got %v", probe)
+	}
+	subtitles, columns, err = ReadCSV(csvContent, nil)
+	if err != nil || len(subtitles) != 1 || subtitles[0].Text[0] != "Hi" || subtitles[0].Actor != "Alice" || len(columns) != 5 {
+		t.Errorf("Unexpected subtitles with an unknown column: %v %v (%v)", subtitles, columns, err)
+	}
+
+	// Without header, the columns are given, and the subtitles end at the next one
+	subtitles, _, err = ReadCSV("00:00:01.000;First\n00:00:03.000;Second\n", []string{"start", "text"})
+	if err != nil || len(subtitles) != 2 || subtitles[0].End != 3*time.Second || subtitles[1].Text[0] != "Second" {
+		t.Errorf("Unexpected subtitles without header: %v (%v)", subtitles, err)
+	}
+
+	// Infinite and out of range times are invalid start times
+	for _, start := range []string{"Inf", "NaN", "1e300"} {
+		subtitles, _, warnings, err := readCSV(start+",2000,Bob,hi\n00:00:03.000,00:00:04.000,Bob,ok\n", nil)
+		if err != nil || len(subtitles) != 1 || subtitles[0].Text[0] != "ok" || len(warnings) != 1 || !strings.Contains(warnings[0].Error(), "invalid start time") {
+			t.Errorf("Expected the row starting at %s skipped with a warning, got %v %v (%v)", start, subtitles, warnings, err)
+		}
+	}
+
+	// Write with the default and the configured columns
+	writtenContent := WriteCSV(&models.Subtitle{Lines: expected})
+	expectedContent := "start,end,speaker,text\n00:00:01.000,00:00:04.000,Alice,\"Hello, world\nSecond line\"\n00:00:05.000,00:00:06.500,Bob,Bye\n"
+	if writtenContent != expectedContent {
+		t.Errorf("Expected content:\n%s\ngot:\n%s", expectedContent, writtenContent)
+	}
+	writtenContent = WriteCSV(&models.Subtitle{Lines: expected, CSVColumns: []string{"seq", "text"}})
+	if writtenContent != "seq,text\n1,\"Hello, world\nSecond line\"\n2,Bye\n" {
+		t.Errorf("Unexpected content with columns:\n%s", writtenContent)
+	}
+}
+
+// TestWhisperReadWrite tests the speech recognition transcripts reading and writing functions
+func TestWhisperReadWrite(t *testing.T) {
+	// Test OpenAI Whisper content with word timestamps
+	whisperContent := `{
+  "language": "en",
+  "text": " Hello there. How are you today?",
+  "segments": [
+    {"id": 0, "start": 1.0, "end": 4.0, "text": " Hello there. How are you today?", "words": [
+      {"word": " Hello", "start": 1.0, "end": 1.4, "probability": 0.9},
+      {"word": " there.", "start": 1.4, "end": 2.0, "probability": 0.8},
+      {"word": " How", "start": 2.2, "end": 2.5, "probability": 0.95},
+      {"word": " are", "start": 2.5, "end": 2.7, "probability": 0.99},
+      {"word": " you", "start": 2.7, "end": 3.0, "probability": 0.99},
+      {"word": " today?", "start": 3.0, "end": 4.0, "probability": 0.97}
+    ]}
+  ]
+}`
+	subtitles, language, err := ReadWhisper(whisperContent, models.Segmentation{})
+	if err != nil {
+		t.Fatalf("Failed to parse Whisper content: %v", err)
+	}
+	if language != "en" || len(subtitles) != 1 || subtitles[0].Start != time.Second || subtitles[0].End != 4*time.Second {
+		t.Fatalf("Unexpected language %q or subtitles %v", language, subtitles)
+	}
+	if len(subtitles[0].Words) != 6 || subtitles[0].Words[1] != (models.ModelWord{Text: "there.", Start: 1400 * time.Millisecond, End: 2 * time.Second, Confidence: 0.8}) {
+		t.Errorf("Unexpected words %v", subtitles[0].Words)
+	}
+
+	// Re-segment the words at the end of the sentences and at the maximum characters
+	subtitles, _, _ = ReadWhisper(whisperContent, models.Segmentation{MaxChars: 12, Punctuation: true})
+	var texts []string
+	for _, item := range subtitles {
+		texts = append(texts, item.Text[0])
+	}
+	if !reflect.DeepEqual(texts, []string{"Hello there.", "How are you", "today?"}) {
+		t.Errorf("Unexpected segmentation %q", texts)
+	}
+	if subtitles[1].Start != 2200*time.Millisecond || subtitles[1].End != 3*time.Second || len(subtitles[1].Words) != 3 {
+		t.Errorf("Unexpected second subtitle %v", subtitles[1])
+	}
+
+	// The words are written as LRC word timing
+	writtenContent := WriteLRC(&models.Subtitle{Lines: subtitles[:1]})
+	if writtenContent != "[00:01.00]<00:01.00>Hello <00:01.40>there.\n[00:02.00]\n" {
+		t.Errorf("Unexpected LRC content:\n%s", writtenContent)
+	}
+
+	// whisper.cpp tokens are joined into words, and the special tokens are skipped
+	cppContent := `{"result": {"language": "es"}, "transcription": [
+  {"offsets": {"from": 500, "to": 2000}, "text": " Hola mundo.", "tokens": [
+    {"text": "[_BEG_]", "offsets": {"from": 500, "to": 500}, "p": 1.0},
+    {"text": " Hola", "offsets": {"from": 500, "to": 900}, "p": 0.9},
+    {"text": " mun", "offsets": {"from": 1000, "to": 1400}, "p": 0.5},
+    {"text": "do", "offsets": {"from": 1400, "to": 1800}, "p": 0.75},
+    {"text": ".", "offsets": {"from": 1800, "to": 2000}, "p": 1.0}
+  ]}
+]}`
+	subtitles, language, err = ReadWhisper(cppContent, models.Segmentation{})
+	if err != nil || language != "es" || len(subtitles) != 1 || subtitles[0].Text[0] != "Hola mundo." {
+		t.Fatalf("Unexpected whisper.cpp subtitles %v (%q, %v)", subtitles, language, err)
+	}
+	if words := subtitles[0].Words; len(words) != 2 || words[1].Text != "mundo." || words[1].Start != time.Second || words[1].End != 2*time.Second || words[1].Confidence != 0.75 {
+		t.Errorf("Unexpected whisper.cpp words %v", words)
+	}
+
+	// Vosk utterances, the empty ones are skipped
+	voskContent := `[{"result": [{"conf": 1.0, "start": 0.5, "end": 0.9, "word": "hello"}, {"conf": 0.7, "start": 1.0, "end": 1.5, "word": "world"}], "text": "hello world"}, {"text": ""}]`
+	subtitles, _, err = ReadWhisper(voskContent, models.Segmentation{})
+	if err != nil || len(subtitles) != 1 || subtitles[0].Text[0] != "hello world" || subtitles[0].Start != 500*time.Millisecond || subtitles[0].End != 1500*time.Millisecond {
+		t.Errorf("Unexpected Vosk subtitles %v (%v)", subtitles, err)
+	}
+
+	// The segments with a time out of the range of a time.Duration are skipped with a warning
+	subtitles, _, warnings, err := readWhisper(`{"segments": [{"start": 1e300, "end": 2.0, "text": "Big"}, {"start": 3.0, "end": 4.0, "text": "Ok"}]}`, models.Segmentation{})
+	if err != nil || len(subtitles) != 1 || subtitles[0].Text[0] != "Ok" || len(warnings) != 1 {
+		t.Errorf("Expected the segment with an invalid time skipped with a warning, got %v %v (%v)", subtitles, warnings, err)
+	}
+
+	// Write the subtitles back as Whisper content
+	writtenContent = WriteWhisper(&models.Subtitle{Language: "en", Lines: subtitles})
+	again, _, err := ReadWhisper(writtenContent, models.Segmentation{})
+	if err != nil || !reflect.DeepEqual(again, subtitles) {
+		t.Errorf("Expected the same subtitles after writing, got %v (%v)\n%s", again, err, writtenContent)
+	}
+}
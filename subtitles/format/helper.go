@@ -22,12 +22,17 @@ func cleanText(text string) string {
 	text = strings.TrimPrefix(text, "\xef\xbb\xbf")
 	
 	// Remove other potential Unicode marks
-	text = strings.Map(func(r rune) rune {
+	text = cleanControl(text)
+	
+	return strings.TrimSpace(text)
+}
+
+// cleanControl removes the control characters from text, except the line breaks.
+func cleanControl(text string) string {
+	return strings.Map(func(r rune) rune {
 		if unicode.IsControl(r) && r != '\n' && r != '\r' {
 			return -1
 		}
 		return r
 	}, text)
-	
-	return strings.TrimSpace(text)
 }
@@ -0,0 +1,88 @@
+package format
+
+import (
+	"golang.org/x/text/unicode/norm"
+)
+
+/*
+ISO 6937 Character Code Table (Latin alphabet of EBU STL):
+
+0x20-0x7E  ASCII characters
+0xA0-0xBF  Symbols and punctuation (e.g., 0xA3 £, 0xAB «, 0xBB »)
+0xC1-0xCF  Non-spacing diacritical marks, written before the letter they modify
+           (e.g., 0xC2 0x65 is é)
+0xD0-0xFF  Symbols and letters (e.g., 0xE9 Ø, 0xFB ß)
+*/
+
+// iso6937Chars maps the characters 0xA0-0xFF of ISO 6937 that are not diacritical marks.
+var iso6937Chars = map[byte]rune{
+	0xA0: '\u00A0', 0xA1: '¡', 0xA2: '¢', 0xA3: '£', 0xA4: '$', 0xA5: '¥', 0xA6: '#', 0xA7: '§',
+	0xA8: '¤', 0xA9: '‘', 0xAA: '“', 0xAB: '«', 0xAC: '←', 0xAD: '↑', 0xAE: '→', 0xAF: '↓',
+	0xB0: '°', 0xB1: '±', 0xB2: '²', 0xB3: '³', 0xB4: '×', 0xB5: 'µ', 0xB6: '¶', 0xB7: '·',
+	0xB8: '÷', 0xB9: '’', 0xBA: '”', 0xBB: '»', 0xBC: '¼', 0xBD: '½', 0xBE: '¾', 0xBF: '¿',
+	0xD0: '―', 0xD1: '¹', 0xD2: '®', 0xD3: '©', 0xD4: '™', 0xD5: '♪', 0xD6: '¬', 0xD7: '¦',
+	0xDC: '⅛', 0xDD: '⅜', 0xDE: '⅝', 0xDF: '⅞',
+	0xE0: 'Ω', 0xE1: 'Æ', 0xE2: 'Đ', 0xE3: 'ª', 0xE4: 'Ħ', 0xE6: 'Ĳ', 0xE7: 'Ŀ',
+	0xE8: 'Ł', 0xE9: 'Ø', 0xEA: 'Œ', 0xEB: 'º', 0xEC: 'Þ', 0xED: 'Ŧ', 0xEE: 'Ŋ', 0xEF: 'ŉ',
+	0xF0: 'ĸ', 0xF1: 'æ', 0xF2: 'đ', 0xF3: 'ð', 0xF4: 'ħ', 0xF5: 'ı', 0xF6: 'ĳ', 0xF7: 'ŀ',
+	0xF8: 'ł', 0xF9: 'ø', 0xFA: 'œ', 0xFB: 'ß', 0xFC: 'þ', 0xFD: 'ŧ', 0xFE: 'ŋ', 0xFF: '\u00AD',
+}
+
+// iso6937Marks maps the non-spacing diacritical marks 0xC1-0xCF of ISO 6937 to the
+// Unicode combining characters.
+var iso6937Marks = map[byte]rune{
+	0xC1: '\u0300', // grave
+	0xC2: '\u0301', // acute
+	0xC3: '\u0302', // circumflex
+	0xC4: '\u0303', // tilde
+	0xC5: '\u0304', // macron
+	0xC6: '\u0306', // breve
+	0xC7: '\u0307', // dot above
+	0xC8: '\u0308', // diaeresis
+	0xCA: '\u030A', // ring above
+	0xCB: '\u0327', // cedilla
+	0xCD: '\u030B', // double acute
+	0xCE: '\u0328', // ogonek
+	0xCF: '\u030C', // caron
+}
+
+// decodeISO6937 converts a character of ISO 6937 to UTF-8: the mark is the previous
+// byte when it is a diacritical mark, or 0. Returns an empty string for unused bytes.
+func decodeISO6937(mark byte, b byte) string {
+	var r rune
+	if b >= 0x20 && b < 0x7F {
+		r = rune(b)
+	} else if c, ok := iso6937Chars[b]; ok {
+		r = c
+	} else {
+		return ""
+	}
+	if combining, ok := iso6937Marks[mark]; ok {
+		return norm.NFC.String(string(r) + string(combining))
+	}
+	return string(r)
+}
+
+// encodeISO6937 converts a character to ISO 6937, with a diacritical mark before the
+// letter for the accented letters. Returns false if the character can't be represented.
+func encodeISO6937(r rune) ([]byte, bool) {
+	if r >= 0x20 && r < 0x7F {
+		return []byte{byte(r)}, true
+	}
+	for b, c := range iso6937Chars {
+		if c == r {
+			return []byte{b}, true
+		}
+	}
+
+	// Accented letters are a mark and a letter
+	decomposed := []rune(norm.NFD.String(string(r)))
+	if len(decomposed) == 2 && decomposed[0] < 0x7F {
+		for mark, combining := range iso6937Marks {
+			if combining == decomposed[1] {
+				return []byte{mark, byte(decomposed[0])}, true
+			}
+		}
+	}
+	return nil, false
+}
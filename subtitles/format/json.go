@@ -0,0 +1,326 @@
+package format
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+)
+
+/*
+JSON Format Specification:
+
+A JSON object mirroring models.Subtitle, for data pipelines and tools working with JSON.
+The times are in milliseconds (with a fraction for sub-millisecond times), and the text is
+an array of lines. Only "lines" with "start", "end" and "text" are required; the optional
+fields are omitted when empty.
+
+	language    Language of the text as a BCP 47 tag
+	frame_rate  Frames per second of the source, if known
+	metadata    Array of {"key", "value"} tags (e.g., LRC artist and title)
+	lines       Array of subtitles:
+	  seq       Sequence number (the position in the array if missing)
+	  id        Cue identifier
+	  start     Start time in milliseconds
+	  end       End time in milliseconds
+	  text      Array of lines of text
+	  style     Name of the style
+	  speaker   Name of the character speaking (the Actor of SSA/ASS)
+	  settings  Positioning settings: vertical, line, position, size, align and region
+	  spans     Array of styled runs of each line: text, italic, bold, underline,
+	            strikeout, color, font and double_height
+	  words     Array of timed words: text, start, end and confidence (between 0 and 1)
+
+Example JSON Format:
+{
+  "language": "en",
+  "lines": [
+    {
+      "seq": 1,
+      "start": 137440,
+      "end": 140375,
+      "text": [
+        "Senator, we're making",
+        "our final approach into Coruscant."
+      ],
+      "speaker": "Captain"
+    }
+  ]
+}
+*/
+
+// jsonSubtitle is the JSON document of a subtitle.
+type jsonSubtitle struct {
+	Language  string             `json:"language,omitempty"`
+	FrameRate float64            `json:"frame_rate,omitempty"`
+	Metadata  []jsonInfo         `json:"metadata,omitempty"`
+	Lines     []jsonItemSubtitle `json:"lines"`
+}
+
+// jsonInfo is a metadata tag of a JSON subtitle.
+type jsonInfo struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// jsonItemSubtitle is a subtitle of a JSON subtitle.
+type jsonItemSubtitle struct {
+	Seq      int              `json:"seq,omitempty"`
+	ID       string           `json:"id,omitempty"`
+	Start    float64          `json:"start"`
+	End      float64          `json:"end"`
+	Text     []string         `json:"text"`
+	Style    string           `json:"style,omitempty"`
+	Speaker  string           `json:"speaker,omitempty"`
+	Settings *jsonCueSettings `json:"settings,omitempty"`
+	Spans    [][]jsonSpan     `json:"spans,omitempty"`
+	Words    []jsonWord       `json:"words,omitempty"`
+}
+
+// jsonCueSettings are the positioning settings of a JSON subtitle.
+type jsonCueSettings struct {
+	Vertical string `json:"vertical,omitempty"`
+	Line     string `json:"line,omitempty"`
+	Position string `json:"position,omitempty"`
+	Size     string `json:"size,omitempty"`
+	Align    string `json:"align,omitempty"`
+	Region   string `json:"region,omitempty"`
+}
+
+// jsonSpan is a styled run of a line of a JSON subtitle.
+type jsonSpan struct {
+	Text         string `json:"text"`
+	Italic       bool   `json:"italic,omitempty"`
+	Bold         bool   `json:"bold,omitempty"`
+	Underline    bool   `json:"underline,omitempty"`
+	StrikeOut    bool   `json:"strikeout,omitempty"`
+	Color        string `json:"color,omitempty"`
+	Font         string `json:"font,omitempty"`
+	DoubleHeight bool   `json:"double_height,omitempty"`
+}
+
+// jsonWord is a timed word of a JSON subtitle.
+type jsonWord struct {
+	Text       string  `json:"text"`
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Confidence float64 `json:"confidence,omitempty"`
+}
+
+// ReadJSON parses JSON formatted subtitle content and converts it to the internal model,
+// with the language, frame rate and metadata of the document.
+// Returns a *ParseError if the content is not a valid JSON subtitle.
+func ReadJSON(content string) (sub models.Subtitle, err error) {
+	sub, _, err = readJSON(content)
+	return sub, err
+}
+
+// readJSON parses JSON formatted subtitle content like ReadJSON, and also returns the problems
+// found in the subtitles as warnings.
+func readJSON(content string) (sub models.Subtitle, warnings []error, err error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+
+	var doc jsonSubtitle
+	if err = json.Unmarshal([]byte(content), &doc); err != nil {
+		return sub, nil, jsonParseError("JSON", content, err)
+	}
+
+	sub.Language = doc.Language
+	sub.FrameRate = doc.FrameRate
+	for _, info := range doc.Metadata {
+		sub.Metadata = append(sub.Metadata, models.ModelInfo{Key: info.Key, Value: info.Value})
+	}
+	for i, line := range doc.Lines {
+		start, okStart := parseMilliseconds(line.Start)
+		end, okEnd := parseMilliseconds(line.End)
+		if !okStart || !okEnd {
+			warnings = append(warnings, &ParseError{Format: "JSON", Cue: i + 1, Reason: fmt.Sprintf("cue %d has an invalid time", i+1)})
+			continue
+		}
+		item := models.ModelItemSubtitle{
+			Seq:   line.Seq,
+			ID:    line.ID,
+			Start: start,
+			End:   end,
+			Text:  line.Text,
+			Style: line.Style,
+			Actor: line.Speaker,
+		}
+		if item.Seq == 0 {
+			item.Seq = i + 1
+		}
+		if line.Settings != nil {
+			item.Settings = models.ModelCueSettings(*line.Settings)
+		}
+		for _, spans := range line.Spans {
+			var runs []models.ModelSpan
+			for _, span := range spans {
+				runs = append(runs, models.ModelSpan(span))
+			}
+			item.Spans = append(item.Spans, runs)
+		}
+		for _, word := range line.Words {
+			// The words with an invalid time are skipped
+			wordStart, okStart := parseMilliseconds(word.Start)
+			wordEnd, okEnd := parseMilliseconds(word.End)
+			if okStart && okEnd {
+				item.Words = append(item.Words, models.ModelWord{Text: word.Text, Start: wordStart, End: wordEnd, Confidence: word.Confidence})
+			}
+		}
+
+		// Check the subtitle, it is kept anyway
+		if len(item.Text) == 0 {
+			warnings = append(warnings, &ParseError{Format: "JSON", Cue: i + 1, Reason: fmt.Sprintf("cue %d has no text", i+1)})
+		}
+		if item.End < item.Start {
+			warnings = append(warnings, &ParseError{Format: "JSON", Cue: i + 1, Reason: "end time before start time"})
+		}
+		if len(sub.Lines) > 0 && item.Start < sub.Lines[len(sub.Lines)-1].Start {
+			warnings = append(warnings, &ParseError{Format: "JSON", Cue: i + 1, Reason: "timestamp out of order"})
+		}
+		sub.Lines = append(sub.Lines, item)
+	}
+
+	// If no subtitles were found, return an error
+	if len(sub.Lines) == 0 {
+		return sub, warnings, &ParseError{Format: "JSON", Reason: "Invalid JSON: no subtitles found"}
+	}
+
+	return sub, warnings, nil
+}
+
+// jsonParseError converts an error decoding JSON content to a *ParseError, with the line and
+// column of the problem when known.
+func jsonParseError(format string, content string, err error) error {
+	ret := &ParseError{Format: format, Reason: "Invalid " + format + ": " + err.Error()}
+
+	var offset int64 = -1
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) {
+		offset = syntaxErr.Offset
+	} else if errors.As(err, &typeErr) {
+		offset = typeErr.Offset
+	}
+	if offset >= 0 && offset <= int64(len(content)) {
+		before := content[:offset]
+		ret.Line = strings.Count(before, "\n") + 1
+		ret.Column = len(before) - strings.LastIndex(before, "\n")
+	}
+	return ret
+}
+
+// maxMilliseconds is the largest number of milliseconds of a time.Duration.
+const maxMilliseconds = float64(math.MaxInt64 / int64(time.Millisecond))
+
+// parseMilliseconds converts a number of milliseconds to a time.Duration.
+// Reports whether the number is valid: finite and within the range of a time.Duration.
+func parseMilliseconds(ms float64) (time.Duration, bool) {
+	if math.IsNaN(ms) || math.Abs(ms) > maxMilliseconds {
+		return 0, false
+	}
+	return time.Duration(math.Round(ms * float64(time.Millisecond))), true
+}
+
+// formatDuration2Milliseconds converts a time.Duration to a number of milliseconds.
+func formatDuration2Milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// WriteJSON converts subtitle data from the internal model to JSON formatted content,
+// indented with two spaces.
+func WriteJSON(sub *models.Subtitle) (content string) {
+	doc := jsonSubtitle{Language: sub.Language, FrameRate: sub.FrameRate, Lines: []jsonItemSubtitle{}}
+	for _, info := range sub.Metadata {
+		doc.Metadata = append(doc.Metadata, jsonInfo{Key: info.Key, Value: info.Value})
+	}
+	for i := range sub.Lines {
+		item := &sub.Lines[i]
+		line := jsonItemSubtitle{
+			Seq:     item.Seq,
+			ID:      item.ID,
+			Start:   formatDuration2Milliseconds(item.Start),
+			End:     formatDuration2Milliseconds(item.End),
+			Text:    item.Text,
+			Style:   item.Style,
+			Speaker: item.Actor,
+		}
+		if line.Text == nil {
+			line.Text = []string{}
+		}
+		if item.Settings != (models.ModelCueSettings{}) {
+			settings := jsonCueSettings(item.Settings)
+			line.Settings = &settings
+		}
+		for _, spans := range item.Spans {
+			runs := []jsonSpan{}
+			for _, span := range spans {
+				runs = append(runs, jsonSpan(span))
+			}
+			line.Spans = append(line.Spans, runs)
+		}
+		for _, word := range item.Words {
+			line.Words = append(line.Words, jsonWord{Text: word.Text, Start: formatDuration2Milliseconds(word.Start), End: formatDuration2Milliseconds(word.End), Confidence: word.Confidence})
+		}
+		doc.Lines = append(doc.Lines, line)
+	}
+
+	raw, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return ""
+	}
+	return string(raw) + "\n"
+}
+
+// jsonFormat implements the Format interface for JSON.
+type jsonFormat struct{}
+
+func init() {
+	Register(jsonFormat{})
+}
+
+// Name returns the name of the JSON format.
+func (jsonFormat) Name() string { return "JSON" }
+
+// Extensions returns the file extensions of the JSON format.
+func (jsonFormat) Extensions() []string { return []string{".json"} }
+
+// Probe returns the confidence that the content is a JSON subtitle: a JSON object with a
+// "lines" array.
+func (jsonFormat) Probe(content string) float64 {
+	content = strings.TrimSpace(strings.TrimPrefix(content, "\ufeff"))
+	if !strings.HasPrefix(content, "{") {
+		return 0
+	}
+	var doc struct {
+		Lines []json.RawMessage `json:"lines"`
+	}
+	if json.Unmarshal([]byte(content), &doc) != nil || doc.Lines == nil {
+		return 0
+	}
+	return 0.9
+}
+
+// Read parses JSON content into sub, with the language, frame rate and metadata of the document.
+func (jsonFormat) Read(content string, sub *models.Subtitle) (err error) {
+	doc, warnings, err := readJSON(content)
+	if err != nil {
+		sub.Warnings = warnings
+		return err
+	}
+	sub.Lines, sub.Metadata, sub.Warnings = doc.Lines, doc.Metadata, warnings
+	if len(doc.Language) > 0 {
+		sub.Language = doc.Language
+	}
+	if doc.FrameRate > 0 {
+		sub.FrameRate = doc.FrameRate
+	}
+	return nil
+}
+
+// Write converts the subtitle to JSON content.
+func (jsonFormat) Write(sub *models.Subtitle) string { return WriteJSON(sub) }
@@ -0,0 +1,256 @@
+package format
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+)
+
+/*
+LRC Format Specification:
+
+Regular Expression for lyrics line validation:
+^((?:\[\d+:\d{1,2}(?:[.:]\d{1,3})?\])+)(.*)$
+
+Each line has one or more [mm:ss.xx] time tags and is shown until the next line; a line
+without text clears the screen. ID tags ([ar:Artist], [ti:Title], [al:Album], [by:Author],
+[offset:+500], ...) hold the metadata; the offset in milliseconds makes the lyrics appear
+sooner. The enhanced format adds a <mm:ss.xx> time tag before each word, and optionally
+one at the end of the last word.
+
+Example LRC Format:
+[ar:Artist]
+[ti:Title]
+[00:12.00]Line one of the lyrics
+[00:17.20][01:17.20]Chorus line
+[00:21.10]<00:21.10>Enhanced <00:21.60>word <00:22.00>timing<00:23.00>
+[00:25.00]
+*/
+
+// lrcMaxDuration is the maximum duration of the lines when the MaxDuration of the subtitle is zero.
+const lrcMaxDuration = 10 * time.Second
+
+// ReadLRC parses LRC formatted lyrics and converts them to the internal model, also returning
+// the ID tags as metadata. Each line ends at the time of the next line, or after maxDuration
+// (10 seconds if zero) when that is sooner. The offset tag is applied to the times, and the
+// enhanced word time tags are kept in Words.
+// Returns a *ParseError if the content is not a valid LRC format.
+func ReadLRC(content string, maxDuration time.Duration) (ret []models.ModelItemSubtitle, metadata []models.ModelInfo, err error) {
+	ret, metadata, _, err = readLRC(content, maxDuration)
+	return ret, metadata, err
+}
+
+// readLRC parses LRC formatted lyrics like ReadLRC, and also returns the problems found in the
+// lines that were parsed or skipped as warnings.
+func readLRC(content string, maxDuration time.Duration) (ret []models.ModelItemSubtitle, metadata []models.ModelInfo, warnings []error, err error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+	if maxDuration <= 0 {
+		maxDuration = lrcMaxDuration
+	}
+
+	lineExp := regexp.MustCompile(`^((?:\[\d+:\d{1,2}(?:[.:]\d{1,3})?\])+)(.*)$`)
+	timeExp := regexp.MustCompile(`\[(\d+):(\d{1,2})(?:[.:](\d{1,3}))?\]`)
+	tagExp := regexp.MustCompile(`^\[([A-Za-z#]+):(.*)\]$`)
+	var texts []timedText
+	var offset time.Duration
+	for i, line := range strings.Split(content, "\n") {
+		line = cleanText(line)
+		if line == "" {
+			continue
+		}
+
+		// ID tags, the offset is applied to the times
+		if res := tagExp.FindStringSubmatch(line); len(res) == 3 {
+			if strings.EqualFold(res[1], "offset") {
+				ms, errOffset := strconv.Atoi(strings.TrimSpace(res[2]))
+				if errOffset != nil {
+					warnings = append(warnings, &ParseError{Format: "LRC", Line: i + 1, Column: 1, Text: line, Reason: "invalid offset"})
+				}
+				offset = time.Duration(ms) * time.Millisecond
+				continue
+			}
+			metadata = append(metadata, models.ModelInfo{Key: res[1], Value: strings.TrimSpace(res[2])})
+			continue
+		}
+
+		res := lineExp.FindStringSubmatch(line)
+		if len(res) != 3 {
+			warnings = append(warnings, &ParseError{Format: "LRC", Line: i + 1, Column: 1, Cue: len(texts) + 1, Text: line, Reason: "unexpected line, expected [mm:ss.xx]text"})
+			continue
+		}
+
+		// A line with several time tags is repeated at each time, with the words moved along
+		text, words := parseLRCText(res[2])
+		var first time.Duration
+		for k, tag := range timeExp.FindAllStringSubmatch(res[1], -1) {
+			start := parseTimedBlockTime([]string{"0", tag[1], tag[2], tag[3]})
+			if k == 0 {
+				first = start
+			}
+			var moved []models.ModelWord
+			for _, word := range words {
+				word.Start += start - first
+				if word.End > 0 {
+					word.End += start - first
+				}
+				moved = append(moved, word)
+			}
+			texts = append(texts, timedText{line: i + 1, start: start, raw: line, text: []string{text}, words: moved})
+		}
+	}
+
+	// Apply the offset and sort the lines repeated at several times
+	for i := range texts {
+		texts[i].start -= offset
+		for j := range texts[i].words {
+			texts[i].words[j].Start -= offset
+			if texts[i].words[j].End > 0 {
+				texts[i].words[j].End -= offset
+			}
+		}
+	}
+	sort.SliceStable(texts, func(i, j int) bool {
+		return texts[i].start < texts[j].start
+	})
+
+	ret, timedWarnings := readTimedTexts("LRC", texts, maxDuration, maxDuration)
+	warnings = append(warnings, timedWarnings...)
+
+	// The last word of each line ends with the line, unless it has an end time tag
+	for i := range ret {
+		if n := len(ret[i].Words); n > 0 && (ret[i].Words[n-1].End == 0 || ret[i].Words[n-1].End > ret[i].End) {
+			ret[i].Words[n-1].End = ret[i].End
+		}
+	}
+
+	// If no lines were found, return an error
+	if len(ret) == 0 {
+		return nil, metadata, warnings, &ParseError{Format: "LRC", Reason: "Invalid LRC: no lyrics found"}
+	}
+
+	return ret, metadata, warnings, nil
+}
+
+// parseLRCText parses the enhanced word time tags of a lyrics line into the plain text and
+// the timed words. Each word ends at the next time tag; the last word ends at a final time
+// tag, or has no end time. Returns nil words if the line has no word time tags.
+func parseLRCText(text string) (plain string, words []models.ModelWord) {
+	exp := regexp.MustCompile(`<(\d+):(\d{1,2})(?:[.:](\d{1,3}))?>`)
+	locs := exp.FindAllStringIndex(text, -1)
+	if len(locs) == 0 {
+		return strings.TrimSpace(text), nil
+	}
+
+	plain = text[:locs[0][0]]
+	for i, tag := range exp.FindAllStringSubmatch(text, -1) {
+		at := parseTimedBlockTime([]string{"0", tag[1], tag[2], tag[3]})
+		segment := text[locs[i][1]:]
+		if i+1 < len(locs) {
+			segment = text[locs[i][1]:locs[i+1][0]]
+		}
+		plain += segment
+		if len(words) > 0 && words[len(words)-1].End == 0 {
+			words[len(words)-1].End = at
+		}
+		if word := strings.TrimSpace(segment); len(word) > 0 {
+			words = append(words, models.ModelWord{Text: word, Start: at})
+		}
+	}
+	return strings.TrimSpace(plain), words
+}
+
+// formatDuration2LRC converts a number of centiseconds to LRC time format string (mm:ss.xx).
+func formatDuration2LRC(centiseconds int) string {
+	return fmt.Sprintf("%02d:%02d.%02d", centiseconds/6000, centiseconds/100%60, centiseconds%100)
+}
+
+// formatLRCText converts the lines of a subtitle to a lyrics line, with a word time tag
+// before each word while the Words match the text, and a final one when the last word ends
+// before the subtitle.
+func formatLRCText(item *models.ModelItemSubtitle, rounding models.FrameRounding) string {
+	lines := make([]string, len(item.Text))
+	for j := range item.Text {
+		lines[j] = cleanText(item.Text[j])
+	}
+	text := strings.Join(lines, " ")
+	if len(item.Words) == 0 {
+		return text
+	}
+
+	// Find the words in order in the text
+	var b strings.Builder
+	pos := 0
+	for _, word := range item.Words {
+		k := strings.Index(text[pos:], word.Text)
+		if k < 0 || len(word.Text) == 0 {
+			return text
+		}
+		b.WriteString(text[pos:pos+k] + "<" + formatDuration2LRC(formatDuration2Frame(word.Start, 100, rounding)) + ">" + word.Text)
+		pos += k + len(word.Text)
+	}
+	b.WriteString(text[pos:])
+	if last := item.Words[len(item.Words)-1]; last.End > 0 && last.End < item.End {
+		b.WriteString("<" + formatDuration2LRC(formatDuration2Frame(last.End, 100, rounding)) + ">")
+	}
+	return b.String()
+}
+
+// WriteLRC converts subtitle data from the internal model to LRC formatted lyrics, with the
+// Metadata as ID tags. The times are converted to centiseconds with the FrameRounding of the
+// subtitle, the Words are written as enhanced word time tags, and an empty line clears the
+// screen at the end of each subtitle, unless the next one starts then.
+func WriteLRC(sub *models.Subtitle) (content string) {
+	var b strings.Builder
+	for _, info := range sub.Metadata {
+		if len(info.Key) > 0 && !strings.EqualFold(info.Key, "offset") {
+			b.WriteString("[" + info.Key + ":" + info.Value + "]\n")
+		}
+	}
+	for i := range sub.Lines {
+		end := formatDuration2Frame(sub.Lines[i].End, 100, sub.FrameRounding)
+		b.WriteString("[" + formatDuration2LRC(formatDuration2Frame(sub.Lines[i].Start, 100, sub.FrameRounding)) + "]" +
+			formatLRCText(&sub.Lines[i], sub.FrameRounding) + "\n")
+		if i+1 == len(sub.Lines) || end < formatDuration2Frame(sub.Lines[i+1].Start, 100, sub.FrameRounding) {
+			b.WriteString("[" + formatDuration2LRC(end) + "]\n")
+		}
+	}
+	return b.String()
+}
+
+// lrcFormat implements the Format interface for LRC.
+type lrcFormat struct{}
+
+func init() {
+	Register(lrcFormat{})
+}
+
+// Name returns the name of the LRC format.
+func (lrcFormat) Name() string { return "LRC" }
+
+// Extensions returns the file extensions of the LRC format.
+func (lrcFormat) Extensions() []string { return []string{".lrc"} }
+
+// Probe returns the confidence that the content is LRC: a line starting with a time tag
+// ([mm:ss.xx], [mm:ss:xx] or [mm:ss]). The [mm:ss:xx] tags alone on their line are not
+// counted, as they are the time lines of SubViewer 1.
+func (lrcFormat) Probe(content string) float64 {
+	if regexp.MustCompile(`(?m)^\[\d+:\d{1,2}(?:\.\d{1,3})?\]|^(?:\[\d+:\d{1,2}:\d{1,3}\])+[ \t]*\S`).MatchString(content) {
+		return 0.8
+	}
+	return 0
+}
+
+// Read parses LRC content into sub, with the ID tags in Metadata and the lines ending
+// after the MaxDuration of the subtitle at most.
+func (lrcFormat) Read(content string, sub *models.Subtitle) (err error) {
+	sub.Lines, sub.Metadata, sub.Warnings, err = readLRC(content, sub.MaxDuration)
+	return err
+}
+
+// Write converts the subtitle to LRC content.
+func (lrcFormat) Write(sub *models.Subtitle) string { return WriteLRC(sub) }
@@ -0,0 +1,290 @@
+package format
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+)
+
+/*
+MicroDVD Format Specification:
+
+Regular Expression for subtitle line validation:
+^\{(\d+)\}\{(\d*)\}(.*)$
+
+The times are frame numbers, converted to times with the frame rate of the video.
+The first line may give the frame rate as the text of a subtitle at frame 1 (or 0).
+Lines of text are separated by |, and control codes at the start of a line style it:
+{y:i} italic, {y:b} bold, {y:u} underline, {y:s} strikeout, {c:$BBGGRR} color and
+{f:Name} font. Lowercase codes style one line and uppercase codes the whole subtitle.
+
+Example MicroDVD Format:
+{1}{1}23.976
+{3292}{3363}Senator, we're making|{y:i}our final approach into Coruscant.
+{3365}{3413}Very good, Lieutenant.
+*/
+
+// DefaultFrameRate is the frame rate used by the frame-based formats when the content
+// has no frame rate and none is given.
+const DefaultFrameRate = 23.976
+
+// ReadMicroDVD parses MicroDVD formatted subtitle content and converts it to the internal model.
+// The frame rate of the content header is used if present, otherwise the given frame rate,
+// or DefaultFrameRate if zero. Returns the frame rate used.
+// Returns a *ParseError if the content is not a valid MicroDVD format.
+func ReadMicroDVD(content string, frameRate float64) (ret []models.ModelItemSubtitle, usedFrameRate float64, err error) {
+	ret, usedFrameRate, _, err = readMicroDVD(content, frameRate)
+	return ret, usedFrameRate, err
+}
+
+// readMicroDVD parses MicroDVD formatted subtitle content like ReadMicroDVD, and also returns the
+// problems found in the subtitles that were parsed or skipped as warnings.
+func readMicroDVD(content string, frameRate float64) (ret []models.ModelItemSubtitle, usedFrameRate float64, warnings []error, err error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+
+	// warn records a problem of a line
+	warn := func(line int, cue int, text string, reason string) {
+		warnings = append(warnings, &ParseError{Format: "MicroDVD", Line: line, Column: 1, Cue: cue, Text: text, Reason: reason})
+	}
+
+	exp := regexp.MustCompile(`^\{(\d+)\}\{(\d*)\}(.*)$`)
+	type frameLine struct {
+		number int
+		start  int
+		end    int
+		text   string
+	}
+	var frames []frameLine
+	for i, line := range strings.Split(content, "\n") {
+		line = cleanText(line)
+		if line == "" {
+			continue
+		}
+		res := exp.FindStringSubmatch(line)
+		if len(res) != 4 {
+			warn(i+1, len(frames)+1, line, "unexpected line, expected {start}{end}text")
+			continue
+		}
+
+		// The frame rate header is a subtitle at frame 0 or 1 with a number as text
+		if len(frames) == 0 && usedFrameRate == 0 && toInt(res[1]) <= 1 && toInt(res[2]) <= 1 {
+			if rate, errRate := strconv.ParseFloat(strings.TrimSpace(res[3]), 64); errRate == nil && rate > 0 {
+				usedFrameRate = rate
+				continue
+			}
+		}
+		frames = append(frames, frameLine{number: i + 1, start: toInt(res[1]), end: toInt(res[2]), text: res[3]})
+	}
+
+	// If no subtitles were found, return an error
+	if len(frames) == 0 {
+		return nil, usedFrameRate, warnings, &ParseError{Format: "MicroDVD", Reason: "Invalid MicroDVD: no subtitles found"}
+	}
+
+	if usedFrameRate == 0 {
+		usedFrameRate = frameRate
+	}
+	if usedFrameRate <= 0 {
+		usedFrameRate = DefaultFrameRate
+		warn(0, 0, "", fmt.Sprintf("no frame rate, using %v fps", DefaultFrameRate))
+	}
+
+	for _, frame := range frames {
+		cue := len(ret) + 1
+		item := models.ModelItemSubtitle{
+			Seq:   cue,
+			Start: formatFrame2Duration(frame.start, usedFrameRate),
+			End:   formatFrame2Duration(frame.end, usedFrameRate),
+		}
+		item.Text, item.Spans = parseMicroDVDText(frame.text)
+		if len(strings.Join(item.Text, "")) == 0 {
+			warn(frame.number, cue, frame.text, fmt.Sprintf("cue %d has no text", cue))
+		}
+		if item.End < item.Start {
+			warn(frame.number, cue, frame.text, "end time before start time")
+		}
+		if len(ret) > 0 && item.Start < ret[len(ret)-1].Start {
+			warn(frame.number, cue, frame.text, "timestamp out of order")
+		}
+		ret = append(ret, item)
+	}
+
+	return ret, usedFrameRate, warnings, nil
+}
+
+// parseMicroDVDText parses the lines of a MicroDVD subtitle (separated by |) and their
+// control codes into plain text and runs. A line starting with / is italic.
+// Returns nil runs if the text has no styling.
+func parseMicroDVDText(text string) (lines []string, spans [][]models.ModelSpan) {
+	exp := regexp.MustCompile(`^\{([a-zA-Z]):([^}]*)\}`)
+
+	var global models.ModelSpan
+	for _, line := range strings.Split(text, "|") {
+		style := global
+		line = strings.TrimSpace(line)
+		for {
+			res := exp.FindStringSubmatch(line)
+			if len(res) != 3 {
+				break
+			}
+			line = strings.TrimSpace(line[len(res[0]):])
+
+			// Uppercase codes style all the lines
+			applyMicroDVDCode(&style, strings.ToLower(res[1]), res[2])
+			if res[1] == strings.ToUpper(res[1]) {
+				applyMicroDVDCode(&global, strings.ToLower(res[1]), res[2])
+			}
+		}
+		if strings.HasPrefix(line, "/") {
+			style.Italic = true
+			line = strings.TrimSpace(line[1:])
+		}
+
+		line = cleanText(line)
+		lines = append(lines, line)
+		spans = append(spans, appendSpan(nil, style, line))
+	}
+
+	if !hasStyle(spans) {
+		spans = nil
+	}
+	return lines, spans
+}
+
+// applyMicroDVDCode applies a MicroDVD control code (lowercase name and value) to the style.
+// Other codes (e.g., size and position) are ignored.
+func applyMicroDVDCode(style *models.ModelSpan, name string, value string) {
+	switch name {
+	case "y":
+		for _, attr := range strings.Split(strings.ToLower(value), ",") {
+			switch strings.TrimSpace(attr) {
+			case "i":
+				style.Italic = true
+			case "b":
+				style.Bold = true
+			case "u":
+				style.Underline = true
+			case "s":
+				style.StrikeOut = true
+			}
+		}
+	case "c":
+		if color := formatSSAColor2HTML(strings.TrimPrefix(strings.TrimSpace(value), "$")); len(color) > 0 {
+			style.Color = color
+		}
+	case "f":
+		style.Font = strings.TrimSpace(value)
+	}
+}
+
+// formatFrame2Duration converts a frame number to the time.Duration at which it is shown.
+func formatFrame2Duration(frame int, frameRate float64) time.Duration {
+	return time.Duration(math.Round(float64(frame) * float64(time.Second) / frameRate))
+}
+
+// formatDuration2Frame converts a time.Duration to a whole number of units of time (e.g.,
+// frames at 25 frames per second, or deciseconds at 10 per second) with the given rounding.
+func formatDuration2Frame(d time.Duration, perSecond float64, rounding models.FrameRounding) int {
+	frames := d.Seconds() * perSecond
+
+	// Ignore the floating point error of times that were converted from frames
+	const epsilon = 1e-6
+	switch rounding {
+	case models.RoundDown:
+		return int(math.Floor(frames + epsilon))
+	case models.RoundUp:
+		return int(math.Ceil(frames - epsilon))
+	}
+	return int(math.Round(frames))
+}
+
+// formatMicroDVDText converts the lines of a subtitle to MicroDVD text, with the control
+// codes of the lines that have a single style.
+func formatMicroDVDText(item *models.ModelItemSubtitle) string {
+	lines := make([]string, len(item.Text))
+	for j := range item.Text {
+		lines[j] = cleanText(item.Text[j])
+		spans := lineSpans(item, j)
+		if len(spans) != 1 {
+			continue
+		}
+
+		codes := ""
+		var attrs []string
+		for _, attr := range []struct {
+			name string
+			on   bool
+		}{{"i", spans[0].Italic}, {"b", spans[0].Bold}, {"u", spans[0].Underline}, {"s", spans[0].StrikeOut}} {
+			if attr.on {
+				attrs = append(attrs, attr.name)
+			}
+		}
+		if len(attrs) > 0 {
+			codes += "{y:" + strings.Join(attrs, ",") + "}"
+		}
+		if color := formatHTMLColor2SSA(spans[0].Color); len(color) > 0 {
+			codes += "{c:$" + strings.Trim(color, "&H") + "}"
+		}
+		if len(spans[0].Font) > 0 {
+			codes += "{f:" + spans[0].Font + "}"
+		}
+		lines[j] = codes + lines[j]
+	}
+	return strings.Join(lines, "|")
+}
+
+// WriteMicroDVD converts subtitle data from the internal model to MicroDVD formatted content.
+// The times are converted to frames with the FrameRate of the subtitle (DefaultFrameRate if zero)
+// and its FrameRounding, and the frame rate is written as the header.
+func WriteMicroDVD(sub *models.Subtitle) (content string) {
+	frameRate := sub.FrameRate
+	if frameRate <= 0 {
+		frameRate = DefaultFrameRate
+	}
+
+	var b strings.Builder
+	b.WriteString("{1}{1}" + strconv.FormatFloat(frameRate, 'f', -1, 64) + "\n")
+	for i := range sub.Lines {
+		fmt.Fprintf(&b, "{%d}{%d}%s\n",
+			formatDuration2Frame(sub.Lines[i].Start, frameRate, sub.FrameRounding),
+			formatDuration2Frame(sub.Lines[i].End, frameRate, sub.FrameRounding),
+			formatMicroDVDText(&sub.Lines[i]))
+	}
+	return b.String()
+}
+
+// microDVDFormat implements the Format interface for MicroDVD.
+type microDVDFormat struct{}
+
+func init() {
+	Register(microDVDFormat{})
+}
+
+// Name returns the name of the MicroDVD format.
+func (microDVDFormat) Name() string { return "MicroDVD" }
+
+// Extensions returns the file extensions of the MicroDVD format.
+func (microDVDFormat) Extensions() []string { return []string{".sub"} }
+
+// Probe returns the confidence that the content is MicroDVD: a line starting with {start}{end}.
+func (microDVDFormat) Probe(content string) float64 {
+	if regexp.MustCompile(`^\{\d+\}\{\d*\}`).MatchString(strings.TrimSpace(strings.TrimPrefix(content, "\ufeff"))) {
+		return 0.9
+	}
+	return 0
+}
+
+// Read parses MicroDVD content into sub, with the FrameRate of sub when the content has none.
+// The frame rate used is stored in sub.FrameRate.
+func (microDVDFormat) Read(content string, sub *models.Subtitle) (err error) {
+	sub.Lines, sub.FrameRate, sub.Warnings, err = readMicroDVD(content, sub.FrameRate)
+	return err
+}
+
+// Write converts the subtitle to MicroDVD content.
+func (microDVDFormat) Write(sub *models.Subtitle) string { return WriteMicroDVD(sub) }
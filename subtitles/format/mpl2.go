@@ -0,0 +1,136 @@
+package format
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+)
+
+/*
+MPL2 Format Specification:
+
+Regular Expression for subtitle line validation:
+^\[(\d+)\]\[(\d*)\](.*)$
+
+The times are in deciseconds. Lines of text are separated by |, and a line starting
+with / is italic.
+
+Example MPL2 Format:
+[1372][1403]Senator, we're making|/our final approach into Coruscant.
+[1404][1425]Very good, Lieutenant.
+*/
+
+// ReadMPL2 parses MPL2 formatted subtitle content and converts it to the internal model.
+// Returns a *ParseError if the content is not a valid MPL2 format.
+func ReadMPL2(content string) (ret []models.ModelItemSubtitle, err error) {
+	ret, _, err = readMPL2(content)
+	return ret, err
+}
+
+// readMPL2 parses MPL2 formatted subtitle content like ReadMPL2, and also returns the
+// problems found in the subtitles that were parsed or skipped as warnings.
+func readMPL2(content string) (ret []models.ModelItemSubtitle, warnings []error, err error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+
+	// warn records a problem of a line
+	warn := func(line int, cue int, text string, reason string) {
+		warnings = append(warnings, &ParseError{Format: "MPL2", Line: line, Column: 1, Cue: cue, Text: text, Reason: reason})
+	}
+
+	exp := regexp.MustCompile(`^\[(\d+)\]\[(\d*)\](.*)$`)
+	for i, line := range strings.Split(content, "\n") {
+		line = cleanText(line)
+		if line == "" {
+			continue
+		}
+		cue := len(ret) + 1
+		res := exp.FindStringSubmatch(line)
+		if len(res) != 4 {
+			warn(i+1, cue, line, "unexpected line, expected [start][end]text")
+			continue
+		}
+
+		item := models.ModelItemSubtitle{
+			Seq:   cue,
+			Start: time.Duration(toInt(res[1])) * 100 * time.Millisecond,
+			End:   time.Duration(toInt(res[2])) * 100 * time.Millisecond,
+		}
+		item.Text, item.Spans = parseMicroDVDText(res[3])
+		if len(strings.Join(item.Text, "")) == 0 {
+			warn(i+1, cue, line, fmt.Sprintf("cue %d has no text", cue))
+		}
+		if item.End < item.Start {
+			warn(i+1, cue, line, "end time before start time")
+		}
+		if len(ret) > 0 && item.Start < ret[len(ret)-1].Start {
+			warn(i+1, cue, line, "timestamp out of order")
+		}
+		ret = append(ret, item)
+	}
+
+	// If no subtitles were found, return an error
+	if len(ret) == 0 {
+		return nil, warnings, &ParseError{Format: "MPL2", Reason: "Invalid MPL2: no subtitles found"}
+	}
+
+	return ret, warnings, nil
+}
+
+// formatMPL2Text converts the lines of a subtitle to MPL2 text, with the italic mark
+// on the lines that are entirely italic.
+func formatMPL2Text(item *models.ModelItemSubtitle) string {
+	lines := make([]string, len(item.Text))
+	for j := range item.Text {
+		lines[j] = cleanText(item.Text[j])
+		if spans := lineSpans(item, j); len(spans) == 1 && spans[0].Italic {
+			lines[j] = "/" + lines[j]
+		}
+	}
+	return strings.Join(lines, "|")
+}
+
+// WriteMPL2 converts subtitle data from the internal model to MPL2 formatted content.
+// The times are converted to deciseconds with the FrameRounding of the subtitle.
+func WriteMPL2(sub *models.Subtitle) (content string) {
+	var b strings.Builder
+	for i := range sub.Lines {
+		fmt.Fprintf(&b, "[%d][%d]%s\n",
+			formatDuration2Frame(sub.Lines[i].Start, 10, sub.FrameRounding),
+			formatDuration2Frame(sub.Lines[i].End, 10, sub.FrameRounding),
+			formatMPL2Text(&sub.Lines[i]))
+	}
+	return b.String()
+}
+
+// mpl2Format implements the Format interface for MPL2.
+type mpl2Format struct{}
+
+func init() {
+	Register(mpl2Format{})
+}
+
+// Name returns the name of the MPL2 format.
+func (mpl2Format) Name() string { return "MPL2" }
+
+// Extensions returns the file extensions of the MPL2 format.
+func (mpl2Format) Extensions() []string { return []string{".mpl", ".txt"} }
+
+// Probe returns the confidence that the content is MPL2: a line starting with [start][end].
+func (mpl2Format) Probe(content string) float64 {
+	if regexp.MustCompile(`^\[\d+\]\[\d*\]`).MatchString(strings.TrimSpace(strings.TrimPrefix(content, "\ufeff"))) {
+		return 0.9
+	}
+	return 0
+}
+
+// Read parses MPL2 content into sub.
+func (mpl2Format) Read(content string, sub *models.Subtitle) (err error) {
+	sub.Lines, sub.Warnings, err = readMPL2(content)
+	return err
+}
+
+// Write converts the subtitle to MPL2 content.
+func (mpl2Format) Write(sub *models.Subtitle) string { return WriteMPL2(sub) }
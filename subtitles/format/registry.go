@@ -0,0 +1,137 @@
+package format
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+)
+
+// Format is a subtitle format that can be detected, read and written.
+// Formats are registered with Register and looked up by name or extension,
+// so other packages can add their own formats.
+type Format interface {
+	// Name returns the unique name of the format (e.g., "SRT").
+	Name() string
+	// Extensions returns the file extensions of the format, with the leading dot (e.g., ".srt").
+	Extensions() []string
+	// Probe returns the confidence, between 0 and 1, that the content is in this format.
+	Probe(content string) float64
+	// Read parses the content and stores the subtitle entries and metadata in sub.
+	Read(content string, sub *models.Subtitle) error
+	// Write converts the subtitle to formatted content.
+	Write(sub *models.Subtitle) string
+}
+
+// BinaryFormat is implemented by the formats of binary files (e.g., EBU STL), whose content
+// is probed, read and written as the raw bytes of the file in a string instead of text
+// decoded from a character encoding.
+type BinaryFormat interface {
+	Format
+	// Binary reports whether the content of the format is binary.
+	Binary() bool
+}
+
+// IsBinary reports whether the format reads and writes binary content.
+func IsBinary(f Format) bool {
+	b, ok := f.(BinaryFormat)
+	return ok && b.Binary()
+}
+
+// StreamFormat is implemented by the text formats made of blocks separated by empty lines
+// (e.g., SRT, WebVTT), whose cues can be read and written one at a time with bounded memory.
+type StreamFormat interface {
+	Format
+	// ReadBlock parses a block of content (lines without empty lines) at a position of the
+	// content into the cues it holds, none for a header or a comment. The blocks that are not
+	// cues may be stored in sub (e.g., WebVTT NOTE blocks). Also returns the problems found in
+	// the block as warnings.
+	ReadBlock(block string, pos StreamPosition, sub *models.Subtitle) (items []models.ModelItemSubtitle, warnings []error)
+	// WriteHeader returns the content before the first cue (e.g., the WEBVTT signature).
+	WriteHeader(sub *models.Subtitle) string
+	// WriteCue returns the content of a cue, followed by an empty line.
+	WriteCue(item *models.ModelItemSubtitle, sub *models.Subtitle) string
+}
+
+// StreamPosition is the position of a block in streamed content.
+type StreamPosition struct {
+	Line     int                       // Line number of the first line of the block, starting at 1
+	Cue      int                       // Index of the next cue, starting at 1
+	Previous *models.ModelItemSubtitle // Previous cue, or nil before the first cue
+}
+
+// registry holds the registered formats in registration order.
+var registry = struct {
+	sync.RWMutex
+	formats []Format
+}{}
+
+// Register adds a format to the registry.
+// A format with the same name (case insensitive) is replaced.
+func Register(f Format) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	for i := range registry.formats {
+		if strings.EqualFold(registry.formats[i].Name(), f.Name()) {
+			registry.formats[i] = f
+			return
+		}
+	}
+	registry.formats = append(registry.formats, f)
+}
+
+// Formats returns the registered formats in registration order.
+func Formats() []Format {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	return append([]Format(nil), registry.formats...)
+}
+
+// Lookup returns the registered format with the given name (case insensitive),
+// or nil if there is none.
+func Lookup(name string) Format {
+	for _, f := range Formats() {
+		if strings.EqualFold(f.Name(), name) {
+			return f
+		}
+	}
+	return nil
+}
+
+// LookupExtension returns the first registered format using the given file extension
+// (case insensitive, with or without the leading dot), or nil if there is none.
+func LookupExtension(ext string) Format {
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	for _, f := range Formats() {
+		for _, e := range f.Extensions() {
+			if strings.EqualFold(e, ext) {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+// Detect probes the content with every registered format and returns the formats
+// with a confidence above zero, from the highest to the lowest confidence.
+func Detect(content string) (ret []Format) {
+	formats := Formats()
+	scores := make(map[string]float64, len(formats))
+	for _, f := range formats {
+		if score := f.Probe(content); score > 0 {
+			scores[f.Name()] = score
+			ret = append(ret, f)
+		}
+	}
+
+	// Sort by confidence, keeping the registration order on ties
+	sort.SliceStable(ret, func(i, j int) bool {
+		return scores[ret[i].Name()] > scores[ret[j].Name()]
+	})
+	return ret
+}
@@ -0,0 +1,499 @@
+package format
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+)
+
+/*
+Rich Text Specification:
+
+The styling of the text is kept in the Spans of each subtitle line, as runs of
+text with the same attributes. Each reader converts its own syntax to runs and
+each writer renders the runs in its own syntax:
+
+SRT:    <i>italic</i> <b>bold</b> <u>underline</u> <s>strike</s> <font color="#FF0000" face="Arial">red</font>
+WebVTT: <i>italic</i> <b>bold</b> <u>underline</u> <c.red>red</c> <v Speaker>voice</v>
+SSA:    {\i1}italic{\i0} {\b1}bold{\b0} {\u1}underline{\u0} {\s1}strike{\s0} {\c&H0000FF&\fnArial}red{\r}
+
+The Spans are only used while their text matches the Text of the line, so
+editing the Text without the Spans writes the edited text without styling.
+
+The SSA karaoke tags ({\k50}Ka{\k30}ra{\k40}oke) are kept in the Words of the
+subtitle, with the timing of each syllable.
+*/
+
+// vttColors maps the WebVTT default color classes to their colors.
+var vttColors = map[string]string{
+	"white":   "#FFFFFF",
+	"lime":    "#00FF00",
+	"cyan":    "#00FFFF",
+	"red":     "#FF0000",
+	"yellow":  "#FFFF00",
+	"magenta": "#FF00FF",
+	"blue":    "#0000FF",
+	"black":   "#000000",
+}
+
+// htmlColors maps the common HTML color names to their colors.
+var htmlColors = map[string]string{
+	"white":   "#FFFFFF",
+	"silver":  "#C0C0C0",
+	"gray":    "#808080",
+	"grey":    "#808080",
+	"black":   "#000000",
+	"red":     "#FF0000",
+	"maroon":  "#800000",
+	"yellow":  "#FFFF00",
+	"olive":   "#808000",
+	"lime":    "#00FF00",
+	"green":   "#008000",
+	"aqua":    "#00FFFF",
+	"cyan":    "#00FFFF",
+	"teal":    "#008080",
+	"blue":    "#0000FF",
+	"navy":    "#000080",
+	"fuchsia": "#FF00FF",
+	"magenta": "#FF00FF",
+	"purple":  "#800080",
+	"orange":  "#FFA500",
+}
+
+// appendSpan appends a run of text with the given style to the spans, merging it
+// with the previous run when both have the same style.
+func appendSpan(spans []models.ModelSpan, style models.ModelSpan, text string) []models.ModelSpan {
+	if len(text) == 0 {
+		return spans
+	}
+	style.Text = ""
+	if len(spans) > 0 {
+		last := spans[len(spans)-1]
+		last.Text = ""
+		if last == style {
+			spans[len(spans)-1].Text += text
+			return spans
+		}
+	}
+	style.Text = text
+	return append(spans, style)
+}
+
+// hasStyle reports whether any run of the lines has styling.
+func hasStyle(lines [][]models.ModelSpan) bool {
+	for _, spans := range lines {
+		for _, span := range spans {
+			span.Text = ""
+			if span != (models.ModelSpan{}) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// spansText returns the text of the runs joined together.
+func spansText(spans []models.ModelSpan) (text string) {
+	for _, span := range spans {
+		text += span.Text
+	}
+	return text
+}
+
+// trimSpanLines removes the spaces around the runs of each line of a subtitle and returns
+// the plain text and the runs of the lines (nil if there is no styling).
+func trimSpanLines(lines [][]models.ModelSpan) (text []string, spans [][]models.ModelSpan) {
+	for _, runs := range lines {
+		if len(runs) > 0 {
+			runs[0].Text = strings.TrimLeft(runs[0].Text, " ")
+			runs[len(runs)-1].Text = strings.TrimRight(runs[len(runs)-1].Text, " ")
+		}
+		var trimmed []models.ModelSpan
+		for _, run := range runs {
+			trimmed = appendSpan(trimmed, run, run.Text)
+		}
+		text = append(text, spansText(trimmed))
+		spans = append(spans, trimmed)
+	}
+	if !hasStyle(spans) {
+		spans = nil
+	}
+	return text, spans
+}
+
+// lineSpans returns the runs of the line j of a subtitle when they match its text,
+// or nil if the line has no styling or its text was edited.
+func lineSpans(item *models.ModelItemSubtitle, j int) []models.ModelSpan {
+	if j >= len(item.Spans) || j >= len(item.Text) {
+		return nil
+	}
+	if strings.TrimSpace(spansText(item.Spans[j])) != strings.TrimSpace(item.Text[j]) {
+		return nil
+	}
+	return item.Spans[j]
+}
+
+// formatHTMLColor converts a HTML color (name, #RGB or #RRGGBB) to "#RRGGBB".
+// Unknown colors are returned unchanged.
+func formatHTMLColor(color string) string {
+	color = strings.TrimSpace(color)
+	if named, ok := htmlColors[strings.ToLower(color)]; ok {
+		return named
+	}
+	if regexp.MustCompile(`^#?[0-9a-fA-F]{6}$`).MatchString(color) {
+		return "#" + strings.ToUpper(strings.TrimPrefix(color, "#"))
+	}
+	if regexp.MustCompile(`^#[0-9a-fA-F]{3}$`).MatchString(color) {
+		c := strings.ToUpper(color)
+		return "#" + c[1:2] + c[1:2] + c[2:3] + c[2:3] + c[3:4] + c[3:4]
+	}
+	return color
+}
+
+// formatSSAColor2HTML converts a SSA color (&HBBGGRR& or &HAABBGGRR&) to "#RRGGBB".
+// Returns an empty string if the color is not valid.
+func formatSSAColor2HTML(color string) string {
+	color = strings.Trim(strings.TrimSpace(color), "&")
+	color = strings.TrimPrefix(strings.TrimPrefix(color, "H"), "h")
+	value, err := strconv.ParseUint(color, 16, 32)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("#%02X%02X%02X", value&0xFF, (value>>8)&0xFF, (value>>16)&0xFF)
+}
+
+// formatHTMLColor2SSA converts a "#RRGGBB" color to a SSA color (&HBBGGRR&).
+// Returns an empty string if the color is not valid.
+func formatHTMLColor2SSA(color string) string {
+	color = formatHTMLColor(color)
+	if !regexp.MustCompile(`^#[0-9A-F]{6}$`).MatchString(color) {
+		return ""
+	}
+	return "&H" + color[5:7] + color[3:5] + color[1:3] + "&"
+}
+
+// parseHTMLTags parses the lines of a cue with HTML-like tags (<i>, <b>, <u>, <s>,
+// <font color face>, and the WebVTT <c.color> and <v Speaker> tags) into plain text
+// and runs. The styling continues across the lines of the cue. The WebVTT <lang>, <ruby>,
+// <rt> and timestamp tags are removed, and any other text between angle brackets
+// (e.g., "<Music>", "a < b > c") is kept as text.
+// When entities is true, the HTML entities (&amp; &lt; &gt; &nbsp;) are decoded.
+// Returns the plain lines, the runs of each line (nil if there is no styling) and the
+// speaker of the first <v> tag, if any.
+func parseHTMLTags(lines []string, entities bool) (text []string, spans [][]models.ModelSpan, voice string) {
+	exp := regexp.MustCompile(`(?i)</?(?:[ibusc]|ruby|rt)(?:\.[^\s<>]*)?>|</?(?:font|v|lang)(?:[.\s][^<>]*)?>|<(?:\d+:)?\d{2}:\d{2}\.\d{3}>`)
+	entity := strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&nbsp;", " ", "&lrm;", "‎", "&rlm;", "‏")
+
+	var style models.ModelSpan
+	var stack []models.ModelSpan
+	for _, line := range lines {
+		var runs []models.ModelSpan
+		last := 0
+		for _, loc := range exp.FindAllStringIndex(line, -1) {
+			before := line[last:loc[0]]
+			if entities {
+				before = entity.Replace(before)
+			}
+			runs = appendSpan(runs, style, before)
+			last = loc[1]
+
+			// Split the tag into its name, classes and annotation
+			tag := strings.TrimSpace(line[loc[0]+1 : loc[1]-1])
+			closing := strings.HasPrefix(tag, "/")
+			tag = strings.TrimPrefix(tag, "/")
+			annotation := ""
+			if i := strings.IndexAny(tag, " \t"); i >= 0 {
+				tag, annotation = tag[:i], strings.TrimSpace(tag[i+1:])
+			}
+			classes := strings.Split(tag, ".")
+			name := strings.ToLower(classes[0])
+
+			switch name {
+			case "i":
+				style.Italic = !closing
+			case "b":
+				style.Bold = !closing
+			case "u":
+				style.Underline = !closing
+			case "s":
+				style.StrikeOut = !closing
+			case "font", "c":
+				if closing {
+					if len(stack) > 0 {
+						previous := stack[len(stack)-1]
+						stack = stack[:len(stack)-1]
+						style.Color, style.Font = previous.Color, previous.Font
+					}
+					continue
+				}
+				stack = append(stack, style)
+				if name == "font" {
+					if color := htmlAttribute(annotation, "color"); len(color) > 0 {
+						style.Color = formatHTMLColor(color)
+					}
+					if face := htmlAttribute(annotation, "face"); len(face) > 0 {
+						style.Font = face
+					}
+				}
+				for _, class := range classes[1:] {
+					if color, ok := vttColors[class]; ok {
+						style.Color = color
+					}
+				}
+			case "v":
+				if !closing && len(voice) == 0 {
+					voice = annotation
+				}
+			}
+		}
+		after := line[last:]
+		if entities {
+			after = entity.Replace(after)
+		}
+		runs = appendSpan(runs, style, after)
+
+		text = append(text, spansText(runs))
+		spans = append(spans, runs)
+	}
+
+	if !hasStyle(spans) {
+		spans = nil
+	}
+	return text, spans, voice
+}
+
+// htmlAttribute returns the value of an attribute of a HTML tag annotation
+// (e.g., color of `color="#FF0000" face="Arial"`), or an empty string if not present.
+func htmlAttribute(annotation string, name string) string {
+	exp := regexp.MustCompile(`(?i)\b` + name + `\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"']+))`)
+	res := exp.FindStringSubmatch(annotation)
+	if len(res) != 4 {
+		return ""
+	}
+	return res[1] + res[2] + res[3]
+}
+
+// formatSpans2HTML renders the runs of a line with HTML-like tags (<i>, <b>, <u>, <s>,
+// <font color face>), closing every tag at the end of the line.
+func formatSpans2HTML(spans []models.ModelSpan) (content string) {
+	for _, span := range spans {
+		open, close := "", ""
+		if len(span.Color) > 0 || len(span.Font) > 0 {
+			open += "<font"
+			if len(span.Color) > 0 {
+				open += ` color="` + span.Color + `"`
+			}
+			if len(span.Font) > 0 {
+				open += ` face="` + span.Font + `"`
+			}
+			open += ">"
+			close = "</font>" + close
+		}
+		for _, tag := range []struct {
+			name string
+			on   bool
+		}{{"b", span.Bold}, {"i", span.Italic}, {"u", span.Underline}, {"s", span.StrikeOut}} {
+			if tag.on {
+				open += "<" + tag.name + ">"
+				close = "</" + tag.name + ">" + close
+			}
+		}
+		content += open + span.Text + close
+	}
+	return content
+}
+
+// formatSpans2VTT renders the runs of a line with WebVTT tags (<i>, <b>, <u> and the
+// <c.color> classes of the default colors), escaping the text.
+func formatSpans2VTT(spans []models.ModelSpan) (content string) {
+	for _, span := range spans {
+		open, close := "", ""
+		for class, color := range vttColors {
+			if color == span.Color {
+				open += "<c." + class + ">"
+				close = "</c>" + close
+				break
+			}
+		}
+		for _, tag := range []struct {
+			name string
+			on   bool
+		}{{"b", span.Bold}, {"i", span.Italic}, {"u", span.Underline}} {
+			if tag.on {
+				open += "<" + tag.name + ">"
+				close = "</" + tag.name + ">" + close
+			}
+		}
+		content += open + escapeVTT(span.Text) + close
+	}
+	return content
+}
+
+// escapeVTT escapes the characters of WebVTT cue text that start tags and entities.
+func escapeVTT(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+// parseSSASpans parses the override tags ({\i1}, {\b1}, {\u1}, {\s1}, {\c&HBBGGRR&},
+// {\1c&HBBGGRR&}, {\fnName}, {\r}) of a SSA/ASS event text into the runs of each line.
+// Other tags are ignored. Returns nil if the text has no styling.
+func parseSSASpans(text string) (spans [][]models.ModelSpan) {
+	exp := regexp.MustCompile(`\{[^}]*\}`)
+	soft := strings.NewReplacer("\\n", " ", "\\h", " ")
+
+	var style models.ModelSpan
+	var runs []models.ModelSpan
+	addText := func(segment string) {
+		for i, part := range strings.Split(segment, "\\N") {
+			if i > 0 {
+				spans = append(spans, runs)
+				runs = nil
+			}
+			runs = appendSpan(runs, style, soft.Replace(part))
+		}
+	}
+
+	last := 0
+	for _, loc := range exp.FindAllStringIndex(text, -1) {
+		addText(text[last:loc[0]])
+		last = loc[1]
+		for _, tag := range strings.Split(text[loc[0]+1:loc[1]-1], "\\")[1:] {
+			applySSATag(&style, strings.TrimSpace(tag))
+		}
+	}
+	addText(text[last:])
+	spans = append(spans, runs)
+
+	if !hasStyle(spans) {
+		return nil
+	}
+	return spans
+}
+
+// parseSSAKaraoke parses the karaoke tags ({\k}, {\K}, {\kf} and {\ko} with a duration in
+// centiseconds) of a SSA/ASS event text into the timed words of the event, from its start time.
+// Syllables without text only advance the time. Returns nil if the text has no karaoke tags.
+func parseSSAKaraoke(text string, start time.Duration) (words []models.ModelWord) {
+	exp := regexp.MustCompile(`\{[^}]*\}`)
+	karaoke := regexp.MustCompile(`\\(?:k|K|kf|ko)(\d+)`)
+	soft := strings.NewReplacer("\\N", " ", "\\n", " ", "\\h", " ")
+
+	var word models.ModelWord
+	var timed bool
+	addWord := func() {
+		word.Text = strings.TrimSpace(soft.Replace(word.Text))
+		if timed && len(word.Text) > 0 {
+			words = append(words, word)
+		}
+		word.Text = ""
+	}
+
+	last := 0
+	for _, loc := range exp.FindAllStringIndex(text, -1) {
+		word.Text += text[last:loc[0]]
+		last = loc[1]
+		for _, tag := range karaoke.FindAllStringSubmatch(text[loc[0]:loc[1]], -1) {
+			addWord()
+			timed = true
+			word.Start = start
+			start += time.Duration(toInt(tag[1])) * 10 * time.Millisecond
+			word.End = start
+		}
+	}
+	word.Text += text[last:]
+	addWord()
+	return words
+}
+
+// applySSATag applies a SSA/ASS override tag (without the backslash) to the style.
+func applySSATag(style *models.ModelSpan, tag string) {
+	// toggle returns the value of a toggle tag (e.g., "i1") and whether the tag is of the given name
+	toggle := func(name string) (on bool, ok bool) {
+		if !strings.HasPrefix(tag, name) {
+			return false, false
+		}
+		value := tag[len(name):]
+		if len(value) == 0 {
+			return false, true
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return false, false
+		}
+		return n != 0, true
+	}
+
+	if on, ok := toggle("i"); ok {
+		style.Italic = on
+	} else if on, ok := toggle("b"); ok {
+		style.Bold = on
+	} else if on, ok := toggle("u"); ok {
+		style.Underline = on
+	} else if on, ok := toggle("s"); ok {
+		style.StrikeOut = on
+	} else if strings.HasPrefix(tag, "fn") {
+		style.Font = strings.TrimSpace(tag[2:])
+	} else if strings.HasPrefix(tag, "1c&") || strings.HasPrefix(tag, "c&") {
+		style.Color = formatSSAColor2HTML(tag[strings.Index(tag, "&"):])
+	} else if tag == "c" || tag == "1c" {
+		style.Color = ""
+	} else if strings.HasPrefix(tag, "r") {
+		*style = models.ModelSpan{}
+	}
+}
+
+// formatSpans2SSA renders the runs of the lines of a subtitle with SSA/ASS override tags,
+// joining the lines with \N. The styling continues across the lines.
+func formatSpans2SSA(lines [][]models.ModelSpan) string {
+	var style models.ModelSpan
+	var content []string
+	for _, spans := range lines {
+		line := ""
+		for _, span := range spans {
+			tags := ""
+
+			// Color and font can't be turned off one by one, so the style is reset
+			if (len(style.Color) > 0 && len(span.Color) == 0) || (len(style.Font) > 0 && len(span.Font) == 0) {
+				tags += "\\r"
+				style = models.ModelSpan{}
+			}
+			for _, tag := range []struct {
+				name    string
+				current bool
+				next    bool
+			}{
+				{"i", style.Italic, span.Italic},
+				{"b", style.Bold, span.Bold},
+				{"u", style.Underline, span.Underline},
+				{"s", style.StrikeOut, span.StrikeOut},
+			} {
+				if tag.current != tag.next {
+					if tag.next {
+						tags += "\\" + tag.name + "1"
+					} else {
+						tags += "\\" + tag.name + "0"
+					}
+				}
+			}
+			if span.Color != style.Color {
+				if color := formatHTMLColor2SSA(span.Color); len(color) > 0 {
+					tags += "\\c" + color
+				}
+			}
+			if span.Font != style.Font {
+				tags += "\\fn" + span.Font
+			}
+			if len(tags) > 0 {
+				line += "{" + tags + "}"
+			}
+			line += span.Text
+			style = span
+		}
+		content = append(content, line)
+	}
+	return strings.Join(content, "\\N")
+}
@@ -0,0 +1,355 @@
+package format
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+)
+
+/*
+SAMI Format Specification:
+
+Regular Expressions for the synchronization points and paragraphs (case insensitive):
+<SYNC\b([^>]*)>       with the Start (and optional End) time in milliseconds
+<P\b([^>]*)>          with the Class of the language of the paragraph
+
+The languages are declared as CSS classes in the STYLE element. A paragraph is shown
+from its SYNC until the next SYNC of the same language, and a paragraph with only
+spaces (&nbsp;) clears the screen.
+
+Example SAMI Format:
+<SAMI>
+<HEAD>
+<STYLE TYPE="text/css">
+<!--
+P { font-family: Arial; text-align: center; }
+.KRCC { Name: Korean; lang: ko-KR; SAMIType: CC; }
+.ENCC { Name: English; lang: en-US; SAMIType: CC; }
+-->
+</STYLE>
+</HEAD>
+<BODY>
+<SYNC Start=137440><P Class=KRCC>의원님, 우리는<br>코루선트에 접근하고 있습니다.
+<SYNC Start=137440><P Class=ENCC>Senator, we're making<br>our final approach into Coruscant.
+<SYNC Start=140375><P Class=KRCC>&nbsp;
+<SYNC Start=140375><P Class=ENCC>&nbsp;
+</BODY>
+</SAMI>
+*/
+
+// samiLastDuration is the duration of the last paragraph of a language, which has no next SYNC.
+const samiLastDuration = 3 * time.Second
+
+// SAMILanguage is a language of a SAMI document, declared as a CSS class.
+type SAMILanguage struct {
+	Class string // Name of the CSS class (e.g., "KRCC")
+	Name  string // Name of the language (e.g., "Korean")
+	Lang  string // Language tag (e.g., "ko-KR")
+}
+
+// samiParagraph is a paragraph of a SAMI document with its synchronization point.
+type samiParagraph struct {
+	class string        // Class of the language of the paragraph
+	start time.Duration // Start time of the SYNC
+	end   time.Duration // End time of the SYNC (0 if not given)
+	text  string        // Text of the paragraph with its tags
+	line  int           // Line number of the SYNC
+}
+
+// ReadSAMILanguages returns the languages of SAMI formatted content: the classes declared
+// in the STYLE element and then the classes used by paragraphs without a declaration.
+// Returns a *ParseError if the content is not a valid SAMI format.
+func ReadSAMILanguages(content string) (languages []SAMILanguage, err error) {
+	languages, paragraphs, err := parseSAMI(content)
+	if err != nil {
+		return nil, err
+	}
+	if len(paragraphs) == 0 {
+		return nil, &ParseError{Format: "SAMI", Reason: "Invalid SAMI: no SYNC found"}
+	}
+	return languages, nil
+}
+
+// ReadSAMI parses the paragraphs of one language of SAMI formatted content and converts them
+// to the internal model. The language is a class (e.g., "KRCC") or a language tag (e.g.,
+// "ko-KR"), case insensitive; the first language is used when empty.
+// Returns a *ParseError if the content is not a valid SAMI format or has no such language.
+func ReadSAMI(content string, language string) (ret []models.ModelItemSubtitle, err error) {
+	ret, _, _, err = readSAMI(content, language)
+	return ret, err
+}
+
+// readSAMI parses one language of SAMI formatted content like ReadSAMI, and also returns the
+// language read and the problems found in the paragraphs as warnings.
+func readSAMI(content string, language string) (ret []models.ModelItemSubtitle, used SAMILanguage, warnings []error, err error) {
+	languages, paragraphs, err := parseSAMI(content)
+	if err != nil {
+		return nil, used, nil, err
+	}
+	if len(paragraphs) == 0 {
+		return nil, used, nil, &ParseError{Format: "SAMI", Reason: "Invalid SAMI: no SYNC found"}
+	}
+
+	// Find the language by class or language tag
+	found := false
+	for _, l := range languages {
+		if len(language) == 0 || strings.EqualFold(l.Class, language) || strings.EqualFold(l.Lang, language) {
+			used, found = l, true
+			break
+		}
+	}
+	if !found {
+		return nil, used, nil, &ParseError{Format: "SAMI", Text: language, Reason: "Invalid SAMI: language not found"}
+	}
+
+	// warn records a problem of a paragraph
+	warn := func(line int, cue int, text string, reason string) {
+		warnings = append(warnings, &ParseError{Format: "SAMI", Line: line, Column: 1, Cue: cue, Text: text, Reason: reason})
+	}
+
+	// Keep the paragraphs of the language, which end at the next SYNC of the language
+	var own []samiParagraph
+	for _, p := range paragraphs {
+		if strings.EqualFold(p.class, used.Class) {
+			own = append(own, p)
+		}
+	}
+	for i, p := range own {
+		text, spans, _ := parseHTMLTags(strings.Split(p.text, "\n"), true)
+
+		// Paragraphs without text clear the screen
+		if len(strings.TrimSpace(strings.Join(text, ""))) == 0 {
+			continue
+		}
+		for j := range text {
+			text[j] = strings.TrimSpace(text[j])
+		}
+		if spans != nil {
+			text, spans = trimSpanLines(spans)
+		}
+
+		end := p.end
+		if end == 0 {
+			end = p.start + samiLastDuration
+			if i+1 < len(own) {
+				end = own[i+1].start
+			}
+		}
+		seq := len(ret) + 1
+		if end < p.start {
+			warn(p.line, seq, p.text, "end time before start time")
+		}
+		if len(ret) > 0 && p.start < ret[len(ret)-1].Start {
+			warn(p.line, seq, p.text, "timestamp out of order")
+		}
+		ret = append(ret, models.ModelItemSubtitle{Seq: seq, Start: p.start, End: end, Text: text, Spans: spans})
+	}
+
+	// If no paragraphs were found, return an error
+	if len(ret) == 0 {
+		return nil, used, warnings, &ParseError{Format: "SAMI", Text: used.Class, Reason: "Invalid SAMI: no paragraphs found"}
+	}
+	return ret, used, warnings, nil
+}
+
+// parseSAMI parses the language classes and the paragraphs of SAMI formatted content.
+// The text of the paragraphs has one line per <br> and the whitespace collapsed.
+func parseSAMI(content string) (languages []SAMILanguage, paragraphs []samiParagraph, err error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+	if !regexp.MustCompile(`(?i)<sync\b`).MatchString(content) {
+		return nil, nil, &ParseError{Format: "SAMI", Line: 1, Column: 1, Reason: "Invalid SAMI: missing SYNC element"}
+	}
+
+	// Languages declared in the STYLE element
+	declared := map[string]bool{}
+	if style := regexp.MustCompile(`(?is)<style\b[^>]*>(.*?)</style>`).FindStringSubmatch(content); len(style) == 2 {
+		for _, rule := range regexp.MustCompile(`\.([\w-]+)\s*\{([^}]*)\}`).FindAllStringSubmatch(style[1], -1) {
+			l := SAMILanguage{Class: rule[1]}
+			for _, property := range strings.Split(rule[2], ";") {
+				parts := strings.SplitN(property, ":", 2)
+				if len(parts) != 2 {
+					continue
+				}
+				switch strings.ToLower(strings.TrimSpace(parts[0])) {
+				case "name":
+					l.Name = strings.TrimSpace(parts[1])
+				case "lang":
+					l.Lang = strings.TrimSpace(parts[1])
+				}
+			}
+			declared[strings.ToLower(l.Class)] = true
+			languages = append(languages, l)
+		}
+	}
+
+	// Remove the comments of the body and split it by SYNC
+	body := content
+	if loc := regexp.MustCompile(`(?i)<body\b[^>]*>`).FindStringIndex(content); loc != nil {
+		body = content[loc[1]:]
+	}
+	offset := strings.Count(content[:len(content)-len(body)], "\n")
+	body = regexp.MustCompile(`(?s)<!--.*?-->`).ReplaceAllStringFunc(body, func(comment string) string {
+		// Keep the line breaks of the comments for the line numbers
+		return strings.Repeat("\n", strings.Count(comment, "\n"))
+	})
+	body = regexp.MustCompile(`(?i)</(?:body|sami)\s*>`).ReplaceAllString(body, "")
+
+	syncExp := regexp.MustCompile(`(?i)<sync\b([^>]*)>`)
+	pExp := regexp.MustCompile(`(?i)<p\b([^>]*)>`)
+	locs := syncExp.FindAllStringSubmatchIndex(body, -1)
+	for i, loc := range locs {
+		attrs := body[loc[2]:loc[3]]
+		startValue := htmlAttribute(attrs, "start")
+		if len(startValue) == 0 {
+			continue
+		}
+		next := len(body)
+		if i+1 < len(locs) {
+			next = locs[i+1][0]
+		}
+		block := body[loc[1]:next]
+		line := offset + strings.Count(body[:loc[0]], "\n") + 1
+		start := time.Duration(toInt(startValue)) * time.Millisecond
+		end := time.Duration(toInt(htmlAttribute(attrs, "end"))) * time.Millisecond
+
+		// Each paragraph of the block belongs to the language of its class
+		pLocs := pExp.FindAllStringSubmatchIndex(block, -1)
+		if len(pLocs) == 0 {
+			pLocs = [][]int{{0, 0, 0, 0}}
+		}
+		for j, pLoc := range pLocs {
+			class := htmlAttribute(block[pLoc[2]:pLoc[3]], "class")
+			pNext := len(block)
+			if j+1 < len(pLocs) {
+				pNext = pLocs[j+1][0]
+			}
+			text := regexp.MustCompile(`(?i)</?(?:p|sync)\b[^>]*>`).ReplaceAllString(block[pLoc[1]:pNext], "")
+			text = regexp.MustCompile(`\s+`).ReplaceAllString(text, " ")
+			text = regexp.MustCompile(`(?i)\s*<br\s*/?>\s*`).ReplaceAllString(text, "\n")
+			paragraphs = append(paragraphs, samiParagraph{class: class, start: start, end: end, text: text, line: line})
+
+			// Languages used without a declaration
+			if !declared[strings.ToLower(class)] {
+				declared[strings.ToLower(class)] = true
+				languages = append(languages, SAMILanguage{Class: class})
+			}
+		}
+	}
+	return languages, paragraphs, nil
+}
+
+// formatSAMIClass returns the class name of a language tag (e.g., "KOKRCC" for "ko-KR").
+func formatSAMIClass(lang string) string {
+	return strings.ToUpper(regexp.MustCompile(`[^A-Za-z0-9]`).ReplaceAllString(lang, "")) + "CC"
+}
+
+// formatSAMIText converts the lines of a subtitle to SAMI text, with the styling tags
+// and the lines separated by <br>.
+func formatSAMIText(item *models.ModelItemSubtitle) string {
+	escape := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	lines := make([]string, len(item.Text))
+	for j := range item.Text {
+		if spans := lineSpans(item, j); spans != nil {
+			escaped := make([]models.ModelSpan, len(spans))
+			for k := range spans {
+				escaped[k] = spans[k]
+				escaped[k].Text = escape.Replace(spans[k].Text)
+			}
+			lines[j] = formatSpans2HTML(escaped)
+			continue
+		}
+		lines[j] = escape.Replace(cleanText(item.Text[j]))
+	}
+	return strings.Join(lines, "<br>")
+}
+
+// WriteSAMI converts subtitle data from the internal model to SAMI formatted content with one
+// language, declared with the Language of the subtitle ("en-US" if empty).
+func WriteSAMI(sub *models.Subtitle) (content string) {
+	return WriteSAMILanguages([]*models.Subtitle{sub})
+}
+
+// WriteSAMILanguages converts the subtitle data of several languages to one SAMI formatted
+// content, with a class for the Language of each subtitle ("en-US" if empty).
+func WriteSAMILanguages(subs []*models.Subtitle) (content string) {
+	type samiSync struct {
+		start time.Duration
+		class string
+		text  string
+	}
+	var syncs []samiSync
+
+	var b strings.Builder
+	b.WriteString("<SAMI>\n<HEAD>\n<STYLE TYPE=\"text/css\">\n<!--\n")
+	b.WriteString("P { font-family: Arial; font-weight: normal; color: white; background-color: black; text-align: center; }\n")
+	for _, sub := range subs {
+		lang := sub.Language
+		if len(lang) == 0 {
+			lang = "en-US"
+		}
+		class := formatSAMIClass(lang)
+		fmt.Fprintf(&b, ".%s { Name: %s; lang: %s; SAMIType: CC; }\n", class, lang, lang)
+
+		// Each subtitle is followed by a clear when the next one doesn't start at its end
+		for i := range sub.Lines {
+			syncs = append(syncs, samiSync{sub.Lines[i].Start, class, formatSAMIText(&sub.Lines[i])})
+			if i+1 == len(sub.Lines) || sub.Lines[i+1].Start > sub.Lines[i].End {
+				syncs = append(syncs, samiSync{sub.Lines[i].End, class, "&nbsp;"})
+			}
+		}
+	}
+	b.WriteString("-->\n</STYLE>\n</HEAD>\n<BODY>\n")
+
+	// The synchronization points of all the languages are written in time order
+	sort.SliceStable(syncs, func(i, j int) bool { return syncs[i].start < syncs[j].start })
+	for _, sync := range syncs {
+		fmt.Fprintf(&b, "<SYNC Start=%d><P Class=%s>%s\n", sync.start.Milliseconds(), sync.class, sync.text)
+	}
+	b.WriteString("</BODY>\n</SAMI>\n")
+	return b.String()
+}
+
+// samiFormat implements the Format interface for SAMI.
+type samiFormat struct{}
+
+func init() {
+	Register(samiFormat{})
+}
+
+// Name returns the name of the SAMI format.
+func (samiFormat) Name() string { return "SAMI" }
+
+// Extensions returns the file extensions of the SAMI format.
+func (samiFormat) Extensions() []string { return []string{".smi", ".sami"} }
+
+// Probe returns the confidence that the content is SAMI: a SAMI element, or SYNC elements.
+func (samiFormat) Probe(content string) float64 {
+	if regexp.MustCompile(`(?i)<sami\b`).MatchString(content) {
+		return 1
+	}
+	if regexp.MustCompile(`(?i)<sync\s+start\s*=`).MatchString(content) {
+		return 0.8
+	}
+	return 0
+}
+
+// Read parses the language named by sub.Language (the first language if empty) of SAMI
+// content into sub, and sets sub.Language to the language tag (or class) read.
+func (samiFormat) Read(content string, sub *models.Subtitle) (err error) {
+	var used SAMILanguage
+	sub.Lines, used, sub.Warnings, err = readSAMI(content, sub.Language)
+	if err == nil {
+		sub.Language = used.Lang
+		if len(sub.Language) == 0 {
+			sub.Language = used.Class
+		}
+	}
+	return err
+}
+
+// Write converts the subtitle to SAMI content.
+func (samiFormat) Write(sub *models.Subtitle) string { return WriteSAMI(sub) }
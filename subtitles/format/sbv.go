@@ -0,0 +1,96 @@
+package format
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+)
+
+/*
+SBV (YouTube) Format Specification:
+
+Blocks of a timing line and the text, separated by empty lines.
+
+Regular Expression for timing line validation:
+^(\d+):(\d{2}):(\d{2})\.(\d{3}),(\d+):(\d{2}):(\d{2})\.(\d{3})$
+
+Example SBV Format:
+0:02:17.440,0:02:20.375
+Senator, we're making
+our final approach into Coruscant.
+
+0:02:20.476,0:02:22.501
+Very good, Lieutenant.
+*/
+
+// ReadSBV parses YouTube SBV formatted subtitle content and converts it to the internal model.
+// Returns a *ParseError if the content is not a valid SBV format.
+func ReadSBV(content string) (ret []models.ModelItemSubtitle, err error) {
+	ret, _, err = readSBV(content)
+	return ret, err
+}
+
+// readSBV parses SBV formatted subtitle content like ReadSBV, and also returns the problems
+// found in the subtitles that were parsed or skipped as warnings.
+func readSBV(content string) (ret []models.ModelItemSubtitle, warnings []error, err error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+	ret, warnings = readTimedBlocks("SBV", strings.Split(content, "\n"), `(\d+):(\d{2}):(\d{2})\.(\d{3})`, "\n")
+
+	// If no subtitles were found, return an error
+	if len(ret) == 0 {
+		return nil, warnings, &ParseError{Format: "SBV", Reason: "Invalid SBV: no subtitles found"}
+	}
+
+	return ret, warnings, nil
+}
+
+// formatDuration2SBV converts a time.Duration to SBV time format string (h:mm:ss.mmm).
+func formatDuration2SBV(d time.Duration) string {
+	return fmt.Sprintf("%d:%02d:%02d.%03d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60, int(d.Milliseconds())%1000)
+}
+
+// WriteSBV converts subtitle data from the internal model to SBV formatted content.
+func WriteSBV(sub *models.Subtitle) (content string) {
+	var b strings.Builder
+	for i := range sub.Lines {
+		fmt.Fprintf(&b, "%s,%s\n", formatDuration2SBV(sub.Lines[i].Start), formatDuration2SBV(sub.Lines[i].End))
+		for j := range sub.Lines[i].Text {
+			b.WriteString(cleanText(sub.Lines[i].Text[j]) + "\n")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// sbvFormat implements the Format interface for SBV.
+type sbvFormat struct{}
+
+func init() {
+	Register(sbvFormat{})
+}
+
+// Name returns the name of the SBV format.
+func (sbvFormat) Name() string { return "SBV" }
+
+// Extensions returns the file extensions of the SBV format.
+func (sbvFormat) Extensions() []string { return []string{".sbv"} }
+
+// Probe returns the confidence that the content is SBV: a timing line with milliseconds.
+func (sbvFormat) Probe(content string) float64 {
+	if regexp.MustCompile(`(?m)^\d+:\d{2}:\d{2}\.\d{3},\d+:\d{2}:\d{2}\.\d{3}[ \t]*$`).MatchString(content) {
+		return 0.9
+	}
+	return 0
+}
+
+// Read parses SBV content into sub.
+func (sbvFormat) Read(content string, sub *models.Subtitle) (err error) {
+	sub.Lines, sub.Warnings, err = readSBV(content)
+	return err
+}
+
+// Write converts the subtitle to SBV content.
+func (sbvFormat) Write(sub *models.Subtitle) string { return WriteSBV(sub) }
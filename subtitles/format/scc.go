@@ -0,0 +1,768 @@
+package format
+
+import (
+	"fmt"
+	"math/bits"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+	"golang.org/x/text/unicode/norm"
+)
+
+/*
+Scenarist SCC Format Specification (CEA-608 closed captions):
+
+The first line is "Scenarist_SCC V1.0". Each other line is a SMPTE timecode at 29.97 frames
+per second (HH:MM:SS;FF for drop-frame, HH:MM:SS:FF for non-drop-frame), a tab and CEA-608
+byte pairs in hexadecimal, one pair per frame. The bytes have odd parity in the high bit.
+
+Regular Expression for caption line validation:
+^(\d{2}):(\d{2}):(\d{2})([:;.,])(\d{2})\s+(.*)$
+
+Byte pairs (without parity, channel 1; channel 2 uses the first byte + 0x08):
+0x20-0x7F 0x20-0x7F  Two standard characters (mostly ASCII, e.g., 0x2A is á)
+0x14 0x20            RCL: Resume Caption Loading (pop-on)
+0x14 0x25-0x27       RU2-RU4: Roll-Up Captions with 2 to 4 rows
+0x14 0x29            RDC: Resume Direct Captioning (paint-on)
+0x14 0x2C/0x2E/0x2F  EDM/ENM/EOC: Erase Displayed Memory, Erase Non-displayed Memory, End Of Caption
+0x14 0x21/0x24/0x2D  BS/DER/CR: Backspace, Delete to End of Row, Carriage Return
+0x10-0x17 0x40-0x7F  Preamble Address Codes (row 1-15, indent, color, italics, underline)
+0x11 0x20-0x2F       Mid-row codes (color, italics, underline), shown as a space
+0x11 0x30-0x3F       Special characters (e.g., ♪)
+0x12/0x13 0x20-0x3F  Extended characters, replacing the previous character (e.g., Á)
+0x17 0x21-0x23       Tab offsets of 1 to 3 columns
+
+Control codes are sent twice; the repeated code is ignored.
+
+Example SCC Format:
+Scenarist_SCC V1.0
+
+00:00:00;22	9420 9420 94ae 94ae 9452 9452 97a2 97a2 c845 4c4c 4f80 942f 942f
+
+00:00:02;10	942c 942c
+*/
+
+// sccFrameRate is the frame rate of the SCC timecodes, sccColumns and sccRows the size of
+// the caption grid.
+const (
+	sccFrameRate = 30000.0 / 1001
+	sccColumns   = 32
+	sccRows      = 15
+)
+
+// sccLastDuration is the duration of a caption still displayed at the end of the content.
+const sccLastDuration = 3 * time.Second
+
+// sccChars maps the standard characters of CEA-608 that differ from ASCII.
+var sccChars = map[byte]rune{
+	0x2A: 'á', 0x5C: 'é', 0x5E: 'í', 0x5F: 'ó', 0x60: 'ú', 0x7B: 'ç', 0x7C: '÷', 0x7D: 'Ñ', 0x7E: 'ñ', 0x7F: '█',
+}
+
+// sccSpecial has the special characters 0x30-0x3F (the transparent space is a no-break space).
+var sccSpecial = []rune("®°½¿™¢£♪à\u00A0èâêîôû")
+
+// sccExtended has the extended characters 0x20-0x3F of the first bytes 0x12 and 0x13.
+var sccExtended = [][]rune{
+	[]rune("ÁÉÓÚÜü‘¡*’—©℠•“”ÀÂÇÈÊËëÎÏïÔÙùÛ«»"),
+	[]rune("ÃãÍÌìÒòÕõ{}\\^_|~ÄäÖöß¥¤¦ÅåØø┌┐└┘"),
+}
+
+// sccColors has the colors of the preamble address and mid-row codes. White is the default color.
+var sccColors = []string{"", "#00FF00", "#0000FF", "#00FFFF", "#FF0000", "#FFFF00", "#FF00FF"}
+
+// sccPACRows maps the first byte of the preamble address codes to its two rows.
+var sccPACRows = map[byte][2]int{
+	0x11: {1, 2}, 0x12: {3, 4}, 0x15: {5, 6}, 0x16: {7, 8}, 0x17: {9, 10}, 0x10: {11, 11}, 0x13: {12, 13}, 0x14: {14, 15},
+}
+
+// sccCell is a character of the caption grid with its style. Mid-row codes are shown as
+// a space cell.
+type sccCell struct {
+	char   rune
+	style  models.ModelSpan
+	midRow bool
+}
+
+// sccMemory is the caption grid of the displayed or non-displayed memory.
+type sccMemory [sccRows][sccColumns]sccCell
+
+// sccDecoder is the state of the CEA-608 decoder of the channel 1.
+type sccDecoder struct {
+	displayed    sccMemory
+	nonDisplayed sccMemory
+	mode         byte // control code of the caption mode: RCL (pop-on), RU2-RU4 (roll-up) or RDC (paint-on)
+	row, col     int
+	style        models.ModelSpan
+	dirty        bool // the displayed memory changed
+}
+
+// memory returns the memory written by the caption mode.
+func (d *sccDecoder) memory() *sccMemory {
+	if d.mode == 0x20 {
+		return &d.nonDisplayed
+	}
+	d.dirty = true
+	return &d.displayed
+}
+
+// write writes a character at the cursor and moves the cursor to the next column.
+func (d *sccDecoder) write(char rune, midRow bool) {
+	if d.col >= sccColumns {
+		d.col = sccColumns - 1
+	}
+	d.memory()[d.row][d.col] = sccCell{char: char, style: d.style, midRow: midRow}
+	d.col++
+}
+
+// control applies a control code (without parity and channel bit).
+func (d *sccDecoder) control(b1 byte, b2 byte) {
+	switch {
+	case (b1 == 0x14 || b1 == 0x15) && b2 >= 0x20 && b2 <= 0x2F:
+		d.command(b2)
+	case b1 == 0x17 && b2 >= 0x21 && b2 <= 0x23:
+		// Tab offset
+		d.col += int(b2 - 0x20)
+		if d.col >= sccColumns {
+			d.col = sccColumns - 1
+		}
+	case b1 == 0x11 && b2 >= 0x20 && b2 <= 0x2F:
+		// Mid-row code, shown as a space with the previous style
+		d.write(' ', true)
+		if b2&0x0E == 0x0E {
+			d.style.Italic = true
+		} else {
+			d.style.Color = sccColors[(b2&0x0E)>>1]
+			d.style.Italic = false
+		}
+		d.style.Underline = b2&0x01 == 1
+	case b1 == 0x11 && b2 >= 0x30 && b2 <= 0x3F:
+		d.write(sccSpecial[b2-0x30], false)
+	case (b1 == 0x12 || b1 == 0x13) && b2 >= 0x20 && b2 <= 0x3F:
+		// Extended characters replace the previous (standard) character
+		if d.col > 0 {
+			d.col--
+		}
+		d.write(sccExtended[b1-0x12][b2-0x20], false)
+	case b2 >= 0x40 && b2 <= 0x7F:
+		d.address(b1, b2)
+	}
+}
+
+// command applies a miscellaneous control code.
+func (d *sccDecoder) command(code byte) {
+	switch code {
+	case 0x20, 0x29:
+		d.mode = code
+	case 0x25, 0x26, 0x27:
+		// Entering roll-up mode erases the memories
+		if d.mode < 0x25 || d.mode > 0x27 {
+			d.displayed, d.nonDisplayed = sccMemory{}, sccMemory{}
+			d.row, d.col, d.dirty = sccRows-1, 0, true
+		}
+		d.mode = code
+	case 0x21:
+		if d.col > 0 {
+			d.col--
+			d.memory()[d.row][d.col] = sccCell{}
+		}
+	case 0x24:
+		memory := d.memory()
+		for col := d.col; col < sccColumns; col++ {
+			memory[d.row][col] = sccCell{}
+		}
+	case 0x2C:
+		d.displayed, d.dirty = sccMemory{}, true
+	case 0x2E:
+		d.nonDisplayed = sccMemory{}
+	case 0x2F:
+		d.displayed, d.nonDisplayed, d.dirty = d.nonDisplayed, d.displayed, true
+	case 0x2D:
+		// Carriage return rolls up the rows of the roll-up window
+		if d.mode >= 0x25 && d.mode <= 0x27 {
+			rows := int(d.mode-0x25) + 2
+			for row := 0; row < d.row; row++ {
+				if row > d.row-rows {
+					d.displayed[row] = d.displayed[row+1]
+				} else {
+					d.displayed[row] = [sccColumns]sccCell{}
+				}
+			}
+			d.displayed[d.row], d.dirty = [sccColumns]sccCell{}, true
+		}
+		d.col = 0
+	}
+}
+
+// address applies a preamble address code, which moves the cursor to a row and indent
+// and sets the style.
+func (d *sccDecoder) address(b1 byte, b2 byte) {
+	rows, ok := sccPACRows[b1]
+	if !ok {
+		return
+	}
+	row := rows[0] - 1
+	if b2&0x20 != 0 {
+		row = rows[1] - 1
+	}
+
+	// The roll-up window moves with its base row
+	if d.mode >= 0x25 && d.mode <= 0x27 && row != d.row {
+		moved := sccMemory{}
+		for r := 0; r < int(d.mode-0x25)+2 && r <= d.row && r <= row; r++ {
+			moved[row-r] = d.displayed[d.row-r]
+		}
+		d.displayed, d.dirty = moved, true
+	}
+
+	d.row, d.col = row, 0
+	d.style = models.ModelSpan{Underline: b2&0x01 == 1}
+	if attribute := b2 & 0x1E; attribute >= 0x10 {
+		d.col = int(attribute-0x10) * 2
+	} else if attribute == 0x0E {
+		d.style.Italic = true
+	} else {
+		d.style.Color = sccColors[attribute>>1]
+	}
+}
+
+// text returns the plain lines and runs of the displayed memory, and its first row (1-15).
+// Consecutive mid-row codes are shown as a single space.
+func (m *sccMemory) text() (lines []string, spans [][]models.ModelSpan, top int) {
+	var rows [][]models.ModelSpan
+	for row := range m {
+		var runs []models.ModelSpan
+		var style models.ModelSpan
+		for col, cell := range m[row] {
+			switch {
+			case cell.char == 0:
+				runs = appendSpan(runs, style, " ")
+			case cell.midRow && col > 0 && m[row][col-1].midRow:
+				continue
+			default:
+				style = cell.style
+				runs = appendSpan(runs, style, string(cell.char))
+			}
+		}
+		if len(strings.TrimSpace(spansText(runs))) > 0 {
+			if top == 0 {
+				top = row + 1
+			}
+			rows = append(rows, runs)
+		}
+	}
+	lines, spans = trimSpanLines(rows)
+	return lines, spans, top
+}
+
+// ReadSCC parses Scenarist SCC (CEA-608) closed caption content of the channel 1 and converts
+// it to the internal model. Pop-on, roll-up and paint-on captions are converted to a subtitle
+// each time the displayed captions change. The first row of the captions is stored in
+// Settings.Line.
+// Returns a *ParseError if the content is not a valid SCC format.
+func ReadSCC(content string) (ret []models.ModelItemSubtitle, err error) {
+	ret, _, err = readSCC(content)
+	return ret, err
+}
+
+// readSCC parses SCC formatted content like ReadSCC, and also returns the problems found in
+// the caption lines as warnings.
+func readSCC(content string) (ret []models.ModelItemSubtitle, warnings []error, err error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+	lines := strings.Split(content, "\n")
+	if !strings.HasPrefix(strings.TrimSpace(lines[0]), "Scenarist_SCC V1.0") {
+		return nil, nil, &ParseError{Format: "SCC", Line: 1, Column: 1, Text: cleanText(lines[0]), Reason: "Invalid SCC: missing Scenarist_SCC V1.0 header"}
+	}
+
+	// warn records a problem of a line
+	warn := func(line int, column int, text string, reason string) {
+		warnings = append(warnings, &ParseError{Format: "SCC", Line: line, Column: column, Cue: len(ret) + 1, Text: text, Reason: reason})
+	}
+
+	exp := regexp.MustCompile(`^(\d{2}):(\d{2}):(\d{2})([:;.,])(\d{2})\s+(.*)$`)
+	decoder := sccDecoder{mode: 0x20, row: sccRows - 1}
+	var item *models.ModelItemSubtitle
+	var last time.Duration
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			continue
+		}
+		res := exp.FindStringSubmatch(line)
+		if len(res) != 7 {
+			warn(i+1, 1, line, "unexpected line, expected timecode and byte pairs")
+			continue
+		}
+		frame := parseSCCTimecode(toInt(res[1]), toInt(res[2]), toInt(res[3]), toInt(res[5]), res[4] == ";" || res[4] == ",")
+		if start := formatFrame2Duration(frame, sccFrameRate); start < last {
+			warn(i+1, 1, line, "timestamp out of order")
+		}
+
+		// Decode the byte pairs, one per frame
+		var changed time.Duration
+		decoder.dirty = false
+		channel := 1
+		var previous string
+		for w, word := range strings.Fields(res[6]) {
+			value, errWord := strconv.ParseUint(word, 16, 16)
+			if errWord != nil || len(word) != 4 {
+				warn(i+1, strings.Index(line, word)+1, word, "invalid byte pair")
+				continue
+			}
+			at := formatFrame2Duration(frame+w, sccFrameRate)
+			last = at
+			b1, b2 := byte(value>>8)&0x7F, byte(value)&0x7F
+			dirty := decoder.dirty
+
+			switch {
+			case b1 >= 0x10 && b1 <= 0x1F:
+				// Control codes are sent twice; the repeated code is ignored
+				if word == previous {
+					previous = ""
+					continue
+				}
+				previous = word
+				channel = 1 + int(b1&0x08)>>3
+				if channel == 1 {
+					decoder.control(b1&^0x08, b2)
+				}
+			case b1 >= 0x20 && channel == 1:
+				previous = ""
+				decoder.write(formatSCCChar(b1), false)
+				if b2 >= 0x20 {
+					decoder.write(formatSCCChar(b2), false)
+				}
+			default:
+				previous = ""
+			}
+			if decoder.dirty && !dirty {
+				changed = at
+			}
+		}
+		if !decoder.dirty {
+			continue
+		}
+
+		// A subtitle ends and another one starts when the displayed captions change
+		text, spans, top := decoder.displayed.text()
+		if item != nil && strings.Join(item.Text, "\n") == strings.Join(text, "\n") && fmt.Sprint(item.Spans) == fmt.Sprint(spans) {
+			continue
+		}
+		if item != nil {
+			item.End = changed
+			ret = append(ret, *item)
+			item = nil
+		}
+		if len(text) > 0 {
+			item = &models.ModelItemSubtitle{Seq: len(ret) + 1, Start: changed, Text: text, Spans: spans}
+			item.Settings.Line = strconv.Itoa(top)
+		}
+	}
+	if item != nil {
+		item.End = item.Start + sccLastDuration
+		ret = append(ret, *item)
+		warn(len(lines), 1, "", fmt.Sprintf("cue %d is not erased, displayed for %v", item.Seq, sccLastDuration))
+	}
+
+	// If no captions were found, return an error
+	if len(ret) == 0 {
+		return nil, warnings, &ParseError{Format: "SCC", Reason: "Invalid SCC: no captions found"}
+	}
+	return ret, warnings, nil
+}
+
+// parseSCCTimecode converts a SMPTE timecode to a frame number at 29.97 frames per second.
+// Drop-frame timecodes skip the frames 0 and 1 of each minute, except every tenth minute.
+func parseSCCTimecode(hours int, minutes int, seconds int, frames int, drop bool) int {
+	frame := (hours*3600+minutes*60+seconds)*30 + frames
+	if drop {
+		totalMinutes := hours*60 + minutes
+		frame -= 2 * (totalMinutes - totalMinutes/10)
+	}
+	return frame
+}
+
+// formatSCCTimecode converts a frame number at 29.97 frames per second to a drop-frame
+// SMPTE timecode (HH:MM:SS;FF).
+func formatSCCTimecode(frame int) string {
+	if frame < 0 {
+		frame = 0
+	}
+	tens, rest := frame/17982, frame%17982
+	frame += 18 * tens
+	if rest > 1 {
+		frame += 2 * ((rest - 2) / 1798)
+	}
+	return fmt.Sprintf("%02d:%02d:%02d;%02d", frame/108000, frame/1800%60, frame/30%60, frame%30)
+}
+
+// formatSCCChar converts a standard character of CEA-608 to a rune.
+func formatSCCChar(b byte) rune {
+	if r, ok := sccChars[b]; ok {
+		return r
+	}
+	return rune(b)
+}
+
+// sccEncoder builds the CEA-608 byte pairs of captions, packing the standard characters in pairs.
+type sccEncoder struct {
+	words   []uint16
+	pending []byte
+}
+
+// flush writes the pending standard character, padded with a null byte.
+func (e *sccEncoder) flush() {
+	if len(e.pending) == 1 {
+		e.words = append(e.words, uint16(e.pending[0])<<8)
+	}
+	e.pending = nil
+}
+
+// char writes a standard character.
+func (e *sccEncoder) char(b byte) {
+	e.pending = append(e.pending, b)
+	if len(e.pending) == 2 {
+		e.words = append(e.words, uint16(e.pending[0])<<8|uint16(e.pending[1]))
+		e.pending = nil
+	}
+}
+
+// control writes a control code twice.
+func (e *sccEncoder) control(code uint16) {
+	e.flush()
+	e.words = append(e.words, code, code)
+}
+
+// write writes a character as a standard, special or extended character. Extended characters
+// are preceded by a standard character for the decoders without them. Characters that can't
+// be represented are written as their base letter, or a space.
+func (e *sccEncoder) write(r rune) {
+	if b, ok := encodeSCCChar(r); ok {
+		e.char(b)
+		return
+	}
+	for code, c := range sccSpecial {
+		if c == r {
+			e.control(0x1130 + uint16(code))
+			return
+		}
+	}
+	base := byte(' ')
+	if b, ok := encodeSCCChar([]rune(norm.NFD.String(string(r)))[0]); ok {
+		base = b
+	}
+	for table := range sccExtended {
+		for code, c := range sccExtended[table] {
+			if c == r {
+				e.char(base)
+				e.control(uint16(0x12+table)<<8 + 0x20 + uint16(code))
+				return
+			}
+		}
+	}
+	e.char(base)
+}
+
+// encodeSCCChar converts a rune to a standard character of CEA-608.
+// Returns false if the rune is not a standard character.
+func encodeSCCChar(r rune) (byte, bool) {
+	for b, c := range sccChars {
+		if c == r {
+			return b, true
+		}
+	}
+	if _, ok := sccChars[byte(r)]; ok || r < 0x20 || r > 0x7F {
+		return 0, false
+	}
+	return byte(r), true
+}
+
+// sccStyle returns the style of a run that CEA-608 can show: white or one of the caption
+// colors, italics and underline.
+func sccStyle(span models.ModelSpan) models.ModelSpan {
+	style := models.ModelSpan{Italic: span.Italic, Underline: span.Underline}
+	for _, color := range sccColors {
+		if strings.EqualFold(color, span.Color) {
+			style.Color = color
+		}
+	}
+	return style
+}
+
+// sccMidRowCodes returns the mid-row codes that change the style from one to another.
+func sccMidRowCodes(from models.ModelSpan, to models.ModelSpan) (codes []uint16) {
+	if from == to {
+		return nil
+	}
+	underline := uint16(0)
+	if to.Underline {
+		underline = 1
+	}
+	if to.Color != from.Color || !to.Italic {
+		for code, color := range sccColors {
+			if color == to.Color {
+				codes = append(codes, 0x1120+uint16(code)<<1+underline)
+			}
+		}
+	}
+	if to.Italic {
+		codes = append(codes, 0x112E+underline)
+	}
+	return codes
+}
+
+// sccChar is a character of a caption row with its style.
+type sccChar struct {
+	char  rune
+	style models.ModelSpan
+}
+
+// sccWidth returns the number of columns of a caption row. A style change takes a column,
+// replacing the space where it starts.
+func sccWidth(chars []sccChar) (width int) {
+	var style models.ModelSpan
+	for _, c := range chars {
+		if codes := sccMidRowCodes(style, c.style); len(codes) > 0 {
+			width += len(codes)
+			style = c.style
+			if c.char == ' ' {
+				continue
+			}
+		}
+		width++
+	}
+	return width
+}
+
+// formatSCCRows converts the lines of a subtitle to caption rows of up to 32 columns,
+// wrapping the long lines at the spaces.
+func formatSCCRows(item *models.ModelItemSubtitle) (rows [][]sccChar) {
+	for j := range item.Text {
+		spans := lineSpans(item, j)
+		if spans == nil {
+			spans = []models.ModelSpan{{Text: cleanText(item.Text[j])}}
+		}
+		var chars []sccChar
+		for _, span := range spans {
+			for _, r := range cleanControl(span.Text) {
+				chars = append(chars, sccChar{char: r, style: sccStyle(span)})
+			}
+		}
+		for len(chars) > 0 && chars[0].char == ' ' {
+			chars = chars[1:]
+		}
+		for len(chars) > 0 && chars[len(chars)-1].char == ' ' {
+			chars = chars[:len(chars)-1]
+		}
+
+		// The spaces take the style of the next character, so that a style change replaces them
+		for k := len(chars) - 2; k >= 0; k-- {
+			if chars[k].char == ' ' {
+				chars[k].style = chars[k+1].style
+			}
+		}
+
+		for len(chars) > 0 {
+			n := len(chars)
+			if sccWidth(chars) > sccColumns {
+				n = 0
+				for k := range chars {
+					if chars[k].char == ' ' && sccWidth(chars[:k]) <= sccColumns {
+						n = k
+					}
+				}
+				if n == 0 {
+					// No space to wrap at: cut the row at the last character that fits, keeping
+					// at least one character so the wrapping always moves on
+					n = len(chars)
+					if n > sccColumns {
+						n = sccColumns
+					}
+					for n > 1 && sccWidth(chars[:n]) > sccColumns {
+						n--
+					}
+				}
+			}
+			rows = append(rows, chars[:n])
+			chars = chars[n:]
+			for len(chars) > 0 && chars[0].char == ' ' {
+				chars = chars[1:]
+			}
+		}
+	}
+	if len(rows) > sccRows {
+		rows = rows[:sccRows]
+	}
+	return rows
+}
+
+// formatSCCCaption converts a subtitle to the byte pairs that load it as a pop-on caption,
+// without the End Of Caption code. The rows are centered at the bottom of the screen, unless
+// Settings.Line has a row (1-15) or Settings.Align an alignment.
+func formatSCCCaption(item *models.ModelItemSubtitle) []uint16 {
+	rows := formatSCCRows(item)
+	top := sccRows - len(rows) + 1
+	if line, err := strconv.Atoi(item.Settings.Line); err == nil && line > 0 && line <= sccRows {
+		top = line
+	}
+	if top+len(rows)-1 > sccRows {
+		top = sccRows - len(rows) + 1
+	}
+
+	e := &sccEncoder{}
+	e.control(0x1420) // RCL
+	e.control(0x142E) // ENM
+	for i, chars := range rows {
+		// Move to the row and the column of the alignment with a preamble address code and a tab offset
+		width := sccWidth(chars)
+		col := (sccColumns - width) / 2
+		switch item.Settings.Align {
+		case "left", "start":
+			col = 0
+		case "right", "end":
+			col = sccColumns - width
+		}
+		row := top + i
+		var first byte
+		var second uint16
+		for b, pair := range sccPACRows {
+			if pair[0] == row || pair[1] == row {
+				first = b
+				if pair[1] == row && pair[0] != row {
+					second = 0x20
+				}
+			}
+		}
+		e.control(uint16(first)<<8 + 0x50 + second + uint16(col/4)<<1)
+		if col%4 > 0 {
+			e.control(0x1720 + uint16(col%4))
+		}
+
+		// Write the characters with the mid-row codes of the style changes
+		var style models.ModelSpan
+		for _, c := range chars {
+			if codes := sccMidRowCodes(style, c.style); len(codes) > 0 {
+				for _, code := range codes {
+					e.control(code)
+				}
+				style = c.style
+				if c.char == ' ' {
+					continue
+				}
+			}
+			e.write(c.char)
+		}
+	}
+	e.flush()
+	return e.words
+}
+
+// WriteSCC converts subtitle data from the internal model to Scenarist SCC (CEA-608) content
+// with pop-on captions on the channel 1. Each caption is loaded before its start time, shown
+// with End Of Caption at its start time and erased at its end time, with drop-frame timecodes
+// rounded with the FrameRounding of the subtitle. Lines are wrapped at 32 columns.
+func WriteSCC(sub *models.Subtitle) (content string) {
+	var b strings.Builder
+	b.WriteString("Scenarist_SCC V1.0\n")
+
+	// Place the byte pairs in frames, after the frames already used
+	var frames []int
+	var words []uint16
+	next := 0
+	place := func(frame int, codes []uint16) {
+		if frame < next {
+			frame = next
+		}
+		for _, code := range codes {
+			frames = append(frames, frame)
+			words = append(words, code)
+			frame++
+		}
+		next = frame
+	}
+	erase := []uint16{0x142C, 0x142C}
+	display := []uint16{0x142F, 0x142F}
+	for i := range sub.Lines {
+		item := &sub.Lines[i]
+		start := formatDuration2Frame(item.Start, sccFrameRate, sub.FrameRounding)
+		caption := formatSCCCaption(item)
+
+		// Load the caption while the previous one is displayed, and erase the previous one
+		// at its end time when there is a gap
+		if i > 0 {
+			end := formatDuration2Frame(sub.Lines[i-1].End, sccFrameRate, sub.FrameRounding)
+			if end+len(erase) > start {
+				place(start-len(caption), caption)
+			} else if start-len(caption) < end+len(erase) {
+				place(end-len(caption), caption)
+				place(end, erase)
+			} else {
+				place(end, erase)
+				place(start-len(caption), caption)
+			}
+		} else {
+			place(start-len(caption), caption)
+		}
+		place(start, display)
+	}
+	if len(sub.Lines) > 0 {
+		place(formatDuration2Frame(sub.Lines[len(sub.Lines)-1].End, sccFrameRate, sub.FrameRounding), erase)
+	}
+
+	// Write a line for each run of consecutive frames
+	for i := range words {
+		if i == 0 || frames[i] != frames[i-1]+1 {
+			b.WriteString("\n" + formatSCCTimecode(frames[i]) + "\t")
+		} else {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%02x%02x", formatSCCParity(byte(words[i]>>8)), formatSCCParity(byte(words[i])))
+		if i+1 == len(words) || frames[i+1] != frames[i]+1 {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// formatSCCParity sets the high bit of a byte for odd parity.
+func formatSCCParity(b byte) byte {
+	if bits.OnesCount8(b)%2 == 0 {
+		return b | 0x80
+	}
+	return b
+}
+
+// sccFormat implements the Format interface for Scenarist SCC.
+type sccFormat struct{}
+
+func init() {
+	Register(sccFormat{})
+}
+
+// Name returns the name of the SCC format.
+func (sccFormat) Name() string { return "SCC" }
+
+// Extensions returns the file extensions of the SCC format.
+func (sccFormat) Extensions() []string { return []string{".scc"} }
+
+// Probe returns the confidence that the content is SCC: the Scenarist_SCC header.
+func (sccFormat) Probe(content string) float64 {
+	if strings.HasPrefix(strings.TrimSpace(strings.TrimPrefix(content, "\ufeff")), "Scenarist_SCC V1.0") {
+		return 1
+	}
+	return 0
+}
+
+// Read parses SCC content into sub, with the frame rate of the timecodes.
+func (sccFormat) Read(content string, sub *models.Subtitle) (err error) {
+	sub.Lines, sub.Warnings, err = readSCC(content)
+	if err == nil {
+		sub.FrameRate = sccFrameRate
+	}
+	return err
+}
+
+// Write converts the subtitle to SCC content.
+func (sccFormat) Write(sub *models.Subtitle) string { return WriteSCC(sub) }
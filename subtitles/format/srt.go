@@ -17,11 +17,13 @@ SRT Format Specification:
 Regular Expression for validation:
 (\d)\n((\d*)\:(\d*)\:(\d*)[\.,\:](\d*) --> (\d*)\:(\d*)\:(\d*)[\.,\:](\d*))\n((?:\n?.)*?)\n\n
 
+Styling tags: <i>, <b>, <u>, <s> and <font color="#RRGGBB" face="Name">
+
 Example SRT Format:
 1
 00:02:17,440 --> 00:02:20,375
 Senator, we're making
-our final approach into Coruscant.
+our <i>final approach</i> into Coruscant.
 
 2
 00:02:20,476 --> 00:02:22,501
@@ -29,25 +31,79 @@ Very good, Lieutenant.
 */
 
 // ReadSRT parses SRT formatted subtitle content and converts it to the internal model.
-// Returns an error if the content is not a valid SRT format.
+// Returns a *ParseError if the content is not a valid SRT format.
 func ReadSRT(content string) (ret []models.ModelItemSubtitle, err error) {
-	content = cleanText(content)
+	ret, _, err = readSRT(content)
+	return ret, err
+}
+
+// readSRT parses SRT formatted subtitle content like ReadSRT, and also returns the
+// problems found in the cues that were parsed or skipped as warnings.
+func readSRT(content string) (ret []models.ModelItemSubtitle, warnings []error, err error) {
+	content = strings.TrimPrefix(content, "\ufeff")
 
-	// Split content into lines
-	lines := strings.Split(content, "\n")
+	// Split content into lines, keeping the line numbers of the original content
+	ret, warnings = readSRTLines(strings.Split(content, "\n"), StreamPosition{Line: 1, Cue: 1})
+
+	// If no subtitles were found, return an error
+	if len(ret) == 0 {
+		return nil, warnings, &ParseError{Format: "SRT", Reason: "Invalid SRT: no subtitles found"}
+	}
 
+	return ret, warnings, nil
+}
+
+// readSRTLines parses lines of SRT content at a position of the content into cues, and also
+// returns the problems found in the cues that were parsed or skipped as warnings.
+func readSRTLines(lines []string, pos StreamPosition) (ret []models.ModelItemSubtitle, warnings []error) {
 	var currentSubtitle models.ModelItemSubtitle
 	var parsingText bool
-	var hasSubtitles bool
+	var timingLine int
+	var timingText string
+
+	// warn records a problem of the current cue
+	warn := func(line int, cue int, text string, reason string) {
+		warnings = append(warnings, &ParseError{Format: "SRT", Line: line, Column: 1, Cue: cue, Text: text, Reason: reason})
+	}
 
-	for i := 0; i < len(lines); i++ {
-		line := strings.TrimSpace(lines[i])
+	// finish adds the current subtitle to the result, reporting its problems
+	finish := func() {
+		if currentSubtitle.Seq == 0 {
+			warn(timingLine, 0, timingText, "cue without sequence number skipped")
+			return
+		}
+		cue := pos.Cue + len(ret)
+		previous := pos.Previous
+		if len(ret) > 0 {
+			previous = &ret[len(ret)-1]
+		}
+
+		// Keep the styling tags as spans and the plain text as text
+		currentSubtitle.Text, currentSubtitle.Spans, _ = parseHTMLTags(currentSubtitle.Text, false)
+		if len(currentSubtitle.Text) == 0 {
+			warn(timingLine, cue, timingText, fmt.Sprintf("cue %d has no text", cue))
+		}
+		if currentSubtitle.End < currentSubtitle.Start {
+			warn(timingLine, cue, timingText, "end time before start time")
+		}
+		if previous != nil && currentSubtitle.Start < previous.Start {
+			warn(timingLine, cue, timingText, "timestamp out of order")
+		}
+		ret = append(ret, currentSubtitle)
+	}
+
+	// The end of the content closes the last subtitle like an empty line
+	for i := 0; i <= len(lines); i++ {
+		line := ""
+		if i < len(lines) {
+			line = cleanText(lines[i])
+		}
 
 		// Skip empty lines
 		if line == "" {
 			// If we were parsing text, this empty line indicates the end of a subtitle
-			if parsingText && currentSubtitle.Seq > 0 {
-				ret = append(ret, currentSubtitle)
+			if parsingText {
+				finish()
 				currentSubtitle = models.ModelItemSubtitle{}
 				parsingText = false
 			}
@@ -68,26 +124,20 @@ func ReadSRT(content string) (ret []models.ModelItemSubtitle, err error) {
 				currentSubtitle.Start = start
 				currentSubtitle.End = end
 				parsingText = true
-				hasSubtitles = true
+				timingLine = pos.Line + i
+				timingText = line
 				continue
 			}
+
+			// Otherwise the line is skipped
+			warn(pos.Line+i, pos.Cue+len(ret), line, "unexpected line, expected a sequence number or a timestamp")
 		} else {
 			// We're parsing text, add this line to the current subtitle
-			currentSubtitle.Text = append(currentSubtitle.Text, cleanText(line))
+			currentSubtitle.Text = append(currentSubtitle.Text, line)
 		}
 	}
 
-	// Add the last subtitle if we were parsing one
-	if parsingText && currentSubtitle.Seq > 0 {
-		ret = append(ret, currentSubtitle)
-	}
-
-	// If no subtitles were found, return an error
-	if !hasSubtitles || len(ret) == 0 {
-		return nil, errors.New("Invalid SRT")
-	}
-
-	return ret, nil
+	return ret, warnings
 }
 
 // formatStringSRT2Duration parses a time range string in SRT format (00:00:00,000 --> 00:00:00,000)
@@ -119,12 +169,71 @@ func formatDuration2SRT(d time.Duration) string {
 
 // WriteSRT converts subtitle data from the internal model to SRT formatted content.
 func WriteSRT(sub *models.Subtitle) (content string) {
+	var b strings.Builder
 	for i := range sub.Lines {
-		content += fmt.Sprintf("%d\n%s --> %s\n", sub.Lines[i].Seq, formatDuration2SRT(sub.Lines[i].Start), formatDuration2SRT(sub.Lines[i].End))
-		for j := range sub.Lines[i].Text {
-			content += cleanText(sub.Lines[i].Text[j]) + "\n"
+		b.WriteString(writeSRTCue(&sub.Lines[i]))
+	}
+	return b.String()
+}
+
+// writeSRTCue converts a subtitle to a SRT cue, followed by an empty line.
+func writeSRTCue(item *models.ModelItemSubtitle) (content string) {
+	content = fmt.Sprintf("%d\n%s --> %s\n", item.Seq, formatDuration2SRT(item.Start), formatDuration2SRT(item.End))
+	for j := range item.Text {
+		// Write the styling tags while the text is unchanged
+		if spans := lineSpans(item, j); spans != nil {
+			content += formatSpans2HTML(spans) + "\n"
+			continue
 		}
-		content += "\n"
+		content += cleanText(item.Text[j]) + "\n"
 	}
-	return content
+	return content + "\n"
+}
+
+// srtFormat implements the Format interface for SRT.
+type srtFormat struct{}
+
+func init() {
+	Register(srtFormat{})
+}
+
+// Name returns the name of the SRT format.
+func (srtFormat) Name() string { return "SRT" }
+
+// Extensions returns the file extensions of the SRT format.
+func (srtFormat) Extensions() []string { return []string{".srt"} }
+
+// Probe returns the confidence that the content is SRT: a sequence number followed by a
+// timestamp range. Timestamps with a comma, as required by SRT, give a higher confidence.
+func (srtFormat) Probe(content string) float64 {
+	content = cleanText(content)
+	if regexp.MustCompile(`(?m)^\d+[ \t]*\n[ \t]*\d+:\d{2}:\d{2},\d{3}[ \t]*-->`).MatchString(content) {
+		return 0.9
+	}
+	if regexp.MustCompile(`(?m)^\d+[ \t]*\n[ \t]*\d+:\d{2}:\d{2}[\.:]\d{1,3}[ \t]*-->`).MatchString(content) {
+		return 0.6
+	}
+	return 0
+}
+
+// Read parses SRT content into sub.
+func (srtFormat) Read(content string, sub *models.Subtitle) (err error) {
+	sub.Lines, sub.Warnings, err = readSRT(content)
+	return err
+}
+
+// Write converts the subtitle to SRT content.
+func (srtFormat) Write(sub *models.Subtitle) string { return WriteSRT(sub) }
+
+// ReadBlock parses a block of SRT content into its cue.
+func (srtFormat) ReadBlock(block string, pos StreamPosition, sub *models.Subtitle) ([]models.ModelItemSubtitle, []error) {
+	return readSRTLines(strings.Split(strings.TrimPrefix(block, "\ufeff"), "\n"), pos)
+}
+
+// WriteHeader returns the content before the first SRT cue, which is empty.
+func (srtFormat) WriteHeader(sub *models.Subtitle) string { return "" }
+
+// WriteCue converts a subtitle to a SRT cue.
+func (srtFormat) WriteCue(item *models.ModelItemSubtitle, sub *models.Subtitle) string {
+	return writeSRTCue(item)
 }
@@ -1,10 +1,7 @@
 package format
 
 import (
-	"errors"
 	"fmt"
-	"regexp"
-	"strings"
 	"time"
 
 	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
@@ -13,15 +10,10 @@ import (
 /*
 SSA Format Specification:
 
-Regular Expressions for validation:
-- Full SSA validation: 
-\[Script Info\]\n((?:\n?.)*?\n\n)\[(.*?)[ ]?Styles\]\n((?:\n?.)*?\n\n)\[Events\]\n(?:.*\n?)(^Dialogue: (\d),(\d*):(\d*):(\d*).(\d*),(\d*):(\d*):(\d*).(\d*),(\w*),[\w ]*,\d*,\d*,\d*,[\w*]?,{\\pos\(\d*,\d*\)}(.*)$)*
-
-- Sections validation: 
-\[Script Info\]\n((?:\n?.)*?\n\n)\[(.*?)[ ]?Styles\]\n((?:\n?.)*?\n\n)\[Events\]\n((?:\n?.)*)
-
-- Dialogue line validation: 
-^Dialogue: (\d),(\d*):(\d*):(\d*).(\d*),(\d*):(\d*):(\d*).(\d*),(\w*),[\w ]*,\d*,\d*,\d*,[\w*]?,{\\pos\(\d*,\d*\)}(.*)$
+The file is split into sections. The [Events] section starts with a Format:
+line that names the columns of the following Dialogue: lines, which are mapped
+by name (see ass.go). Override blocks such as {\pos(400,570)} are removed from
+the text.
 
 Example SSA Format:
 [Script Info]
@@ -47,55 +39,23 @@ Dialogue: 0,0:00:01.18,0:00:06.85,DefaultVCD, NTP,0000,0000,0000,,{\pos(400,570)
 */
 
 // ReadSSA parses SSA formatted subtitle content and converts it to the internal model.
-// Returns an error if the content is not a valid SSA format.
+// Returns a *ParseError if the content is not a valid SSA format.
 func ReadSSA(content string) (ret []models.ModelItemSubtitle, err error) {
-	// Clean the input content to remove any unnecessary characters
-	content = cleanText(content)
+	ret, _, err = readSSA(content)
+	return ret, err
+}
 
-	// Regular expression to match the full SSA format
-	exp := regexp.MustCompile(`\[Script Info\]\n((?:\n?.)*?\n\n)\[(.*?)[ ]?Styles\]\n((?:\n?.)*?\n\n)\[Events\]\n(?:.*\n?)(^Dialogue: (\d),(\d*):(\d*):(\d*).(\d*),(\d*):(\d*):(\d*).(\d*),(\w*),[\w ]*,\d*,\d*,\d*,[\w*]?,{\\pos\(\d*,\d*\)}(.*)$)*`)
-	if !exp.MatchString(content) {
-		return ret, errors.New("Invalid SSA")
+// readSSA parses SSA formatted subtitle content like ReadSSA, and also returns the
+// problems found in the script as warnings.
+func readSSA(content string) (ret []models.ModelItemSubtitle, warnings []error, err error) {
+	// Split the content into sections and map the event columns
+	script, err := parseSSAScript(content, "SSA")
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Regular expression to match the sections of the SSA format
-	exp = regexp.MustCompile(`\[Script Info\]\n((?:\n?.)*?\n\n)\[(.*?)[ ]?Styles\]\n((?:\n?.)*?\n\n)\[Events\]\n((?:\n?.)*)`)
-	res1 := exp.FindAllStringSubmatch(content, -1)
-	if len(res1) == 1 {
-		if len(res1[0]) == 5 {
-			// Extract the dialogue lines from the SSA content
-			dialogues := res1[0][4]
-
-			// Regular expression to match each dialogue line
-			exp = regexp.MustCompile(`(?m)^Dialogue: (\d),(\d*):(\d*):(\d*).(\d*),(\d*):(\d*):(\d*).(\d*),(\w*),[\w ]*,\d*,\d*,\d*,[\w*]?,{\\pos\(\d*,\d*\)}(.*)$`)
-			res2 := exp.FindAllStringSubmatch(dialogues, -1)
-			if len(res2) > 0 {
-				// Initialize variables to keep track of the sequence and subtitle data
-				seq := 0
-				dummy := models.ModelItemSubtitle{}
-
-				// Iterate over each dialogue line and extract the relevant data
-				for i := 0; i < len(res2); i++ {
-					if len(res2[i]) == 12 {
-						seq++
-						dummy.Seq = seq
-						dummy.Start = formatSSA2Duration(res2[i][2], res2[i][3], res2[i][4], res2[i][5])
-						dummy.End = formatSSA2Duration(res2[i][6], res2[i][7], res2[i][8], res2[i][9])
-						text := strings.Split(cleanText(res2[i][11]), "\\N")
-						dummy.Text = text
-					}
-
-					// Check if the subtitle data is valid and append it to the result
-					if dummy.Seq > 0 && dummy.Start.Milliseconds() > 0 &&
-						dummy.End.Milliseconds() > 0 && len(dummy.Text) > 0 {
-						ret = append(ret, dummy)
-						dummy = models.ModelItemSubtitle{} // empty
-					}
-				}
-			}
-		}
-	}
-	return ret, err
+	// Extract the dialogue lines from the SSA content
+	return readSSAEvents(script)
 }
 
 // formatSSA2Duration converts SSA time format components (hour, minute, second, millisecond)
@@ -107,10 +67,10 @@ func formatSSA2Duration(hour string, minute string, second string, millisecond s
 }
 
 // WriteSSA converts subtitle data from the internal model to SSA formatted content.
-// Creates a standard SSA file with default styling.
+// The script header read with ReadSSAScript is written back when present, otherwise
+// a standard SSA header with default styling is created.
 func WriteSSA(sub *models.Subtitle) (content string) {
-	// Create the SSA header
-	content = `[Script Info]
+	return writeSSAScript(sub, `[Script Info]
 ; This is a Sub Station Alpha v4 script.
 ScriptType: v4.00
 Collisions: Normal
@@ -124,20 +84,7 @@ Style: DefaultVCD, Arial,28,11861244,11861244,11861244,-2147483640,-1,0,1,1,2,2,
 
 [Events]
 Format: Marked, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
-`
-
-	// Iterate over each subtitle line and create the SSA dialogue lines
-	for i := range sub.Lines {
-		start := formatDuration2SSA(sub.Lines[i].Start)
-		end := formatDuration2SSA(sub.Lines[i].End)
-		cleanedTexts := make([]string, len(sub.Lines[i].Text))
-		for j, text := range sub.Lines[i].Text {
-			cleanedTexts[j] = cleanText(text)
-		}
-		text := strings.Join(cleanedTexts, "\\N")
-		content += fmt.Sprintf("Dialogue: 0,%s,%s,DefaultVCD,NTP,0000,0000,0000,,{\\pos(400,570)}%s\n", start, end, text)
-	}
-	return content
+`, "0000")
 }
 
 // formatDuration2SSA converts a time.Duration to SSA time format string (h:mm:ss.cc).
@@ -151,3 +98,41 @@ func formatDuration2SSA(d time.Duration) string {
 	// Format the SSA time string
 	return fmt.Sprintf("%d:%02d:%02d.%02d", h, m, s, ms/10)
 }
+
+// ssaFormat implements the Format interface for SSA.
+type ssaFormat struct{}
+
+func init() {
+	Register(ssaFormat{})
+}
+
+// Name returns the name of the SSA format.
+func (ssaFormat) Name() string { return "SSA" }
+
+// Extensions returns the file extensions of the SSA format.
+func (ssaFormat) Extensions() []string { return []string{".ssa"} }
+
+// Probe returns the confidence that the content is SSA: a [Script Info] and an [Events] section.
+// ASS scripts are also valid SSA scripts, so they get a lower confidence.
+func (ssaFormat) Probe(content string) float64 {
+	switch probeSSA(content) {
+	case "SSA":
+		return 0.9
+	case "ASS":
+		return 0.5
+	}
+	return 0
+}
+
+// Read parses SSA content and its script header into sub.
+func (ssaFormat) Read(content string, sub *models.Subtitle) (err error) {
+	sub.Lines, sub.Warnings, err = readSSA(content)
+	if err != nil {
+		return err
+	}
+	sub.Script, err = ReadSSAScript(content)
+	return err
+}
+
+// Write converts the subtitle to SSA content.
+func (ssaFormat) Write(sub *models.Subtitle) string { return WriteSSA(sub) }
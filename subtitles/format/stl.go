@@ -0,0 +1,450 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+	"golang.org/x/text/encoding/charmap"
+)
+
+/*
+EBU STL Format Specification (EBU Tech 3264):
+
+A binary file with a 1024 byte General Subtitle Information (GSI) block followed by
+128 byte Text and Timing Information (TTI) blocks.
+
+GSI block (text fields, padded with spaces):
+0-2 Code Page Number       3-10 Disk Format Code ("STL25.01" or "STL30.01", the frame rate)
+11 Display Standard Code   12-13 Character Code Table ("00" Latin ISO 6937, "01" Cyrillic,
+14-15 Language Code              "02" Arabic, "03" Greek, "04" Hebrew)
+238-242 Total Number of TTI Blocks       243-247 Total Number of Subtitles
+256-263 Time Code Start-of-Programme (HHMMSSFF)
+
+TTI block:
+0 Subtitle Group Number    1-2 Subtitle Number (little endian)
+3 Extension Block Number (0xFF for the last block of a subtitle, 0xFE for user data)
+4 Cumulative Status        5-8 Time Code In (hours, minutes, seconds, frames)
+9-12 Time Code Out         13 Vertical Position (teletext row)
+14 Justification Code (0 unchanged, 1 left, 2 centered, 3 right)
+15 Comment Flag            16-127 Text Field, padded with 0x8F
+
+Text field control codes:
+0x00-0x07 Teletext colors (black, red, green, yellow, blue, magenta, cyan, white)
+0x0C Normal height  0x0D Double height  0x80/0x81 Italics on/off  0x82/0x83 Underline on/off
+0x8A Line break     0x8F Unused space
+
+Example EBU STL TTI block (hexadecimal):
+00 0100 FF 00 00021711 00022009 14 02 00 0D 0B 0B 53656E61746F72 ... 8A 8A 0D 0B 0B 6F7572 ... 8F 8F
+*/
+
+// stlBlockSize is the size of the GSI block and stlTTISize the size of the TTI blocks.
+const (
+	stlBlockSize = 1024
+	stlTTISize   = 128
+	stlTextSize  = 112
+)
+
+// stlColors maps the teletext color codes to their colors. White is the default color.
+var stlColors = []string{"#000000", "#FF0000", "#00FF00", "#FFFF00", "#0000FF", "#FF00FF", "#00FFFF", ""}
+
+// stlLanguages maps the language codes of the GSI block to the language tags.
+var stlLanguages = []string{
+	"", "sq", "br", "ca", "hr", "cy", "cs", "da", "de", "en", "es", "eo", "et", "eu", "fo", "fr",
+	"fy", "ga", "gd", "gl", "is", "it", "se", "la", "lv", "lb", "lt", "hu", "mt", "nl", "no", "oc",
+	"pl", "pt", "ro", "rm", "sr", "sk", "sl", "fi", "sv", "tr", "nl-BE", "wa",
+}
+
+// stlCharmaps maps the character code tables of the GSI block, other than ISO 6937, to the charmaps.
+var stlCharmaps = map[string]*charmap.Charmap{
+	"01": charmap.ISO8859_5,
+	"02": charmap.ISO8859_6,
+	"03": charmap.ISO8859_7,
+	"04": charmap.ISO8859_8,
+}
+
+// ReadSTL parses EBU STL (Tech 3264) binary subtitle content and converts it to the internal
+// model. The times are converted from frames with the frame rate of the GSI block and start
+// at the Start-of-Programme time code when the subtitles are after it.
+// Returns the frame rate of the content.
+// Returns a *ParseError if the content is not a valid EBU STL format.
+func ReadSTL(raw []byte) (ret []models.ModelItemSubtitle, frameRate float64, err error) {
+	sub := models.Subtitle{}
+	ret, _, err = readSTL(raw, &sub)
+	return ret, sub.FrameRate, err
+}
+
+// readSTL parses EBU STL binary content like ReadSTL, storing the frame rate and language of
+// the GSI block in sub, and also returns the problems found in the TTI blocks as warnings.
+func readSTL(raw []byte, sub *models.Subtitle) (ret []models.ModelItemSubtitle, warnings []error, err error) {
+	// warn records a problem of a block, with the block number as line
+	warn := func(block int, cue int, reason string) {
+		warnings = append(warnings, &ParseError{Format: "STL", Line: block, Cue: cue, Reason: reason})
+	}
+
+	// Parse the GSI block
+	if len(raw) < stlBlockSize || !regexp.MustCompile(`^STL\d\d\.01$`).Match(raw[3:11]) {
+		return nil, nil, &ParseError{Format: "STL", Line: 1, Column: 4, Reason: "Invalid STL: missing GSI block"}
+	}
+	gsi := func(from int, to int) string {
+		return strings.TrimSpace(string(raw[from:to]))
+	}
+	frameRate := float64(toInt(string(raw[6:8])))
+	codeTable := gsi(12, 14)
+	if language, errLanguage := strconv.ParseUint(gsi(14, 16), 16, 8); errLanguage == nil && int(language) < len(stlLanguages) {
+		sub.Language = stlLanguages[language]
+	}
+	sub.FrameRate = frameRate
+	teletext := raw[11] == '1' || raw[11] == '2'
+
+	// Start-of-Programme time code
+	var programme time.Duration
+	if tcp := gsi(256, 264); len(tcp) == 8 {
+		programme = formatSTLTimecode([]byte{byte(toInt(tcp[0:2])), byte(toInt(tcp[2:4])), byte(toInt(tcp[4:6])), byte(toInt(tcp[6:8]))}, frameRate)
+	}
+
+	// Parse the TTI blocks, joining the extension blocks of each subtitle
+	var item *models.ModelItemSubtitle
+	var text []byte
+	for block := 0; stlBlockSize+(block+1)*stlTTISize <= len(raw); block++ {
+		tti := raw[stlBlockSize+block*stlTTISize : stlBlockSize+(block+1)*stlTTISize]
+		extension := tti[3]
+
+		// Skip the user data and comment blocks
+		if extension == 0xFE || tti[15] != 0 {
+			continue
+		}
+		if item == nil {
+			item = &models.ModelItemSubtitle{
+				Start: formatSTLTimecode(tti[5:9], frameRate),
+				End:   formatSTLTimecode(tti[9:13], frameRate),
+			}
+			switch tti[14] {
+			case 1:
+				item.Settings.Align = "left"
+			case 2:
+				item.Settings.Align = "center"
+			case 3:
+				item.Settings.Align = "right"
+			}
+			if teletext && tti[13] > 0 {
+				item.Settings.Line = strconv.Itoa(int(tti[13]))
+			}
+			text = nil
+		}
+		text = append(text, tti[16:]...)
+		if extension != 0xFF {
+			continue
+		}
+
+		// The last block of the subtitle
+		seq := len(ret) + 1
+		item.Seq = seq
+		item.Text, item.Spans = parseSTLText(text, codeTable)
+		if len(strings.Join(item.Text, "")) == 0 {
+			warn(block+2, seq, fmt.Sprintf("cue %d has no text", seq))
+		}
+		if item.End < item.Start {
+			warn(block+2, seq, "end time before start time")
+		}
+		if len(ret) > 0 && item.Start < ret[len(ret)-1].Start {
+			warn(block+2, seq, "timestamp out of order")
+		}
+		ret = append(ret, *item)
+		item = nil
+	}
+	if item != nil {
+		warn(0, len(ret)+1, "subtitle without last extension block skipped")
+	}
+
+	// If no subtitles were found, return an error
+	if len(ret) == 0 {
+		return nil, warnings, &ParseError{Format: "STL", Reason: "Invalid STL: no subtitles found"}
+	}
+
+	// The times start at the Start-of-Programme time code, when the subtitles are after it
+	if programme > 0 && ret[0].Start >= programme {
+		for i := range ret {
+			ret[i].Start -= programme
+			ret[i].End -= programme
+		}
+	}
+	return ret, warnings, nil
+}
+
+// formatSTLTimecode converts a time code (hours, minutes, seconds and frames bytes) to a time.Duration.
+func formatSTLTimecode(tc []byte, frameRate float64) time.Duration {
+	return time.Duration(tc[0])*time.Hour + time.Duration(tc[1])*time.Minute + time.Duration(tc[2])*time.Second +
+		formatFrame2Duration(int(tc[3]), frameRate)
+}
+
+// formatDuration2STLTimecode converts a time.Duration to a time code (hours, minutes, seconds
+// and frames bytes) with the given rounding of the frames.
+func formatDuration2STLTimecode(d time.Duration, frameRate float64, rounding models.FrameRounding) []byte {
+	fps := int(frameRate)
+	frames := formatDuration2Frame(d, frameRate, rounding)
+	return []byte{byte(frames / fps / 3600), byte(frames / fps / 60 % 60), byte(frames / fps % 60), byte(frames % fps)}
+}
+
+// parseSTLText parses the text field of a subtitle, in the given character code table, into
+// plain lines and runs. The teletext colors, double height, italics and underline control
+// codes style the rest of the row.
+func parseSTLText(text []byte, codeTable string) (lines []string, spans [][]models.ModelSpan) {
+	cm, ok := stlCharmaps[codeTable]
+	var style models.ModelSpan
+	var runs []models.ModelSpan
+	var mark byte
+	newLine := func() {
+		if len(strings.TrimSpace(spansText(runs))) > 0 {
+			spans = append(spans, runs)
+		}
+		runs = nil
+		style = models.ModelSpan{}
+	}
+
+	for _, b := range text {
+		switch {
+		case b == 0x8F:
+			continue
+		case b == 0x8A:
+			newLine()
+		case b <= 0x07:
+			style.Color = stlColors[b]
+		case b == 0x0C || b == 0x0E:
+			style.DoubleHeight = false
+		case b == 0x0D || b == 0x0F:
+			style.DoubleHeight = true
+		case b == 0x80 || b == 0x81:
+			style.Italic = b == 0x80
+		case b == 0x82 || b == 0x83:
+			style.Underline = b == 0x82
+		case b >= 0xC1 && b <= 0xCF && !ok:
+			mark = b
+			continue
+		case b >= 0x20 && b != 0x7F && (b < 0x80 || b >= 0xA0):
+			if ok {
+				runs = appendSpan(runs, style, string(cm.DecodeByte(b)))
+			} else {
+				runs = appendSpan(runs, style, decodeISO6937(mark, b))
+			}
+		}
+		mark = 0
+	}
+	newLine()
+
+	// Remove the spaces around each row
+	lines, spans = trimSpanLines(spans)
+	if lines == nil {
+		lines = []string{}
+	}
+	return lines, spans
+}
+
+// encodeSTLText converts a rune to the given character code table.
+// Returns false if the character can't be represented.
+func encodeSTLText(r rune, codeTable string) ([]byte, bool) {
+	if cm, ok := stlCharmaps[codeTable]; ok {
+		b, ok := cm.EncodeRune(r)
+		return []byte{b}, ok && (b < 0x80 || b >= 0xA0)
+	}
+	return encodeISO6937(r)
+}
+
+// formatSTLCodeTable returns the first character code table that can represent all the text
+// of the subtitles, or "00" (ISO 6937) if there is none.
+func formatSTLCodeTable(sub *models.Subtitle) string {
+	for _, codeTable := range []string{"00", "01", "02", "03", "04"} {
+		valid := true
+		for i := 0; i < len(sub.Lines) && valid; i++ {
+			for _, r := range strings.Join(sub.Lines[i].Text, "") {
+				if _, ok := encodeSTLText(r, codeTable); !ok {
+					valid = false
+					break
+				}
+			}
+		}
+		if valid {
+			return codeTable
+		}
+	}
+	return "00"
+}
+
+// formatSTLText converts the lines of a subtitle to the text field, in the given character
+// code table, with the teletext control codes of the runs. Lines without runs are written at
+// double height, as usual for teletext subtitles. Characters that can't be represented are
+// replaced by spaces.
+func formatSTLText(item *models.ModelItemSubtitle, codeTable string) []byte {
+	var buf bytes.Buffer
+	for j := range item.Text {
+		if j > 0 {
+			buf.Write([]byte{0x8A, 0x8A})
+		}
+		spans := lineSpans(item, j)
+		if spans == nil {
+			spans = []models.ModelSpan{{Text: cleanText(item.Text[j]), DoubleHeight: true}}
+		}
+
+		var style models.ModelSpan
+		for k, span := range spans {
+			if span.DoubleHeight != style.DoubleHeight || k == 0 {
+				if span.DoubleHeight {
+					buf.WriteByte(0x0D)
+				} else if k > 0 {
+					buf.WriteByte(0x0C)
+				}
+			}
+			if span.Color != style.Color {
+				color := byte(7)
+				for code, c := range stlColors {
+					if c == span.Color {
+						color = byte(code)
+					}
+				}
+				buf.WriteByte(color)
+			}
+			if span.Italic && !style.Italic {
+				buf.WriteByte(0x80)
+			} else if !span.Italic && style.Italic {
+				buf.WriteByte(0x81)
+			}
+			if span.Underline && !style.Underline {
+				buf.WriteByte(0x82)
+			} else if !span.Underline && style.Underline {
+				buf.WriteByte(0x83)
+			}
+			for _, r := range span.Text {
+				encoded, ok := encodeSTLText(r, codeTable)
+				if !ok {
+					encoded = []byte{' '}
+				}
+				buf.Write(encoded)
+			}
+			style = span
+		}
+	}
+	return buf.Bytes()
+}
+
+// WriteSTL converts subtitle data from the internal model to EBU STL (Tech 3264) binary content
+// for level-1 teletext. The frame rate is 30 when the FrameRate of the subtitle is close to 30,
+// and 25 otherwise; the times are rounded to frames with the FrameRounding of the subtitle.
+// Long texts are split into extension blocks.
+func WriteSTL(sub *models.Subtitle) []byte {
+	frameRate := 25.0
+	if sub.FrameRate > 27.5 {
+		frameRate = 30
+	}
+	codeTable := formatSTLCodeTable(sub)
+
+	// Write the TTI blocks
+	var tti bytes.Buffer
+	blocks := 0
+	maxChars := 0
+	for i := range sub.Lines {
+		item := &sub.Lines[i]
+		for _, text := range item.Text {
+			if len([]rune(text)) > maxChars {
+				maxChars = len([]rune(text))
+			}
+		}
+
+		// Vertical position of teletext rows, at the bottom of the screen by default
+		row := 22 - 2*(len(item.Text)-1)
+		if line, err := strconv.Atoi(item.Settings.Line); err == nil && line > 0 && line < 24 {
+			row = line
+		}
+		if row < 1 {
+			row = 1
+		}
+		justification := byte(2)
+		switch item.Settings.Align {
+		case "left", "start":
+			justification = 1
+		case "right", "end":
+			justification = 3
+		}
+
+		text := formatSTLText(item, codeTable)
+		for extension := 0; extension == 0 || len(text) > 0; extension++ {
+			chunk := text
+			if len(chunk) > stlTextSize {
+				chunk = chunk[:stlTextSize]
+			}
+			text = text[len(chunk):]
+			ebn := byte(extension)
+			if len(text) == 0 {
+				ebn = 0xFF
+			}
+			tti.Write([]byte{0, byte(i + 1), byte((i + 1) >> 8), ebn, 0})
+			tti.Write(formatDuration2STLTimecode(item.Start, frameRate, sub.FrameRounding))
+			tti.Write(formatDuration2STLTimecode(item.End, frameRate, sub.FrameRounding))
+			tti.Write([]byte{byte(row), justification, 0})
+			tti.Write(chunk)
+			tti.Write(bytes.Repeat([]byte{0x8F}, stlTextSize-len(chunk)))
+			blocks++
+		}
+	}
+
+	// Write the GSI block
+	language := 0
+	for code, tag := range stlLanguages {
+		if len(sub.Language) > 0 && strings.EqualFold(strings.SplitN(sub.Language, "-", 2)[0], tag) {
+			language = code
+			break
+		}
+	}
+	firstCue := "00000000"
+	if len(sub.Lines) > 0 {
+		tc := formatDuration2STLTimecode(sub.Lines[0].Start, frameRate, sub.FrameRounding)
+		firstCue = fmt.Sprintf("%02d%02d%02d%02d", tc[0], tc[1], tc[2], tc[3])
+	}
+	if maxChars > 99 {
+		maxChars = 99
+	}
+	date := time.Now().Format("060102")
+	gsi := fmt.Sprintf("850STL%02d.011%s%02X", int(frameRate), codeTable, language) +
+		strings.Repeat(" ", 32*6+16) + date + date + "00" +
+		fmt.Sprintf("%05d%05d%03d%02d%02d", blocks, len(sub.Lines), 1, maxChars, 23) +
+		"1" + "00000000" + firstCue + "11" + "   "
+	gsi += strings.Repeat(" ", stlBlockSize-len(gsi))
+
+	return append([]byte(gsi), tti.Bytes()...)
+}
+
+// stlFormat implements the Format and BinaryFormat interfaces for EBU STL.
+type stlFormat struct{}
+
+func init() {
+	Register(stlFormat{})
+}
+
+// Name returns the name of the EBU STL format.
+func (stlFormat) Name() string { return "STL" }
+
+// Extensions returns the file extensions of the EBU STL format.
+func (stlFormat) Extensions() []string { return []string{".stl"} }
+
+// Binary reports that the EBU STL format is binary.
+func (stlFormat) Binary() bool { return true }
+
+// Probe returns the confidence that the raw content is EBU STL: a GSI block with a disk format code.
+func (stlFormat) Probe(content string) float64 {
+	if len(content) >= stlBlockSize && regexp.MustCompile(`^STL\d\d\.01$`).MatchString(content[3:11]) {
+		return 1
+	}
+	return 0
+}
+
+// Read parses the raw EBU STL content into sub, with the frame rate and language of the GSI block.
+func (stlFormat) Read(content string, sub *models.Subtitle) (err error) {
+	sub.Lines, sub.Warnings, err = readSTL([]byte(content), sub)
+	return err
+}
+
+// Write converts the subtitle to raw EBU STL content.
+func (stlFormat) Write(sub *models.Subtitle) string { return string(WriteSTL(sub)) }
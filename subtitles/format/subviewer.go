@@ -0,0 +1,224 @@
+package format
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+)
+
+/*
+SubViewer Format Specification:
+
+SubViewer 2: an [INFORMATION] header, then blocks of a timing line and the text, separated
+by empty lines. The times are in centiseconds and lines of text are separated by [br].
+
+Regular Expression for timing line validation (SubViewer 2):
+^(\d{1,2}):(\d{2}):(\d{2})\.(\d{2}),(\d{1,2}):(\d{2}):(\d{2})\.(\d{2})$
+
+SubViewer 1: a [hh:mm:ss] time line before each text, which is shown until the next time
+line; a time line without text clears the screen. Lines of text are separated by |.
+
+Example SubViewer 2 Format:
+[INFORMATION]
+[TITLE]Star Wars
+[END INFORMATION]
+[SUBTITLE]
+00:02:17.44,00:02:20.37
+Senator, we're making[br]our final approach into Coruscant.
+
+00:02:20.47,00:02:22.50
+Very good, Lieutenant.
+
+Example SubViewer 1 Format:
+[BEGIN]
+******** START SCRIPT ********
+[00:02:17]
+Senator, we're making|our final approach into Coruscant.
+[00:02:20]
+Very good, Lieutenant.
+[00:02:23]
+
+[END]
+******** END SCRIPT ********
+*/
+
+// ReadSubViewer parses SubViewer 1 or 2 formatted subtitle content and converts it to the
+// internal model. SubViewer 1 subtitles end at the next time line, or after 3 seconds for the last one.
+// Returns a *ParseError if the content is not a valid SubViewer format.
+func ReadSubViewer(content string) (ret []models.ModelItemSubtitle, err error) {
+	ret, _, err = readSubViewer(content)
+	return ret, err
+}
+
+// readSubViewer parses SubViewer formatted subtitle content like ReadSubViewer, and also
+// returns the problems found in the subtitles that were parsed or skipped as warnings.
+func readSubViewer(content string) (ret []models.ModelItemSubtitle, warnings []error, err error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+	lines := strings.Split(content, "\n")
+
+	// SubViewer 1 has a time line before each text
+	exp := regexp.MustCompile(`^\[(\d{1,2}):(\d{2}):(\d{2})\]$`)
+	if regexp.MustCompile(`(?m)^\[\d{1,2}:\d{2}:\d{2}\][ \t]*$`).MatchString(content) {
+		var texts []timedText
+		for i, line := range lines {
+			line = cleanText(line)
+			if res := exp.FindStringSubmatch(line); len(res) == 4 {
+				start := time.Duration(toInt(res[1]))*time.Hour + time.Duration(toInt(res[2]))*time.Minute + time.Duration(toInt(res[3]))*time.Second
+				texts = append(texts, timedText{line: i + 1, start: start, raw: line})
+				continue
+			}
+
+			// The text belongs to the previous time line; the header and the script marks are skipped
+			if line == "" || len(texts) == 0 || line == "[END]" || strings.HasPrefix(line, "*****") {
+				continue
+			}
+			for _, text := range strings.Split(line, "|") {
+				texts[len(texts)-1].text = append(texts[len(texts)-1].text, strings.TrimSpace(text))
+			}
+		}
+		ret, warnings = readTimedTexts("SubViewer", texts, tmplayerLastDuration, 0)
+	} else {
+		ret, warnings = readTimedBlocks("SubViewer", lines, `(\d{1,2}):(\d{2}):(\d{2})\.(\d{2})`, "[br]")
+	}
+
+	// If no subtitles were found, return an error
+	if len(ret) == 0 {
+		return nil, warnings, &ParseError{Format: "SubViewer", Reason: "Invalid SubViewer: no subtitles found"}
+	}
+
+	return ret, warnings, nil
+}
+
+// readTimedBlocks parses the blocks of a "start,end" timing line and the text, separated by
+// empty lines, of SubViewer 2 and SBV. The time expression captures the hours, minutes, seconds
+// and fraction of second; lines of text are also split at lineBreak. The lines before the first
+// timing line (the header) are skipped. Also returns the problems found in the blocks as warnings.
+func readTimedBlocks(format string, lines []string, timeExp string, lineBreak string) (ret []models.ModelItemSubtitle, warnings []error) {
+	exp := regexp.MustCompile(`^` + timeExp + `,` + timeExp + `$`)
+
+	// warn records a problem of a block
+	warn := func(line int, cue int, text string, reason string) {
+		warnings = append(warnings, &ParseError{Format: format, Line: line, Column: 1, Cue: cue, Text: text, Reason: reason})
+	}
+
+	var item *models.ModelItemSubtitle
+	var timingLine int
+	var timingText string
+	for i := 0; i <= len(lines); i++ {
+		line := ""
+		if i < len(lines) {
+			line = cleanText(lines[i])
+		}
+
+		// An empty line ends the text of the block
+		if line == "" {
+			if item == nil {
+				continue
+			}
+			seq := len(ret) + 1
+			item.Seq = seq
+			if len(item.Text) == 0 {
+				warn(timingLine, seq, timingText, fmt.Sprintf("cue %d has no text", seq))
+			}
+			if item.End < item.Start {
+				warn(timingLine, seq, timingText, "end time before start time")
+			}
+			if len(ret) > 0 && item.Start < ret[len(ret)-1].Start {
+				warn(timingLine, seq, timingText, "timestamp out of order")
+			}
+			ret = append(ret, *item)
+			item = nil
+			continue
+		}
+
+		if item != nil {
+			for _, text := range strings.Split(line, lineBreak) {
+				item.Text = append(item.Text, strings.TrimSpace(text))
+			}
+			continue
+		}
+		if res := exp.FindStringSubmatch(line); len(res) == 9 {
+			item = &models.ModelItemSubtitle{Start: parseTimedBlockTime(res[1:5]), End: parseTimedBlockTime(res[5:9])}
+			timingLine, timingText = i+1, line
+			continue
+		}
+
+		// Lines before the first block are the header
+		if len(ret) > 0 {
+			warn(i+1, len(ret)+1, line, "unexpected line, expected a timing line")
+		}
+	}
+	return ret, warnings
+}
+
+// parseTimedBlockTime converts the hours, minutes, seconds and fraction of second of a time
+// to a time.Duration.
+func parseTimedBlockTime(parts []string) time.Duration {
+	fraction := time.Duration(toInt(parts[3])) * time.Second
+	for range parts[3] {
+		fraction /= 10
+	}
+	return time.Duration(toInt(parts[0]))*time.Hour + time.Duration(toInt(parts[1]))*time.Minute + time.Duration(toInt(parts[2]))*time.Second + fraction
+}
+
+// formatDuration2SubViewer converts a number of centiseconds to SubViewer 2 time format string (hh:mm:ss.cc).
+func formatDuration2SubViewer(centiseconds int) string {
+	return fmt.Sprintf("%02d:%02d:%02d.%02d", centiseconds/360000, centiseconds/6000%60, centiseconds/100%60, centiseconds%100)
+}
+
+// WriteSubViewer converts subtitle data from the internal model to SubViewer 2 formatted content.
+// The times are converted to centiseconds with the FrameRounding of the subtitle.
+func WriteSubViewer(sub *models.Subtitle) (content string) {
+	var b strings.Builder
+	b.WriteString("[INFORMATION]\n[TITLE]\n[AUTHOR]\n[SOURCE]\n[PRG]\n[FILEPATH]\n[DELAY]0\n[CD TRACK]0\n[COMMENT]\n[END INFORMATION]\n" +
+		"[SUBTITLE]\n[COLF]&HFFFFFF,[STYLE]no,[SIZE]18,[FONT]Arial\n")
+	for i := range sub.Lines {
+		lines := make([]string, len(sub.Lines[i].Text))
+		for j := range sub.Lines[i].Text {
+			lines[j] = cleanText(sub.Lines[i].Text[j])
+		}
+		fmt.Fprintf(&b, "%s,%s\n%s\n\n",
+			formatDuration2SubViewer(formatDuration2Frame(sub.Lines[i].Start, 100, sub.FrameRounding)),
+			formatDuration2SubViewer(formatDuration2Frame(sub.Lines[i].End, 100, sub.FrameRounding)),
+			strings.Join(lines, "[br]"))
+	}
+	return b.String()
+}
+
+// subViewerFormat implements the Format interface for SubViewer.
+type subViewerFormat struct{}
+
+func init() {
+	Register(subViewerFormat{})
+}
+
+// Name returns the name of the SubViewer format.
+func (subViewerFormat) Name() string { return "SubViewer" }
+
+// Extensions returns the file extensions of the SubViewer format.
+func (subViewerFormat) Extensions() []string { return []string{".sub"} }
+
+// Probe returns the confidence that the content is SubViewer: a SubViewer 2 timing line, or
+// a SubViewer 1 time line. The [INFORMATION] header gives a higher confidence.
+func (subViewerFormat) Probe(content string) float64 {
+	if !regexp.MustCompile(`(?m)^\d{1,2}:\d{2}:\d{2}\.\d{2},\d{1,2}:\d{2}:\d{2}\.\d{2}[ \t]*$`).MatchString(content) &&
+		!regexp.MustCompile(`(?m)^\[\d{1,2}:\d{2}:\d{2}\][ \t]*$`).MatchString(content) {
+		return 0
+	}
+	if strings.Contains(content, "[INFORMATION]") || strings.Contains(content, "[BEGIN]") {
+		return 0.9
+	}
+	return 0.8
+}
+
+// Read parses SubViewer content into sub.
+func (subViewerFormat) Read(content string, sub *models.Subtitle) (err error) {
+	sub.Lines, sub.Warnings, err = readSubViewer(content)
+	return err
+}
+
+// Write converts the subtitle to SubViewer 2 content.
+func (subViewerFormat) Write(sub *models.Subtitle) string { return WriteSubViewer(sub) }
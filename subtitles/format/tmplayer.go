@@ -0,0 +1,168 @@
+package format
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+)
+
+/*
+TMPlayer Format Specification:
+
+Regular Expression for subtitle line validation:
+^(\d{1,2}):(\d{2}):(\d{2})(?:,\d+)?[:=](.*)$
+
+Each line is shown from its time until the time of the next line; a line without text
+clears the screen. Lines of text are separated by |.
+
+Example TMPlayer Format:
+00:02:17:Senator, we're making|our final approach into Coruscant.
+00:02:20:Very good, Lieutenant.
+00:02:23:
+*/
+
+// tmplayerLastDuration is the duration of the last line of text when no line clears it.
+const tmplayerLastDuration = 3 * time.Second
+
+// timedText is a text of the formats without end times, shown from its start time until
+// the start time of the next one.
+type timedText struct {
+	line  int
+	start time.Duration
+	raw   string
+	text  []string
+	words []models.ModelWord
+}
+
+// readTimedTexts converts texts shown until the next one to subtitles. Texts without text
+// clear the screen, and the last text is shown for lastDuration. The subtitles are shown
+// for maxDuration at most, unless it is zero. Also returns the problems found in the texts
+// as warnings.
+func readTimedTexts(format string, texts []timedText, lastDuration time.Duration, maxDuration time.Duration) (ret []models.ModelItemSubtitle, warnings []error) {
+	for i, t := range texts {
+		if len(strings.TrimSpace(strings.Join(t.text, ""))) == 0 {
+			continue
+		}
+		seq := len(ret) + 1
+		end := t.start + lastDuration
+		if i+1 < len(texts) {
+			end = texts[i+1].start
+		}
+		if maxDuration > 0 && end > t.start+maxDuration {
+			end = t.start + maxDuration
+		}
+		if end < t.start {
+			warnings = append(warnings, &ParseError{Format: format, Line: t.line, Column: 1, Cue: seq, Text: t.raw, Reason: "end time before start time"})
+		}
+		if len(ret) > 0 && t.start < ret[len(ret)-1].Start {
+			warnings = append(warnings, &ParseError{Format: format, Line: t.line, Column: 1, Cue: seq, Text: t.raw, Reason: "timestamp out of order"})
+		}
+		ret = append(ret, models.ModelItemSubtitle{Seq: seq, Start: t.start, End: end, Text: t.text, Words: t.words})
+	}
+	return ret, warnings
+}
+
+// ReadTMPlayer parses TMPlayer formatted subtitle content and converts it to the internal model.
+// Each subtitle ends at the time of the next line, or after 3 seconds for the last one.
+// Returns a *ParseError if the content is not a valid TMPlayer format.
+func ReadTMPlayer(content string) (ret []models.ModelItemSubtitle, err error) {
+	ret, _, err = readTMPlayer(content)
+	return ret, err
+}
+
+// readTMPlayer parses TMPlayer formatted subtitle content like ReadTMPlayer, and also returns
+// the problems found in the lines that were parsed or skipped as warnings.
+func readTMPlayer(content string) (ret []models.ModelItemSubtitle, warnings []error, err error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+
+	exp := regexp.MustCompile(`^(\d{1,2}):(\d{2}):(\d{2})(?:,\d+)?[:=](.*)$`)
+	var texts []timedText
+	for i, line := range strings.Split(content, "\n") {
+		line = cleanText(line)
+		if line == "" {
+			continue
+		}
+		res := exp.FindStringSubmatch(line)
+		if len(res) != 5 {
+			warnings = append(warnings, &ParseError{Format: "TMPlayer", Line: i + 1, Column: 1, Cue: len(texts) + 1, Text: line, Reason: "unexpected line, expected hh:mm:ss:text"})
+			continue
+		}
+		text := strings.Split(res[4], "|")
+		for j := range text {
+			text[j] = strings.TrimSpace(text[j])
+		}
+		texts = append(texts, timedText{
+			line:  i + 1,
+			start: time.Duration(toInt(res[1]))*time.Hour + time.Duration(toInt(res[2]))*time.Minute + time.Duration(toInt(res[3]))*time.Second,
+			raw:   line,
+			text:  text,
+		})
+	}
+	ret, timedWarnings := readTimedTexts("TMPlayer", texts, tmplayerLastDuration, 0)
+	warnings = append(warnings, timedWarnings...)
+
+	// If no subtitles were found, return an error
+	if len(ret) == 0 {
+		return nil, warnings, &ParseError{Format: "TMPlayer", Reason: "Invalid TMPlayer: no subtitles found"}
+	}
+
+	return ret, warnings, nil
+}
+
+// formatDuration2TMPlayer converts a number of seconds to TMPlayer time format string (hh:mm:ss).
+func formatDuration2TMPlayer(seconds int) string {
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+// WriteTMPlayer converts subtitle data from the internal model to TMPlayer formatted content.
+// The times are converted to seconds with the FrameRounding of the subtitle, and an empty
+// line clears the screen at the end of each subtitle, unless the next one starts then.
+func WriteTMPlayer(sub *models.Subtitle) (content string) {
+	var b strings.Builder
+	for i := range sub.Lines {
+		start := formatDuration2Frame(sub.Lines[i].Start, 1, sub.FrameRounding)
+		end := formatDuration2Frame(sub.Lines[i].End, 1, sub.FrameRounding)
+		lines := make([]string, len(sub.Lines[i].Text))
+		for j := range sub.Lines[i].Text {
+			lines[j] = cleanText(sub.Lines[i].Text[j])
+		}
+		b.WriteString(formatDuration2TMPlayer(start) + ":" + strings.Join(lines, "|") + "\n")
+		if i+1 == len(sub.Lines) || end < formatDuration2Frame(sub.Lines[i+1].Start, 1, sub.FrameRounding) {
+			b.WriteString(formatDuration2TMPlayer(end) + ":\n")
+		}
+	}
+	return b.String()
+}
+
+// tmplayerFormat implements the Format interface for TMPlayer.
+type tmplayerFormat struct{}
+
+func init() {
+	Register(tmplayerFormat{})
+}
+
+// Name returns the name of the TMPlayer format.
+func (tmplayerFormat) Name() string { return "TMPlayer" }
+
+// Extensions returns the file extensions of the TMPlayer format.
+func (tmplayerFormat) Extensions() []string { return []string{".txt"} }
+
+// Probe returns the confidence that the content is TMPlayer: a first line starting with hh:mm:ss: or hh:mm:ss=.
+func (tmplayerFormat) Probe(content string) float64 {
+	if regexp.MustCompile(`^\d{1,2}:\d{2}:\d{2}(,\d+)?[:=]`).MatchString(cleanText(content)) {
+		return 0.8
+	}
+	return 0
+}
+
+// Read parses TMPlayer content into sub.
+func (tmplayerFormat) Read(content string, sub *models.Subtitle) (err error) {
+	sub.Lines, sub.Warnings, err = readTMPlayer(content)
+	return err
+}
+
+// Write converts the subtitle to TMPlayer content.
+func (tmplayerFormat) Write(sub *models.Subtitle) string { return WriteTMPlayer(sub) }
@@ -0,0 +1,585 @@
+package format
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+)
+
+/*
+TTML Format Specification (DFXP, SMPTE-TT, IMSC1):
+
+Regular Expressions for time expressions:
+Clock time:  ^(\d{2,}):(\d{2}):(\d{2})(?:\.(\d+)|:(\d{2,})(?:\.(\d+))?)?$  (hh:mm:ss.fraction or hh:mm:ss:frames.subframes)
+Offset time: ^(\d+(?:\.\d+)?)(h|m|s|ms|f|t)$                            (hours, minutes, seconds, milliseconds, frames or ticks)
+
+Frames are converted with the ttp:frameRate (30 if not set) and ttp:frameRateMultiplier
+parameters, and ticks with the ttp:tickRate parameter. The begin of the body and div
+elements is added to the times of their paragraphs.
+
+Example TTML Format:
+<?xml version="1.0" encoding="UTF-8"?>
+<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttp="http://www.w3.org/ns/ttml#parameter"
+    xmlns:tts="http://www.w3.org/ns/ttml#styling" ttp:frameRate="24" xml:lang="en">
+  <head>
+    <styling>
+      <style xml:id="italic" tts:fontStyle="italic"/>
+    </styling>
+    <layout>
+      <region xml:id="bottom" tts:origin="10% 10%" tts:extent="80% 80%" tts:displayAlign="after"/>
+    </layout>
+  </head>
+  <body region="bottom">
+    <div>
+      <p begin="00:02:17.440" end="00:02:20.375">Senator, we're making<br/>our <span style="italic">final approach</span> into Coruscant.</p>
+      <p begin="140.476s" dur="2s">Very good, Lieutenant.</p>
+    </div>
+  </body>
+</tt>
+*/
+
+// ttmlTiming holds the timing parameters of a TTML document.
+type ttmlTiming struct {
+	frameRate    float64 // Effective frame rate (frames per second)
+	subFrameRate float64 // Sub-frames per frame
+	tickRate     float64 // Ticks per second
+}
+
+// ttmlContext holds the inherited timing, region and styling of an element.
+type ttmlContext struct {
+	name   string           // Local name of the element
+	begin  time.Duration    // Begin of the element from the start of the document
+	region string           // Region of the element
+	style  models.ModelSpan // Styling of the text of the element
+}
+
+// ReadTTML parses TTML (DFXP, SMPTE-TT, IMSC1) formatted subtitle content and converts it
+// to the internal model. The region and style references of each paragraph are kept in
+// Settings.Region and Style, and the styling of the text in Spans.
+// Returns a *ParseError if the content is not a valid TTML document.
+func ReadTTML(content string) (ret []models.ModelItemSubtitle, err error) {
+	doc := models.Subtitle{}
+	ret, _, err = readTTML(content, &doc)
+	return ret, err
+}
+
+// readTTML parses TTML formatted subtitle content like ReadTTML, storing the language and
+// the frame rate of the document in sub, and also returns the problems found in the
+// paragraphs that were parsed or skipped as warnings.
+func readTTML(content string, sub *models.Subtitle) (ret []models.ModelItemSubtitle, warnings []error, err error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+
+	// lineAt returns the line number of an offset of the content
+	lineAt := func(offset int64) int {
+		if offset > int64(len(content)) {
+			offset = int64(len(content))
+		}
+		return strings.Count(content[:offset], "\n") + 1
+	}
+
+	// warn records a problem of a paragraph
+	warn := func(line int, cue int, text string, reason string) {
+		warnings = append(warnings, &ParseError{Format: "TTML", Line: line, Column: 1, Cue: cue, Text: text, Reason: reason})
+	}
+
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		// The content is already decoded to UTF-8
+		return input, nil
+	}
+
+	timing := ttmlTiming{frameRate: 30, subFrameRate: 1, tickRate: 1}
+	styles := map[string][]xml.Attr{}
+	var stack []ttmlContext
+	var cue *models.ModelItemSubtitle
+	var cueLine int
+	var runs []models.ModelSpan
+	root := true
+
+	for {
+		offset := decoder.InputOffset()
+		token, errToken := decoder.Token()
+		if errToken == io.EOF {
+			break
+		}
+		if errToken != nil {
+			var syntax *xml.SyntaxError
+			if errors.As(errToken, &syntax) {
+				return nil, warnings, &ParseError{Format: "TTML", Line: syntax.Line, Reason: "Invalid TTML: " + syntax.Msg}
+			}
+			return nil, warnings, &ParseError{Format: "TTML", Line: lineAt(offset), Reason: "Invalid TTML: " + errToken.Error()}
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			// The document element must be tt
+			if root {
+				root = false
+				if t.Name.Local != "tt" {
+					return nil, warnings, &ParseError{Format: "TTML", Line: lineAt(offset), Column: 1, Text: t.Name.Local, Reason: "Invalid TTML: missing tt element"}
+				}
+				timing = parseTTMLTiming(t.Attr)
+				if _, hasRate := ttmlAttr(t.Attr, "frameRate"); hasRate {
+					sub.FrameRate = timing.frameRate
+				}
+				if lang, ok := ttmlAttr(t.Attr, "lang"); ok {
+					sub.Language = lang
+				}
+			}
+
+			ctx := ttmlContext{}
+			if len(stack) > 0 {
+				ctx = stack[len(stack)-1]
+			}
+			ctx.name = t.Name.Local
+
+			switch t.Name.Local {
+			case "style":
+				if id, ok := ttmlAttr(t.Attr, "id"); ok && len(stack) > 0 && stack[len(stack)-1].name == "styling" {
+					styles[id] = t.Attr
+				}
+			case "body", "div", "p", "span":
+				if begin, ok := ttmlAttr(t.Attr, "begin"); ok && t.Name.Local != "p" {
+					if d, errTime := parseTTMLTime(begin, timing); errTime == nil {
+						ctx.begin += d
+					}
+				}
+				if region, ok := ttmlAttr(t.Attr, "region"); ok {
+					ctx.region = region
+				}
+				applyTTMLStyle(&ctx.style, t.Attr, styles, 0)
+			case "br":
+				if cue != nil {
+					cue.Spans = append(cue.Spans, runs)
+					runs = nil
+				}
+			}
+
+			// Paragraphs are the cues
+			if t.Name.Local == "p" {
+				cueLine = lineAt(offset)
+				item, errTime := parseTTMLParagraph(t.Attr, ctx.begin, timing)
+				if errTime != nil {
+					warn(cueLine, len(ret)+1, "", "paragraph with invalid time skipped: "+errTime.Error())
+				} else {
+					item.Settings.Region = ctx.region
+					ctx.begin = item.Start
+					cue = &item
+					runs = nil
+				}
+			}
+			stack = append(stack, ctx)
+
+		case xml.EndElement:
+			if len(stack) == 0 {
+				continue
+			}
+			stack = stack[:len(stack)-1]
+			if t.Name.Local != "p" || cue == nil {
+				continue
+			}
+
+			// Finish the cue with the plain text and runs of each line
+			cue.Spans = append(cue.Spans, runs)
+			cue.Text, cue.Spans = trimSpanLines(cue.Spans)
+			seq := len(ret) + 1
+			cue.Seq = seq
+			text := strings.Join(cue.Text, " ")
+			if len(strings.TrimSpace(text)) == 0 {
+				warn(cueLine, seq, text, fmt.Sprintf("cue %d has no text", seq))
+			}
+			if cue.End < cue.Start {
+				warn(cueLine, seq, text, "end time before start time")
+			}
+			if len(ret) > 0 && cue.Start < ret[len(ret)-1].Start {
+				warn(cueLine, seq, text, "timestamp out of order")
+			}
+			ret = append(ret, *cue)
+			cue = nil
+
+		case xml.CharData:
+			// Only the text of paragraphs and spans is shown
+			if cue == nil || len(stack) == 0 || (stack[len(stack)-1].name != "p" && stack[len(stack)-1].name != "span") {
+				continue
+			}
+			text := regexp.MustCompile(`[ \t\r\n]+`).ReplaceAllString(string(t), " ")
+			if strings.HasSuffix(spansText(runs), " ") {
+				text = strings.TrimLeft(text, " ")
+			}
+			runs = appendSpan(runs, stack[len(stack)-1].style, text)
+		}
+	}
+
+	if root {
+		return nil, warnings, &ParseError{Format: "TTML", Reason: "Invalid TTML: missing tt element"}
+	}
+
+	// If no paragraphs were found, return an error
+	if len(ret) == 0 {
+		return nil, warnings, &ParseError{Format: "TTML", Reason: "Invalid TTML: no paragraphs found"}
+	}
+
+	return ret, warnings, nil
+}
+
+// ttmlAttr returns the value of the attribute of an element with the given local name,
+// in any namespace, and whether it is present.
+func ttmlAttr(attrs []xml.Attr, name string) (string, bool) {
+	for _, attr := range attrs {
+		if attr.Name.Local == name {
+			return strings.TrimSpace(attr.Value), true
+		}
+	}
+	return "", false
+}
+
+// parseTTMLTiming parses the timing parameters of the tt element.
+func parseTTMLTiming(attrs []xml.Attr) ttmlTiming {
+	timing := ttmlTiming{frameRate: 30, subFrameRate: 1}
+	if value, ok := ttmlAttr(attrs, "frameRate"); ok {
+		if rate, err := strconv.ParseFloat(value, 64); err == nil && rate > 0 {
+			timing.frameRate = rate
+		}
+	}
+	if value, ok := ttmlAttr(attrs, "frameRateMultiplier"); ok {
+		// The multiplier is a numerator and a denominator (e.g., "1000 1001")
+		parts := strings.Fields(value)
+		if len(parts) == 2 && toInt(parts[0]) > 0 && toInt(parts[1]) > 0 {
+			timing.frameRate = timing.frameRate * float64(toInt(parts[0])) / float64(toInt(parts[1]))
+		}
+	}
+	if value, ok := ttmlAttr(attrs, "subFrameRate"); ok && toInt(value) > 0 {
+		timing.subFrameRate = float64(toInt(value))
+	}
+
+	// The tick rate defaults to the frame rate times the sub-frame rate when a frame rate is given
+	timing.tickRate = 1
+	if _, ok := ttmlAttr(attrs, "frameRate"); ok {
+		timing.tickRate = timing.frameRate * timing.subFrameRate
+	}
+	if value, ok := ttmlAttr(attrs, "tickRate"); ok && toInt(value) > 0 {
+		timing.tickRate = float64(toInt(value))
+	}
+	return timing
+}
+
+// parseTTMLTime parses a TTML time expression (clock time or offset time) and converts it
+// to a time.Duration value.
+func parseTTMLTime(value string, timing ttmlTiming) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	seconds := 0.0
+
+	clock := regexp.MustCompile(`^(\d{2,}):(\d{2}):(\d{2})(?:\.(\d+)|:(\d{2,})(?:\.(\d+))?)?$`)
+	offset := regexp.MustCompile(`^(\d+(?:\.\d+)?)(h|m|s|ms|f|t)$`)
+	if res := clock.FindStringSubmatch(value); len(res) == 7 {
+		seconds = float64(toInt(res[1])*3600 + toInt(res[2])*60 + toInt(res[3]))
+		if len(res[4]) > 0 {
+			fraction, _ := strconv.ParseFloat("0."+res[4], 64)
+			seconds += fraction
+		}
+		if len(res[5]) > 0 {
+			frames := float64(toInt(res[5]))
+			if len(res[6]) > 0 {
+				frames += float64(toInt(res[6])) / timing.subFrameRate
+			}
+			seconds += frames / timing.frameRate
+		}
+	} else if res := offset.FindStringSubmatch(value); len(res) == 3 {
+		count, _ := strconv.ParseFloat(res[1], 64)
+		switch res[2] {
+		case "h":
+			seconds = count * 3600
+		case "m":
+			seconds = count * 60
+		case "s":
+			seconds = count
+		case "ms":
+			seconds = count / 1000
+		case "f":
+			seconds = count / timing.frameRate
+		case "t":
+			seconds = count / timing.tickRate
+		}
+	} else {
+		return 0, fmt.Errorf("not a time expression: %q", value)
+	}
+	return time.Duration(math.Round(seconds * float64(time.Second))), nil
+}
+
+// parseTTMLParagraph parses the timing, identifier and style references of a p element
+// whose parent begins at the given time.
+func parseTTMLParagraph(attrs []xml.Attr, parentBegin time.Duration, timing ttmlTiming) (item models.ModelItemSubtitle, err error) {
+	item.Start = parentBegin
+	if begin, ok := ttmlAttr(attrs, "begin"); ok {
+		d, err := parseTTMLTime(begin, timing)
+		if err != nil {
+			return item, err
+		}
+		item.Start += d
+	}
+	item.End = item.Start
+	if end, ok := ttmlAttr(attrs, "end"); ok {
+		d, err := parseTTMLTime(end, timing)
+		if err != nil {
+			return item, err
+		}
+		item.End = parentBegin + d
+	} else if dur, ok := ttmlAttr(attrs, "dur"); ok {
+		d, err := parseTTMLTime(dur, timing)
+		if err != nil {
+			return item, err
+		}
+		item.End = item.Start + d
+	} else {
+		return item, errors.New("missing end time")
+	}
+	item.ID, _ = ttmlAttr(attrs, "id")
+	item.Style, _ = ttmlAttr(attrs, "style")
+	return item, nil
+}
+
+// applyTTMLStyle applies the referenced styles and the styling attributes (tts:fontStyle,
+// tts:fontWeight, tts:textDecoration, tts:color, tts:fontFamily) of an element to the style.
+func applyTTMLStyle(style *models.ModelSpan, attrs []xml.Attr, styles map[string][]xml.Attr, depth int) {
+	// Referenced styles are applied first, and may reference other styles
+	if refs, ok := ttmlAttr(attrs, "style"); ok && depth < 8 {
+		for _, ref := range strings.Fields(refs) {
+			if referenced, ok := styles[ref]; ok {
+				applyTTMLStyle(style, referenced, styles, depth+1)
+			}
+		}
+	}
+
+	for _, attr := range attrs {
+		value := strings.TrimSpace(attr.Value)
+		switch attr.Name.Local {
+		case "fontStyle":
+			style.Italic = value == "italic" || value == "oblique"
+		case "fontWeight":
+			style.Bold = value == "bold"
+		case "textDecoration":
+			for _, decoration := range strings.Fields(value) {
+				switch decoration {
+				case "underline":
+					style.Underline = true
+				case "noUnderline", "none":
+					style.Underline = false
+				case "lineThrough":
+					style.StrikeOut = true
+				case "noLineThrough":
+					style.StrikeOut = false
+				}
+				if decoration == "none" {
+					style.StrikeOut = false
+				}
+			}
+		case "color":
+			style.Color = formatTTMLColor(value)
+		case "fontFamily":
+			style.Font = strings.Trim(strings.TrimSpace(strings.Split(value, ",")[0]), `"'`)
+		}
+	}
+}
+
+// formatTTMLColor converts a TTML color (#RRGGBB, #RRGGBBAA, rgb(r,g,b), rgba(r,g,b,a) or a
+// named color) to "#RRGGBB".
+func formatTTMLColor(color string) string {
+	if regexp.MustCompile(`^#[0-9a-fA-F]{8}$`).MatchString(color) {
+		return strings.ToUpper(color[:7])
+	}
+	res := regexp.MustCompile(`^rgba?\(\s*(\d+)\s*,\s*(\d+)\s*,\s*(\d+)`).FindStringSubmatch(color)
+	if len(res) == 4 {
+		return fmt.Sprintf("#%02X%02X%02X", toInt(res[1])&0xFF, toInt(res[2])&0xFF, toInt(res[3])&0xFF)
+	}
+	return formatHTMLColor(color)
+}
+
+// formatTTMLID converts a name to a valid xml:id value, replacing the invalid characters.
+func formatTTMLID(name string) string {
+	id := regexp.MustCompile(`[^A-Za-z0-9_.-]`).ReplaceAllString(name, "_")
+	if len(id) == 0 || !regexp.MustCompile(`^[A-Za-z_]`).MatchString(id) {
+		id = "_" + id
+	}
+	return id
+}
+
+// formatDuration2TTML converts a time.Duration to TTML clock time format string (hh:mm:ss.mmm).
+func formatDuration2TTML(d time.Duration) string {
+	// Extract the hour, minute, second, and millisecond components from the time.Duration
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	s := int(d.Seconds()) % 60
+	ms := int(d.Milliseconds()) % 1000
+
+	// Format the TTML time string
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", h, m, s, ms)
+}
+
+// escapeXML escapes the text for XML content and attribute values.
+func escapeXML(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(text)
+}
+
+// formatSpans2TTML renders the runs of a line as TTML text with span elements.
+func formatSpans2TTML(spans []models.ModelSpan) (content string) {
+	for _, span := range spans {
+		attrs := ""
+		if span.Italic {
+			attrs += ` tts:fontStyle="italic"`
+		}
+		if span.Bold {
+			attrs += ` tts:fontWeight="bold"`
+		}
+		if span.Underline || span.StrikeOut {
+			var decorations []string
+			if span.Underline {
+				decorations = append(decorations, "underline")
+			}
+			if span.StrikeOut {
+				decorations = append(decorations, "lineThrough")
+			}
+			attrs += ` tts:textDecoration="` + strings.Join(decorations, " ") + `"`
+		}
+		if len(span.Color) > 0 {
+			attrs += ` tts:color="` + escapeXML(span.Color) + `"`
+		}
+		if len(span.Font) > 0 {
+			attrs += ` tts:fontFamily="` + escapeXML(span.Font) + `"`
+		}
+		if len(attrs) == 0 {
+			content += escapeXML(span.Text)
+			continue
+		}
+		content += "<span" + attrs + ">" + escapeXML(span.Text) + "</span>"
+	}
+	return content
+}
+
+// WriteTTML converts subtitle data from the internal model to a TTML document of the IMSC1
+// text profile. The cues are shown in a bottom region unless they reference another region,
+// and the referenced styles are declared empty, as their styling is written in the spans.
+func WriteTTML(sub *models.Subtitle) (content string) {
+	// Collect the regions and styles referenced by the cues
+	var regions, styles []string
+	seen := map[string]bool{"region bottom": true}
+	for i := range sub.Lines {
+		if region := sub.Lines[i].Settings.Region; len(region) > 0 && !seen["region "+formatTTMLID(region)] {
+			seen["region "+formatTTMLID(region)] = true
+			regions = append(regions, formatTTMLID(region))
+		}
+		for _, style := range strings.Fields(sub.Lines[i].Style) {
+			if !seen["style "+formatTTMLID(style)] {
+				seen["style "+formatTTMLID(style)] = true
+				styles = append(styles, formatTTMLID(style))
+			}
+		}
+	}
+
+	encoding := sub.Encoding
+	if len(encoding) == 0 {
+		encoding = "UTF-8"
+	}
+	language := sub.Language
+	if len(language) == 0 {
+		language = "und"
+	}
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="` + escapeXML(encoding) + `"?>` + "\n")
+	b.WriteString(`<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttp="http://www.w3.org/ns/ttml#parameter" xmlns:tts="http://www.w3.org/ns/ttml#styling"` +
+		` ttp:profile="http://www.w3.org/ns/ttml/profile/imsc1/text" ttp:timeBase="media" xml:lang="` + escapeXML(language) + `">` + "\n")
+
+	// Write the styles and regions
+	b.WriteString("  <head>\n")
+	if len(styles) > 0 {
+		b.WriteString("    <styling>\n")
+		for _, style := range styles {
+			b.WriteString(`      <style xml:id="` + style + `"/>` + "\n")
+		}
+		b.WriteString("    </styling>\n")
+	}
+	b.WriteString("    <layout>\n")
+	for _, region := range append([]string{"bottom"}, regions...) {
+		b.WriteString(`      <region xml:id="` + region + `" tts:origin="10% 10%" tts:extent="80% 80%" tts:displayAlign="after" tts:textAlign="center"/>` + "\n")
+	}
+	b.WriteString("    </layout>\n")
+	b.WriteString("  </head>\n")
+
+	// Write the cues
+	b.WriteString(`  <body region="bottom">` + "\n")
+	b.WriteString("    <div>\n")
+	for i := range sub.Lines {
+		line := &sub.Lines[i]
+		attrs := ""
+		if len(line.ID) > 0 {
+			attrs += ` xml:id="` + formatTTMLID(line.ID) + `"`
+		}
+		attrs += ` begin="` + formatDuration2TTML(line.Start) + `" end="` + formatDuration2TTML(line.End) + `"`
+		if len(line.Settings.Region) > 0 {
+			attrs += ` region="` + formatTTMLID(line.Settings.Region) + `"`
+		}
+		if refs := strings.Fields(line.Style); len(refs) > 0 {
+			for j := range refs {
+				refs[j] = formatTTMLID(refs[j])
+			}
+			attrs += ` style="` + strings.Join(refs, " ") + `"`
+		}
+
+		texts := make([]string, len(line.Text))
+		for j := range line.Text {
+			if spans := lineSpans(line, j); spans != nil {
+				texts[j] = formatSpans2TTML(spans)
+			} else {
+				texts[j] = escapeXML(cleanText(line.Text[j]))
+			}
+		}
+		b.WriteString("      <p" + attrs + ">" + strings.Join(texts, "<br/>") + "</p>\n")
+	}
+	b.WriteString("    </div>\n")
+	b.WriteString("  </body>\n")
+	b.WriteString("</tt>\n")
+	return b.String()
+}
+
+// ttmlFormat implements the Format interface for TTML.
+type ttmlFormat struct{}
+
+func init() {
+	Register(ttmlFormat{})
+}
+
+// Name returns the name of the TTML format.
+func (ttmlFormat) Name() string { return "TTML" }
+
+// Extensions returns the file extensions of the TTML format.
+func (ttmlFormat) Extensions() []string { return []string{".ttml", ".dfxp", ".xml"} }
+
+// Probe returns the confidence that the content is TTML: a tt element, with a higher
+// confidence when it uses a TTML namespace.
+func (ttmlFormat) Probe(content string) float64 {
+	if !regexp.MustCompile(`<(?:[A-Za-z_][\w.-]*:)?tt[\s>]`).MatchString(content) {
+		return 0
+	}
+	if strings.Contains(content, "http://www.w3.org/ns/ttml") || strings.Contains(content, "ttaf1") {
+		return 1
+	}
+	return 0.6
+}
+
+// Read parses TTML content into sub, with the language and frame rate of the document.
+func (ttmlFormat) Read(content string, sub *models.Subtitle) (err error) {
+	sub.Lines, sub.Warnings, err = readTTML(content, sub)
+	return err
+}
+
+// Write converts the subtitle to a TTML document of the IMSC1 text profile.
+func (ttmlFormat) Write(sub *models.Subtitle) string { return WriteTTML(sub) }
@@ -0,0 +1,361 @@
+package format
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+)
+
+/*
+WebVTT Format Specification:
+
+Regular Expression for timing line validation:
+^((?:\d+:)?\d{2}:\d{2}\.\d{3})[ \t]+-->[ \t]+((?:\d+:)?\d{2}:\d{2}\.\d{3})(.*)$
+
+Example WebVTT Format:
+WEBVTT
+
+STYLE
+::cue {
+  color: yellow;
+}
+
+REGION
+id:bottom
+width:40%
+
+NOTE This is a comment
+
+1
+00:02:17.440 --> 00:02:20.375 line:90% align:center
+<v Captain>Senator, we're <i>making</i>
+our final approach into Coruscant.
+
+02:20.476 --> 02:22.501
+Very good, Lieutenant.
+*/
+
+// ReadVTT parses WebVTT formatted subtitle content and converts it to the internal model.
+// The NOTE, STYLE and REGION blocks are returned separately from the cues.
+// Returns a *ParseError if the content is not a valid WebVTT format.
+func ReadVTT(content string) (ret []models.ModelItemSubtitle, blocks []models.ModelBlock, err error) {
+	ret, blocks, _, err = readVTT(content)
+	return ret, blocks, err
+}
+
+// readVTT parses WebVTT formatted subtitle content like ReadVTT, and also returns the
+// problems found in the cues that were parsed or skipped as warnings.
+func readVTT(content string) (ret []models.ModelItemSubtitle, blocks []models.ModelBlock, warnings []error, err error) {
+	content = strings.Replace(strings.TrimPrefix(content, "\ufeff"), "\r\n", "\n", -1)
+
+	// The file must start with the WEBVTT signature
+	if content != "WEBVTT" && !strings.HasPrefix(content, "WEBVTT ") && !strings.HasPrefix(content, "WEBVTT\t") &&
+		!strings.HasPrefix(content, "WEBVTT\n") && !strings.HasPrefix(content, "WEBVTT\r") {
+		return nil, nil, nil, &ParseError{Format: "VTT", Line: 1, Column: 1, Reason: "Invalid VTT: missing WEBVTT signature"}
+	}
+
+	// Split the content into blocks separated by blank lines, skipping the header block
+	lines := strings.Split(content, "\n")
+	var block []string
+	blockLine := 0
+	inHeader := true
+	for i := 0; i <= len(lines); i++ {
+		line := ""
+		if i < len(lines) {
+			line = strings.TrimRight(cleanControl(lines[i]), " \t")
+		}
+
+		if line != "" {
+			if len(block) == 0 {
+				blockLine = i + 1
+			}
+			block = append(block, line)
+			continue
+		}
+		if len(block) == 0 {
+			continue
+		}
+
+		// The first block holds the WEBVTT signature and optional header text
+		if inHeader {
+			inHeader = false
+			block = nil
+			continue
+		}
+
+		// Blocks that are not cues, and cues
+		var previous *models.ModelItemSubtitle
+		if len(ret) > 0 {
+			previous = &ret[len(ret)-1]
+		}
+		cue, other, blockWarnings := readVTTBlock(block, StreamPosition{Line: blockLine, Cue: len(ret) + 1, Previous: previous})
+		warnings = append(warnings, blockWarnings...)
+		if other != nil {
+			blocks = append(blocks, *other)
+		}
+		if cue != nil {
+			ret = append(ret, *cue)
+		}
+		block = nil
+	}
+
+	// If no cues were found, return an error
+	if len(ret) == 0 {
+		return nil, nil, warnings, &ParseError{Format: "VTT", Reason: "Invalid VTT: no cues found"}
+	}
+
+	return ret, blocks, warnings, nil
+}
+
+// readVTTBlock parses a block of WebVTT content at a position of the content into a cue,
+// numbered with the index of the cue, or a block that is not a cue. Also returns the problems
+// found in the block as warnings.
+func readVTTBlock(block []string, pos StreamPosition) (cue *models.ModelItemSubtitle, other *models.ModelBlock, warnings []error) {
+	// warn records a problem of the block
+	warn := func(line int, cue int, text string, reason string) {
+		warnings = append(warnings, &ParseError{Format: "VTT", Line: line, Column: 1, Cue: cue, Text: text, Reason: reason})
+	}
+
+	// Blocks that are not cues
+	kind := vttBlockKind(block[0])
+	if len(kind) > 0 {
+		body := []string{}
+		if first := strings.TrimSpace(strings.TrimPrefix(block[0], kind)); len(first) > 0 {
+			body = append(body, first)
+		}
+		body = append(body, block[1:]...)
+		return nil, &models.ModelBlock{Kind: kind, Text: body}, nil
+	}
+
+	// Cue blocks, with an optional identifier before the timing line
+	cue = &models.ModelItemSubtitle{}
+	timing := 0
+	if !strings.Contains(block[0], "-->") && len(block) > 1 {
+		cue.ID = block[0]
+		timing = 1
+	}
+	start, end, settings, errTiming := formatStringVTT2Duration(block[timing])
+	if errTiming != nil {
+		warn(pos.Line+timing, 0, block[timing], "block without valid timestamp skipped")
+		return nil, nil, warnings
+	}
+	if end < start {
+		warn(pos.Line+timing, pos.Cue, block[timing], "end time before start time")
+	}
+	if pos.Previous != nil && start < pos.Previous.Start {
+		warn(pos.Line+timing, pos.Cue, block[timing], "timestamp out of order")
+	}
+	if len(block) == timing+1 {
+		warn(pos.Line+timing, pos.Cue, block[timing], fmt.Sprintf("cue %d has no text", pos.Cue))
+	}
+	cue.Seq = pos.Cue
+	cue.Start = start
+	cue.End = end
+	cue.Settings = settings
+	for _, text := range block[timing+1:] {
+		cue.Text = append(cue.Text, cleanText(text))
+	}
+
+	// Keep the styling tags as spans, the plain text as text and the voice as actor
+	cue.Text, cue.Spans, cue.Actor = parseHTMLTags(cue.Text, true)
+	return cue, nil, warnings
+}
+
+// vttBlockKind returns the kind of a non-cue WebVTT block from its first line,
+// or an empty string if the block is a cue.
+func vttBlockKind(line string) string {
+	if strings.Contains(line, "-->") {
+		return ""
+	}
+	for _, kind := range []string{"NOTE", "STYLE", "REGION"} {
+		if line == kind || strings.HasPrefix(line, kind+" ") || strings.HasPrefix(line, kind+"\t") {
+			return kind
+		}
+	}
+	return ""
+}
+
+// formatStringVTT2Duration parses a WebVTT timing line (00:00:00.000 --> 00:00:00.000 settings)
+// and converts it to start and end time.Duration values and the cue settings.
+func formatStringVTT2Duration(line string) (start time.Duration, end time.Duration, settings models.ModelCueSettings, err error) {
+	exp := regexp.MustCompile(`^(?:(\d+):)?(\d{2}):(\d{2})\.(\d{3})[ \t]+-->[ \t]+(?:(\d+):)?(\d{2}):(\d{2})\.(\d{3})(.*)$`)
+	res := exp.FindStringSubmatch(line)
+	if len(res) != 10 {
+		return start, end, settings, errors.New("not time")
+	}
+	start = formatSSA2Duration(res[1], res[2], res[3], res[4])
+	end = formatSSA2Duration(res[5], res[6], res[7], res[8])
+
+	// Parse the cue settings (name:value pairs separated by spaces)
+	for _, setting := range strings.Fields(res[9]) {
+		parts := strings.SplitN(setting, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "vertical":
+			settings.Vertical = parts[1]
+		case "line":
+			settings.Line = parts[1]
+		case "position":
+			settings.Position = parts[1]
+		case "size":
+			settings.Size = parts[1]
+		case "align":
+			settings.Align = parts[1]
+		case "region":
+			settings.Region = parts[1]
+		}
+	}
+	return start, end, settings, nil
+}
+
+// formatDuration2VTT converts a time.Duration to WebVTT time format string (hh:mm:ss.mmm).
+func formatDuration2VTT(d time.Duration) string {
+	// Extract the hour, minute, second, and millisecond components from the time.Duration
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	s := int(d.Seconds()) % 60
+	ms := int(d.Milliseconds()) % 1000
+
+	// Format the WebVTT time string
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", h, m, s, ms)
+}
+
+// formatSettings2VTT converts the cue settings to the WebVTT settings string,
+// including the leading space when any setting is present.
+func formatSettings2VTT(settings models.ModelCueSettings) (content string) {
+	pairs := []struct {
+		name  string
+		value string
+	}{
+		{"vertical", settings.Vertical},
+		{"line", settings.Line},
+		{"position", settings.Position},
+		{"size", settings.Size},
+		{"align", settings.Align},
+		{"region", settings.Region},
+	}
+	for _, pair := range pairs {
+		if len(pair.value) > 0 {
+			content += " " + pair.name + ":" + pair.value
+		}
+	}
+	return content
+}
+
+// WriteVTT converts subtitle data from the internal model to WebVTT formatted content.
+// The NOTE, STYLE and REGION blocks are written before the first cue.
+func WriteVTT(sub *models.Subtitle) (content string) {
+	var b strings.Builder
+	b.WriteString(writeVTTHeader(sub))
+	for i := range sub.Lines {
+		b.WriteString(writeVTTCue(&sub.Lines[i]))
+	}
+	return b.String()
+}
+
+// writeVTTHeader returns the WEBVTT signature and the blocks that are not cues of the subtitle.
+func writeVTTHeader(sub *models.Subtitle) (content string) {
+	content = "WEBVTT\n\n"
+
+	// Write the blocks that are not cues
+	for _, block := range sub.Blocks {
+		content += block.Kind
+		if block.Kind == "NOTE" && len(block.Text) > 0 {
+			content += " " + strings.Join(block.Text, "\n") + "\n\n"
+			continue
+		}
+		content += "\n"
+		for _, text := range block.Text {
+			content += text + "\n"
+		}
+		content += "\n"
+	}
+	return content
+}
+
+// writeVTTCue converts a subtitle to a WebVTT cue, followed by an empty line.
+func writeVTTCue(item *models.ModelItemSubtitle) (content string) {
+	if len(item.ID) > 0 {
+		content += item.ID + "\n"
+	}
+	content += fmt.Sprintf("%s --> %s%s\n", formatDuration2VTT(item.Start), formatDuration2VTT(item.End), formatSettings2VTT(item.Settings))
+	for j := range item.Text {
+		// Write the styling tags while the text is unchanged
+		text := escapeVTT(cleanText(item.Text[j]))
+		if spans := lineSpans(item, j); spans != nil {
+			text = formatSpans2VTT(spans)
+		}
+
+		// The speaker is written as a voice tag on the first line
+		if j == 0 && len(item.Actor) > 0 {
+			text = "<v " + item.Actor + ">" + text
+		}
+		content += text + "\n"
+	}
+	return content + "\n"
+}
+
+// vttFormat implements the Format interface for WebVTT.
+type vttFormat struct{}
+
+func init() {
+	Register(vttFormat{})
+}
+
+// Name returns the name of the WebVTT format.
+func (vttFormat) Name() string { return "VTT" }
+
+// Extensions returns the file extensions of the WebVTT format.
+func (vttFormat) Extensions() []string { return []string{".vtt"} }
+
+// Probe returns the confidence that the content is WebVTT, which is identified by its WEBVTT signature.
+func (vttFormat) Probe(content string) float64 {
+	content = strings.TrimPrefix(content, "\ufeff")
+	if content == "WEBVTT" || regexp.MustCompile(`^WEBVTT[ \t\r\n]`).MatchString(content) {
+		return 1
+	}
+	return 0
+}
+
+// Read parses WebVTT content into sub.
+func (vttFormat) Read(content string, sub *models.Subtitle) (err error) {
+	sub.Lines, sub.Blocks, sub.Warnings, err = readVTT(content)
+	return err
+}
+
+// Write converts the subtitle to WebVTT content.
+func (vttFormat) Write(sub *models.Subtitle) string { return WriteVTT(sub) }
+
+// ReadBlock parses a block of WebVTT content into its cue, skipping the header block with the
+// WEBVTT signature. The NOTE, STYLE and REGION blocks are stored in the Blocks of sub.
+func (f vttFormat) ReadBlock(block string, pos StreamPosition, sub *models.Subtitle) ([]models.ModelItemSubtitle, []error) {
+	if f.Probe(block) > 0 {
+		return nil, nil
+	}
+	lines := strings.Split(block, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(cleanControl(lines[i]), " \t\r")
+	}
+	cue, other, warnings := readVTTBlock(lines, pos)
+	if other != nil {
+		sub.Blocks = append(sub.Blocks, *other)
+	}
+	if cue == nil {
+		return nil, warnings
+	}
+	return []models.ModelItemSubtitle{*cue}, warnings
+}
+
+// WriteHeader returns the WEBVTT signature and the NOTE, STYLE and REGION blocks of the subtitle.
+func (vttFormat) WriteHeader(sub *models.Subtitle) string { return writeVTTHeader(sub) }
+
+// WriteCue converts a subtitle to a WebVTT cue.
+func (vttFormat) WriteCue(item *models.ModelItemSubtitle, sub *models.Subtitle) string {
+	return writeVTTCue(item)
+}
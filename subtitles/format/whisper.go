@@ -0,0 +1,430 @@
+package format
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+)
+
+/*
+Whisper Format Specification:
+
+The JSON transcripts of speech recognition engines, with the segments of speech and the
+timing and confidence of each word:
+
+OpenAI Whisper (verbose JSON): a "segments" array with the "start" and "end" times in seconds,
+the "text" and, with word timestamps, the "words" with their "probability". The API can also
+return a top-level "words" array only.
+
+whisper.cpp (-oj, -ojf): a "transcription" array with the "offsets" in milliseconds, the "text"
+and, with the full output, the "tokens" with their probability "p", which are joined into words.
+
+Vosk: a "result" array of words with the "start" and "end" times in seconds and the "conf",
+in an object or in an array of objects (one per utterance).
+
+The subtitles are the segments (the utterances of Vosk), unless a Segmentation is given: then
+the words of all the segments are split into new subtitles at the maximum characters, the
+maximum duration and the end of the sentences.
+
+Example Whisper Format:
+{
+  "language": "en",
+  "text": " Hello world.",
+  "segments": [
+    {
+      "id": 0,
+      "start": 1.0,
+      "end": 2.5,
+      "text": " Hello world.",
+      "words": [
+        {"word": " Hello", "start": 1.0, "end": 1.5, "probability": 0.98},
+        {"word": " world.", "start": 1.5, "end": 2.5, "probability": 0.95}
+      ]
+    }
+  ]
+}
+*/
+
+// whisperTranscript is the JSON document of the transcript of OpenAI Whisper, and the object
+// of whisper.cpp and Vosk transcripts.
+type whisperTranscript struct {
+	Language      string              `json:"language,omitempty"`
+	Text          string              `json:"text"`
+	Segments      []whisperSegment    `json:"segments"`
+	Words         []whisperWord       `json:"words,omitempty"`
+	Transcription []whisperCppSegment `json:"transcription,omitempty"`
+	Result        json.RawMessage     `json:"result,omitempty"`
+}
+
+// whisperSegment is a segment of speech of an OpenAI Whisper transcript.
+type whisperSegment struct {
+	ID    int           `json:"id"`
+	Start float64       `json:"start"`
+	End   float64       `json:"end"`
+	Text  string        `json:"text"`
+	Words []whisperWord `json:"words,omitempty"`
+}
+
+// whisperWord is a word of an OpenAI Whisper transcript.
+type whisperWord struct {
+	Word        string  `json:"word"`
+	Start       float64 `json:"start"`
+	End         float64 `json:"end"`
+	Probability float64 `json:"probability,omitempty"`
+}
+
+// whisperCppOffsets are the times in milliseconds of a whisper.cpp segment or token.
+type whisperCppOffsets struct {
+	From float64 `json:"from"`
+	To   float64 `json:"to"`
+}
+
+// whisperCppSegment is a segment of speech of a whisper.cpp transcript.
+type whisperCppSegment struct {
+	Offsets whisperCppOffsets `json:"offsets"`
+	Text    string            `json:"text"`
+	Tokens  []whisperCppToken `json:"tokens"`
+}
+
+// whisperCppToken is a token of a whisper.cpp segment, a word or a part of a word.
+type whisperCppToken struct {
+	Text    string            `json:"text"`
+	Offsets whisperCppOffsets `json:"offsets"`
+	P       float64           `json:"p"`
+}
+
+// voskUtterance is an utterance of a Vosk transcript.
+type voskUtterance struct {
+	Result []voskWord `json:"result"`
+	Text   string     `json:"text"`
+}
+
+// voskWord is a word of a Vosk transcript.
+type voskWord struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Conf  float64 `json:"conf"`
+}
+
+// ReadWhisper parses the JSON transcript of a speech recognition engine (OpenAI Whisper,
+// whisper.cpp or Vosk) and converts it to the internal model, with the timing and confidence
+// of the words in Words. The segments are the subtitles, unless seg is not zero: then the
+// words are split into new subtitles (see SegmentWords). Also returns the language of the
+// transcript, if known.
+// Returns a *ParseError if the content is not a valid transcript.
+func ReadWhisper(content string, seg models.Segmentation) (ret []models.ModelItemSubtitle, language string, err error) {
+	ret, language, _, err = readWhisper(content, seg)
+	return ret, language, err
+}
+
+// readWhisper parses the JSON transcript of a speech recognition engine like ReadWhisper, and
+// also returns the problems found in the segments as warnings.
+func readWhisper(content string, seg models.Segmentation) (ret []models.ModelItemSubtitle, language string, warnings []error, err error) {
+	content = strings.TrimSpace(strings.TrimPrefix(content, "\ufeff"))
+
+	// Vosk utterances in an array
+	var segments []models.ModelItemSubtitle
+	if strings.HasPrefix(content, "[") {
+		var utterances []voskUtterance
+		if err = json.Unmarshal([]byte(content), &utterances); err != nil {
+			return nil, "", nil, jsonParseError("Whisper", content, err)
+		}
+		for _, utterance := range utterances {
+			segments = append(segments, voskSegment(utterance))
+		}
+	} else {
+		var doc whisperTranscript
+		if err = json.Unmarshal([]byte(content), &doc); err != nil {
+			return nil, "", nil, jsonParseError("Whisper", content, err)
+		}
+		language = doc.Language
+		switch {
+		case doc.Segments != nil:
+			for _, segment := range doc.Segments {
+				start, okStart := parseSeconds(segment.Start)
+				end, okEnd := parseSeconds(segment.End)
+				if !okStart || !okEnd {
+					warnings = append(warnings, &ParseError{Format: "Whisper", Text: strings.TrimSpace(segment.Text), Reason: "segment with an invalid time"})
+					continue
+				}
+				item := models.ModelItemSubtitle{Start: start, End: end, Text: []string{strings.TrimSpace(segment.Text)}}
+				item.Words = whisperWords(segment.Words)
+				segments = append(segments, item)
+			}
+		case doc.Transcription != nil:
+			var result struct {
+				Language string `json:"language"`
+			}
+			if json.Unmarshal(doc.Result, &result) == nil {
+				language = result.Language
+			}
+			for _, segment := range doc.Transcription {
+				start, okStart := parseMilliseconds(segment.Offsets.From)
+				end, okEnd := parseMilliseconds(segment.Offsets.To)
+				if !okStart || !okEnd {
+					warnings = append(warnings, &ParseError{Format: "Whisper", Text: strings.TrimSpace(segment.Text), Reason: "segment with an invalid time"})
+					continue
+				}
+				item := models.ModelItemSubtitle{Start: start, End: end, Text: []string{strings.TrimSpace(segment.Text)}}
+				item.Words = whisperCppWords(segment.Tokens)
+				segments = append(segments, item)
+			}
+		case strings.HasPrefix(string(doc.Result), "["):
+			utterance := voskUtterance{Text: doc.Text}
+			if err = json.Unmarshal(doc.Result, &utterance.Result); err != nil {
+				return nil, "", nil, jsonParseError("Whisper", content, err)
+			}
+			segments = append(segments, voskSegment(utterance))
+		case doc.Words != nil:
+			words := whisperWords(doc.Words)
+			if len(words) > 0 {
+				segments = append(segments, models.ModelItemSubtitle{Start: words[0].Start, End: words[len(words)-1].End, Text: []string{strings.TrimSpace(doc.Text)}, Words: words})
+			}
+		}
+	}
+
+	// Check the segments, the segments without text are silences
+	var words []models.ModelWord
+	hasWords := true
+	for _, item := range segments {
+		if len(item.Text[0]) == 0 && len(item.Words) == 0 {
+			continue
+		}
+		seq := len(ret) + 1
+		item.Seq = seq
+		if item.End < item.Start {
+			warnings = append(warnings, &ParseError{Format: "Whisper", Cue: seq, Text: item.Text[0], Reason: "end time before start time"})
+		}
+		if len(ret) > 0 && item.Start < ret[len(ret)-1].Start {
+			warnings = append(warnings, &ParseError{Format: "Whisper", Cue: seq, Text: item.Text[0], Reason: "timestamp out of order"})
+		}
+		ret = append(ret, item)
+		words = append(words, item.Words...)
+		hasWords = hasWords && len(item.Words) > 0
+	}
+
+	// Split the words into new subtitles, when every segment has words
+	if seg != (models.Segmentation{}) && hasWords && len(words) > 0 {
+		ret = SegmentWords(words, seg)
+	}
+
+	// If no subtitles were found, return an error
+	if len(ret) == 0 {
+		return nil, language, warnings, &ParseError{Format: "Whisper", Reason: "Invalid Whisper: no segments found"}
+	}
+
+	return ret, language, warnings, nil
+}
+
+// whisperWords converts the words of an OpenAI Whisper transcript to timed words.
+// The words with an invalid time are skipped.
+func whisperWords(words []whisperWord) (ret []models.ModelWord) {
+	for _, word := range words {
+		start, okStart := parseSeconds(word.Start)
+		end, okEnd := parseSeconds(word.End)
+		if text := strings.TrimSpace(word.Word); len(text) > 0 && okStart && okEnd {
+			ret = append(ret, models.ModelWord{Text: text, Start: start, End: end, Confidence: word.Probability})
+		}
+	}
+	return ret
+}
+
+// whisperCppWords joins the tokens of a whisper.cpp segment into timed words: a token starting
+// with a space starts a word, the others are added to the previous word. The special tokens
+// ([_BEG_], [_TT_...]) and the tokens with an invalid time are skipped, and the confidence of
+// a word is the mean probability of its tokens.
+func whisperCppWords(tokens []whisperCppToken) (ret []models.ModelWord) {
+	count := 0
+	for _, token := range tokens {
+		start, okStart := parseMilliseconds(token.Offsets.From)
+		end, okEnd := parseMilliseconds(token.Offsets.To)
+		if strings.HasPrefix(token.Text, "[_") || len(strings.TrimSpace(token.Text)) == 0 || !okStart || !okEnd {
+			continue
+		}
+		if len(ret) == 0 || strings.HasPrefix(token.Text, " ") {
+			ret = append(ret, models.ModelWord{Text: strings.TrimSpace(token.Text), Start: start, End: end, Confidence: token.P})
+			count = 1
+			continue
+		}
+		last := &ret[len(ret)-1]
+		last.Text += strings.TrimSpace(token.Text)
+		last.End = end
+		last.Confidence = (last.Confidence*float64(count) + token.P) / float64(count+1)
+		count++
+	}
+	return ret
+}
+
+// voskSegment converts a Vosk utterance to a subtitle, from the start of its first word to the
+// end of its last word. The words with an invalid time are skipped.
+func voskSegment(utterance voskUtterance) (item models.ModelItemSubtitle) {
+	var texts []string
+	for _, word := range utterance.Result {
+		start, okStart := parseSeconds(word.Start)
+		end, okEnd := parseSeconds(word.End)
+		if text := strings.TrimSpace(word.Word); len(text) > 0 && okStart && okEnd {
+			item.Words = append(item.Words, models.ModelWord{Text: text, Start: start, End: end, Confidence: word.Conf})
+			texts = append(texts, text)
+		}
+	}
+	if len(item.Words) > 0 {
+		item.Start, item.End = item.Words[0].Start, item.Words[len(item.Words)-1].End
+	}
+	text := strings.TrimSpace(utterance.Text)
+	if len(text) == 0 {
+		text = strings.Join(texts, " ")
+	}
+	item.Text = []string{text}
+	return item
+}
+
+// SegmentWords splits timed words into subtitles of one line. A subtitle ends before the word
+// that would make it longer than the MaxChars or the MaxDuration of the segmentation, and after
+// a word ending a sentence (".", "?", "!") when Punctuation is set. The words are kept in Words.
+func SegmentWords(words []models.ModelWord, seg models.Segmentation) (ret []models.ModelItemSubtitle) {
+	var item *models.ModelItemSubtitle
+
+	// flush ends the current subtitle
+	flush := func() {
+		if item != nil {
+			ret = append(ret, *item)
+			item = nil
+		}
+	}
+
+	for _, word := range words {
+		word.Text = strings.TrimSpace(word.Text)
+		if len(word.Text) == 0 {
+			continue
+		}
+
+		// End the subtitle before the word if it would be too long
+		if item != nil {
+			line := item.Text[0] + " " + word.Text
+			if (seg.MaxChars > 0 && utf8.RuneCountInString(line) > seg.MaxChars) ||
+				(seg.MaxDuration > 0 && word.End-item.Start > seg.MaxDuration) {
+				flush()
+			}
+		}
+
+		if item == nil {
+			item = &models.ModelItemSubtitle{Seq: len(ret) + 1, Start: word.Start, Text: []string{word.Text}}
+		} else {
+			item.Text[0] += " " + word.Text
+		}
+		item.End = word.End
+		item.Words = append(item.Words, word)
+
+		// End the subtitle at the end of a sentence
+		if r, _ := utf8.DecodeLastRuneInString(word.Text); seg.Punctuation && strings.ContainsRune(".?!…。？！", r) {
+			flush()
+		}
+	}
+	flush()
+	return ret
+}
+
+// parseSeconds converts a number of seconds to a time.Duration, rounded to milliseconds.
+// Reports whether the number is valid like parseMilliseconds.
+func parseSeconds(seconds float64) (time.Duration, bool) {
+	return parseMilliseconds(seconds * 1000)
+}
+
+// WriteWhisper converts subtitle data from the internal model to the OpenAI Whisper verbose JSON
+// transcript, with the Words of each subtitle and their confidence as probability.
+func WriteWhisper(sub *models.Subtitle) (content string) {
+	doc := whisperTranscript{Language: sub.Language, Segments: []whisperSegment{}}
+	var texts []string
+	for i := range sub.Lines {
+		lines := make([]string, len(sub.Lines[i].Text))
+		for j := range sub.Lines[i].Text {
+			lines[j] = cleanText(sub.Lines[i].Text[j])
+		}
+		segment := whisperSegment{
+			ID:    i,
+			Start: sub.Lines[i].Start.Seconds(),
+			End:   sub.Lines[i].End.Seconds(),
+			Text:  strings.Join(lines, " "),
+		}
+		for _, word := range sub.Lines[i].Words {
+			segment.Words = append(segment.Words, whisperWord{Word: word.Text, Start: word.Start.Seconds(), End: word.End.Seconds(), Probability: word.Confidence})
+		}
+		doc.Segments = append(doc.Segments, segment)
+		texts = append(texts, segment.Text)
+	}
+	doc.Text = strings.Join(texts, " ")
+
+	raw, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return ""
+	}
+	return string(raw) + "\n"
+}
+
+// whisperFormat implements the Format interface for the transcripts of Whisper and other
+// speech recognition engines.
+type whisperFormat struct{}
+
+func init() {
+	Register(whisperFormat{})
+}
+
+// Name returns the name of the Whisper format.
+func (whisperFormat) Name() string { return "Whisper" }
+
+// Extensions returns the file extensions of the Whisper format.
+func (whisperFormat) Extensions() []string { return []string{".json"} }
+
+// Probe returns the confidence that the content is a speech recognition transcript: a JSON
+// object with a "segments", "transcription", "words" or "result" array, or an array of objects
+// with a "result" array.
+func (whisperFormat) Probe(content string) float64 {
+	content = strings.TrimSpace(strings.TrimPrefix(content, "\ufeff"))
+	if strings.HasPrefix(content, "[") {
+		var utterances []struct {
+			Result []json.RawMessage `json:"result"`
+		}
+		if json.Unmarshal([]byte(content), &utterances) == nil {
+			for _, utterance := range utterances {
+				if utterance.Result != nil {
+					return 0.9
+				}
+			}
+		}
+		return 0
+	}
+	if !strings.HasPrefix(content, "{") {
+		return 0
+	}
+	var doc struct {
+		Segments      []json.RawMessage `json:"segments"`
+		Transcription []json.RawMessage `json:"transcription"`
+		Words         []json.RawMessage `json:"words"`
+		Result        json.RawMessage   `json:"result"`
+	}
+	if json.Unmarshal([]byte(content), &doc) != nil {
+		return 0
+	}
+	if doc.Segments != nil || doc.Transcription != nil || doc.Words != nil || strings.HasPrefix(string(doc.Result), "[") {
+		return 0.9
+	}
+	return 0
+}
+
+// Read parses a speech recognition transcript into sub, with the language of the transcript
+// and the words split by the Segmentation of the subtitle.
+func (whisperFormat) Read(content string, sub *models.Subtitle) (err error) {
+	var language string
+	sub.Lines, language, sub.Warnings, err = readWhisper(content, sub.Segmentation)
+	if err == nil && len(language) > 0 {
+		sub.Language = language
+	}
+	return err
+}
+
+// Write converts the subtitle to an OpenAI Whisper transcript.
+func (whisperFormat) Write(sub *models.Subtitle) string { return WriteWhisper(sub) }
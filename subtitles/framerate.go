@@ -0,0 +1,119 @@
+package subtitles
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+)
+
+// ntscFrameRates are the integer frame rates slowed down by 1000/1001 for NTSC video
+// (e.g., 24000/1001 = 23.976 fps, 30000/1001 = 29.97 fps).
+var ntscFrameRates = []int64{24, 30, 48, 60, 120}
+
+// ntscTolerance is the difference between a frame rate and an NTSC frame rate under which
+// the frame rate is the NTSC one rounded (e.g., 23.976 or 23.98 for 24000/1001).
+const ntscTolerance = 0.005
+
+// frameRateRatio returns a frame rate as the exact fraction num/den of frames per second:
+// the NTSC frame rates (e.g., 23.976, 29.97) are 24000/1001, 30000/1001, ..., and the others
+// are rounded to the thousandth of frame per second.
+// Returns ErrInvalidFrameRate if the frame rate is not positive.
+func frameRateRatio(rate float64) (num, den int64, err error) {
+	if math.IsNaN(rate) || math.IsInf(rate, 0) || rate <= 0 {
+		return 0, 0, fmt.Errorf("%w: %v", ErrInvalidFrameRate, rate)
+	}
+	for _, base := range ntscFrameRates {
+		if math.Abs(rate-float64(base*1000)/1001) < ntscTolerance {
+			return base * 1000, 1001, nil
+		}
+	}
+	if whole := math.Round(rate); math.Abs(rate-whole) < 1e-9 {
+		return int64(whole), 1, nil
+	}
+	num, den = int64(math.Round(rate*1000)), 1000
+	if num == 0 {
+		return 0, 0, fmt.Errorf("%w: %v", ErrInvalidFrameRate, rate)
+	}
+	g := new(big.Int).GCD(nil, nil, big.NewInt(num), big.NewInt(den)).Int64()
+	return num / g, den / g, nil
+}
+
+// mulDiv returns x * num / den, for a positive den, rounded with the given rounding.
+// The product is computed exactly, without overflow.
+func mulDiv(x int64, num, den *big.Int, rounding models.FrameRounding) int64 {
+	n := new(big.Int).Mul(big.NewInt(x), num)
+	q, m := new(big.Int).DivMod(n, den, new(big.Int))
+	switch rounding {
+	case models.RoundDown:
+	case models.RoundUp:
+		if m.Sign() > 0 {
+			q.Add(q, big.NewInt(1))
+		}
+	default:
+		if m.Lsh(m, 1).Cmp(den) >= 0 {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q.Int64()
+}
+
+// ConvertFrameRate retimes the cues of a subtitle made for a video at the from frame rate to
+// the same video played at the to frame rate, as when a film is sped up from 23.976 fps to
+// 25 fps for PAL television: every frame keeps its cues, so a time t becomes t * from / to.
+// The NTSC frame rates (e.g., 23.976, 29.97) are handled as the exact fractions 24000/1001,
+// 30000/1001, ..., without accumulated floating point error. FrameRate is set to the to
+// frame rate, so frame-based formats (e.g., MicroDVD) save the same frames.
+// Returns ErrInvalidFrameRate if a frame rate is not positive.
+func (sub *Subtitle) ConvertFrameRate(from, to float64) error {
+	fromNum, fromDen, err := frameRateRatio(from)
+	if err != nil {
+		return err
+	}
+	toNum, toDen, err := frameRateRatio(to)
+	if err != nil {
+		return err
+	}
+
+	// t * (fromNum / fromDen) / (toNum / toDen)
+	num := new(big.Int).Mul(big.NewInt(fromNum), big.NewInt(toDen))
+	den := new(big.Int).Mul(big.NewInt(fromDen), big.NewInt(toNum))
+	sub.retime(Selection{}, func(t time.Duration) time.Duration {
+		return time.Duration(mulDiv(int64(t), num, den, models.RoundNearest))
+	})
+	sub.FrameRate = to
+	return nil
+}
+
+// SnapToFrames moves the times of the cues to the start of the frames of a video at the given
+// frame rate, chosen with the rounding (e.g., RoundDown for the frame shown at the time), so
+// the cues start and end exactly on frame boundaries. The NTSC frame rates are handled as
+// exact fractions like ConvertFrameRate, and snapping snapped times doesn't change them.
+// The cues ending at zero once snapped are removed and returned.
+// Returns ErrInvalidFrameRate if the frame rate is not positive.
+func (sub *Subtitle) SnapToFrames(rate float64, rounding models.FrameRounding) (dropped []models.ModelItemSubtitle, err error) {
+	num, den, err := frameRateRatio(rate)
+	if err != nil {
+		return nil, err
+	}
+
+	// The frame of a time is t * num / (den * 1s), and the time of a frame f * den * 1s / num
+	frameNum := big.NewInt(num)
+	frameDen := new(big.Int).Mul(big.NewInt(den), big.NewInt(int64(time.Second)))
+	halfDen := new(big.Int).Lsh(frameDen, 1)
+	return sub.retime(Selection{}, func(t time.Duration) time.Duration {
+		// Ignore the half nanosecond of error of times that were rounded from frames
+		var frame int64
+		switch rounding {
+		case models.RoundDown:
+			frame = mulDiv(2*int64(t)+1, frameNum, halfDen, rounding)
+		case models.RoundUp:
+			frame = mulDiv(2*int64(t)-1, frameNum, halfDen, rounding)
+		default:
+			frame = mulDiv(int64(t), frameNum, frameDen, rounding)
+		}
+		return time.Duration(mulDiv(frame, frameDen, frameNum, models.RoundNearest))
+	}), nil
+}
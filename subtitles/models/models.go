@@ -5,16 +5,131 @@ import "time"
 
 // Subtitle represents a subtitle file with its metadata and content.
 type Subtitle struct {
-	Filename string                // Path to the subtitle file
-	Format   string                // Format of the subtitle file (e.g., "SRT", "SSA")
-	Lines    []ModelItemSubtitle   // Collection of subtitle entries
-	Verbose  bool                  // Whether to print processing information
+	Filename      string              // Path to the subtitle file
+	Format        string              // Format of the subtitle file (e.g., "SRT", "SSA", "VTT")
+	Lines         []ModelItemSubtitle // Collection of subtitle entries
+	Blocks        []ModelBlock        // Non-cue blocks of the file (e.g., WebVTT NOTE, STYLE, REGION)
+	Script        ModelScript         // Script header of the file (SSA/ASS)
+	Language      string              // Language of the text as a BCP 47 tag (e.g., "en"), if known
+	Metadata      []ModelInfo         // Metadata tags of the file (e.g., LRC [ar:] artist and [ti:] title)
+	Warnings      []error             // Non-fatal problems found while parsing (e.g., *format.ParseError)
+	Encoding      string              // Character encoding of the file (e.g., "windows-1252"), detected when loading and used when saving (UTF-8 if empty)
+	BOM           bool                // Whether the file starts with a byte order mark, detected when loading and written when saving
+	InputEncoding string              // Character encoding used when loading instead of detecting it (e.g., "ISO-8859-7")
+	FrameRate     float64             // Frames per second of frame-based formats (e.g., MicroDVD), used when loading and saving
+	FrameRounding FrameRounding       // Rounding of times to frames when saving frame-based formats
+	MaxDuration   time.Duration       // Maximum duration of the subtitles of formats without end times (e.g., LRC), used when loading
+	CSVColumns    []string            // Columns of CSV files (e.g., "start", "end", "speaker", "text"), detected from the header when loading and used when saving
+	Segmentation  Segmentation        // Splitting of the timed words of speech recognition transcripts (e.g., Whisper) into subtitles, used when loading
+	Verbose       bool                // Whether to log processing information
+}
+
+// FrameRounding is the rounding of times to whole frames (or other units of time)
+// when saving formats that can't represent every time.
+type FrameRounding int
+
+const (
+	RoundNearest FrameRounding = iota // Round to the nearest frame
+	RoundDown                         // Round down to the frame shown at the time
+	RoundUp                           // Round up to the next frame
+)
+
+// Segmentation is the splitting of timed words into subtitles, as done with the transcripts
+// of speech recognition engines. The zero Segmentation keeps the segments of the transcript.
+type Segmentation struct {
+	MaxChars    int           // Maximum characters of a subtitle (no limit if zero)
+	MaxDuration time.Duration // Maximum duration of a subtitle (no limit if zero)
+	Punctuation bool          // Whether the end of a sentence (".", "?", "!") ends the subtitle
 }
 
 // ModelItemSubtitle represents a single subtitle entry with timing and text.
 type ModelItemSubtitle struct {
-	Seq   int            // Sequence number of the subtitle
-	Start time.Duration  // Start time of the subtitle
-	End   time.Duration  // End time of the subtitle
-	Text  []string       // Lines of text in the subtitle
+	Seq      int              // Sequence number of the subtitle
+	Start    time.Duration    // Start time of the subtitle
+	End      time.Duration    // End time of the subtitle
+	Text     []string         // Lines of text in the subtitle
+	ID       string           // Cue identifier (WebVTT)
+	Settings ModelCueSettings // Cue positioning settings (WebVTT, STL)
+	Layer    string           // Layer (ASS) or Marked (SSA) column of the event
+	Style    string           // Name of the style of the event (SSA/ASS)
+	Actor    string           // Name of the character speaking (SSA/ASS)
+	MarginL  string           // Left margin override of the event (SSA/ASS)
+	MarginR  string           // Right margin override of the event (SSA/ASS)
+	MarginV  string           // Vertical margin override of the event (SSA/ASS)
+	Effect   string           // Transition effect of the event (SSA/ASS)
+	RawText  string           // Original text of the event with override tags (SSA/ASS)
+	Spans    [][]ModelSpan    // Styled runs of each line of Text (nil if the text has no styling)
+	Words    []ModelWord      // Timed words of the Text in order (e.g., LRC, SSA karaoke), nil if unknown
+}
+
+// ModelWord represents a word (or syllable) of a subtitle with its own timing,
+// as used by karaoke and lyrics formats and speech recognition transcripts.
+type ModelWord struct {
+	Text       string        // Text of the word, without the spaces around it
+	Start      time.Duration // Start time of the word
+	End        time.Duration // End time of the word
+	Confidence float64       // Confidence of the speech recognition of the word, between 0 and 1 (0 if unknown)
+}
+
+// ModelSpan represents a run of text with the same inline styling.
+// The text of the runs of a line joined together is the line of Text.
+type ModelSpan struct {
+	Text         string // Text of the run
+	Italic       bool   // Whether the text is italic
+	Bold         bool   // Whether the text is bold
+	Underline    bool   // Whether the text is underlined
+	StrikeOut    bool   // Whether the text is struck out
+	Color        string // Color of the text as "#RRGGBB" (empty for the default color)
+	Font         string // Font name of the text (empty for the default font)
+	DoubleHeight bool   // Whether the text is shown at double height (teletext)
+}
+
+// ModelCueSettings represents the positioning settings of a WebVTT cue.
+// Empty fields are not present in the cue.
+type ModelCueSettings struct {
+	Vertical string // Writing direction ("rl" or "lr")
+	Line     string // Line position (e.g., "0", "-1", "90%,end")
+	Position string // Text position (e.g., "50%", "10%,line-left")
+	Size     string // Cue box size (e.g., "80%")
+	Align    string // Text alignment ("start", "center", "end", "left", "right")
+	Region   string // Identifier of the region the cue belongs to
+}
+
+// ModelBlock represents a block of a subtitle file that is not a cue,
+// such as a WebVTT comment, style sheet or region definition.
+type ModelBlock struct {
+	Kind string   // Kind of block (e.g., "NOTE", "STYLE", "REGION")
+	Text []string // Lines of the block body
+}
+
+// ModelScript represents the script header of a SSA/ASS file.
+// The columns are kept in file order so the script can be written back unchanged.
+type ModelScript struct {
+	Info          []ModelInfo  // Key/value lines and comments of the [Script Info] section
+	StylesSection string       // Name of the styles section (e.g., "V4+ Styles")
+	StyleFormat   []string     // Columns of the styles Format: line
+	Styles        []ModelStyle // Styles of the script
+	EventFormat   []string     // Columns of the events Format: line
+	OtherEvents   []ModelEvent // Event lines that are not dialogues read as subtitles (e.g., Comment:), in file order
+	Sections      []ModelBlock // Other sections of the script (e.g., Fonts, Graphics)
+}
+
+// ModelEvent represents a line of the [Events] section of a SSA/ASS script that is not a
+// dialogue read as a subtitle, such as a Comment: line or a dialogue with an invalid timestamp.
+type ModelEvent struct {
+	Line  string // Original line
+	After int    // Number of dialogues before the line, so it is written back after the same subtitle
+}
+
+// ModelInfo represents a line of the [Script Info] section.
+// Comment lines have an empty Key and the full line as Value.
+type ModelInfo struct {
+	Key   string // Name of the property (e.g., "Title", "PlayResY")
+	Value string // Value of the property
+}
+
+// ModelStyle represents a style of a SSA/ASS script.
+type ModelStyle struct {
+	Name   string            // Name of the style
+	Fields map[string]string // Values of the style by column name of the Format: line
 }
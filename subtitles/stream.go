@@ -0,0 +1,278 @@
+package subtitles
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/transform"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/format"
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+)
+
+// streamPeekSize is the size of the start of streamed content used to detect its character
+// encoding and format.
+const streamPeekSize = 64 * 1024
+
+// streamMaxLine is the maximum length of a line of streamed content.
+const streamMaxLine = 1024 * 1024
+
+// Reader reads the cues of subtitle content one at a time from an io.Reader. The formats made
+// of blocks separated by empty lines (SRT, VTT) are read a block at a time, with bounded memory
+// regardless of the size of the content; the other formats are loaded whole by the first Read.
+type Reader struct {
+	// Subtitle holds the load options (e.g., InputEncoding, FrameRate), set before the first
+	// Read, and the Format, Encoding, BOM, Blocks and Warnings of the content read so far.
+	// Its Lines are not used.
+	Subtitle Subtitle
+
+	r        *bufio.Reader
+	hint     string
+	started  bool
+	err      error                      // Error returned once the pending cues are read
+	stream   format.StreamFormat        // Format of the content, nil if loaded whole
+	scanner  *bufio.Scanner             // Lines of the content decoded to UTF-8
+	line     int                        // Number of lines read
+	cues     int                        // Number of cues read
+	previous models.ModelItemSubtitle   // Last cue read
+	pending  []models.ModelItemSubtitle // Cues read but not returned yet
+}
+
+// NewReader returns a Reader of the subtitle content of r. The hint is a format name
+// (e.g., "SRT") or a filename whose extension names the format, like Load.
+func NewReader(r io.Reader, hint string) *Reader {
+	return &Reader{r: bufio.NewReaderSize(r, streamPeekSize), hint: hint}
+}
+
+// Read returns the next cue of the content. The format and the character encoding are
+// detected from the start of the content by the first Read, like Load.
+// Returns io.EOF after the last cue, and ErrUnsupportedFormat if no registered format
+// recognizes the content.
+func (r *Reader) Read() (item models.ModelItemSubtitle, err error) {
+	if !r.started {
+		r.started = true
+		r.err = r.start()
+	}
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return item, r.err
+		}
+		r.err = r.readBlock()
+	}
+	item = r.pending[0]
+	r.pending = r.pending[1:]
+	return item, nil
+}
+
+// start detects the format and the character encoding of the content, and loads the content
+// whole if the format can't be streamed.
+func (r *Reader) start() error {
+	peek, err := r.r.Peek(streamPeekSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return err
+	}
+	hinted := lookupHint(r.hint)
+
+	// Binary formats are loaded whole
+	if len(candidates(string(peek), r.hint, hinted, true)) > 0 {
+		return r.loadAll()
+	}
+
+	// Detect the character encoding from the complete lines of the start of the content
+	encoding := r.Subtitle.InputEncoding
+	if len(encoding) == 0 {
+		sample := peek
+		if k := bytes.LastIndexByte(peek, '\n'); len(peek) == streamPeekSize && k > 0 {
+			sample = peek[:k+1]
+		}
+		encoding = DetectEncoding(sample)
+	}
+	content, err := DecodeText(peek, encoding)
+	if err != nil {
+		return err
+	}
+	r.Subtitle.BOM = strings.HasPrefix(content, "\ufeff")
+	content = strings.Replace(strings.TrimPrefix(content, "\ufeff"), "\r\n", "\n", -1)
+
+	// Stream the content in the detected format, if it can be streamed
+	ranked := candidates(content, r.hint, hinted, false)
+	if len(ranked) == 0 {
+		return ErrUnsupportedFormat
+	}
+	stream, ok := ranked[0].Format.(format.StreamFormat)
+	if !ok {
+		return r.loadAll()
+	}
+	enc, err := lookupEncoding(encoding)
+	if err != nil {
+		return err
+	}
+	r.stream = stream
+	r.Subtitle.Format = stream.Name()
+	r.Subtitle.Encoding = encoding
+	r.scanner = bufio.NewScanner(transform.NewReader(r.r, enc.NewDecoder()))
+	r.scanner.Buffer(make([]byte, 4096), streamMaxLine)
+	r.Subtitle.logDetected(ranked[0])
+	return nil
+}
+
+// loadAll loads the whole content like Load, with the cues pending.
+// Returns io.EOF once the content is loaded.
+func (r *Reader) loadAll() error {
+	raw, err := io.ReadAll(r.r)
+	if err != nil {
+		return err
+	}
+	sub := r.Subtitle
+	if err = sub.load(raw, r.hint, r.hint); err != nil {
+		return err
+	}
+	r.pending, sub.Lines = sub.Lines, nil
+	r.Subtitle = sub
+	return io.EOF
+}
+
+// readBlock reads the next block of lines separated by empty lines, and parses it into the
+// pending cues. Returns io.EOF at the end of the content.
+func (r *Reader) readBlock() error {
+	var lines []string
+	first := 0
+	for r.scanner.Scan() {
+		r.line++
+		line := strings.TrimSuffix(r.scanner.Text(), "\r")
+		if r.line == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if strings.TrimSpace(line) == "" {
+			if len(lines) == 0 {
+				continue
+			}
+			break
+		}
+		if len(lines) == 0 {
+			first = r.line
+		}
+		lines = append(lines, line)
+	}
+	if err := r.scanner.Err(); err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return io.EOF
+	}
+
+	// Parse the block after the previous cue
+	pos := format.StreamPosition{Line: first, Cue: r.cues + 1}
+	if r.cues > 0 {
+		pos.Previous = &r.previous
+	}
+	items, warnings := r.stream.ReadBlock(strings.Join(lines, "\n"), pos, (*models.Subtitle)(&r.Subtitle))
+	r.Subtitle.Warnings = append(r.Subtitle.Warnings, warnings...)
+	if len(items) > 0 {
+		r.cues += len(items)
+		r.previous = items[len(items)-1]
+	}
+	r.pending = items
+	return nil
+}
+
+// Writer writes subtitle cues one at a time to an io.Writer. The formats made of blocks
+// separated by empty lines (SRT, VTT) are written a cue at a time, with bounded memory;
+// the cues of the other formats are kept and written whole by Close.
+type Writer struct {
+	// Subtitle holds the Format, Encoding and BOM of the content, the save options
+	// (e.g., FrameRate) and the header data (e.g., Blocks), set before the first Write.
+	// Its Lines hold the cues of the formats that can't be streamed.
+	Subtitle Subtitle
+
+	w       io.Writer
+	started bool
+	err     error               // Error of the format lookup or the header
+	stream  format.StreamFormat // Format of the content, nil if written whole
+	written bool                // Whether content was written
+	cues    int                 // Number of cues written
+}
+
+// NewWriter returns a Writer of subtitle content in the format with the given name
+// (e.g., "SRT") to w.
+func NewWriter(w io.Writer, formatName string) *Writer {
+	return &Writer{Subtitle: Subtitle{Format: formatName}, w: w}
+}
+
+// Write writes a cue of the subtitle, after the header of the content for the first one.
+// The cues without sequence number are numbered in order.
+// Returns ErrUnsupportedFormat if the format is not registered.
+func (w *Writer) Write(item models.ModelItemSubtitle) (err error) {
+	if err = w.start(); err != nil {
+		return err
+	}
+	w.cues++
+	if item.Seq == 0 {
+		item.Seq = w.cues
+	}
+	if w.stream == nil {
+		w.Subtitle.Lines = append(w.Subtitle.Lines, item)
+		return nil
+	}
+	return w.write(w.stream.WriteCue(&item, (*models.Subtitle)(&w.Subtitle)))
+}
+
+// Close writes the end of the content: the whole content of the formats that can't be
+// streamed, or the header of content without cues. The underlying writer is not closed.
+func (w *Writer) Close() (err error) {
+	if err = w.start(); err != nil || w.stream != nil {
+		return err
+	}
+	content, err := w.Subtitle.render()
+	if err != nil {
+		return err
+	}
+	_, err = w.w.Write(content)
+	return err
+}
+
+// start looks up the format and writes the header of the content, once.
+func (w *Writer) start() error {
+	if !w.started {
+		w.started = true
+		w.err = w.writeHeader()
+	}
+	return w.err
+}
+
+// writeHeader looks up the format and writes the header of the content if the format can
+// be streamed.
+func (w *Writer) writeHeader() error {
+	if len(w.Subtitle.Format) == 0 {
+		return fmt.Errorf("%w: format not specified", ErrUnsupportedFormat)
+	}
+	f := format.Lookup(w.Subtitle.Format)
+	if f == nil {
+		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, w.Subtitle.Format)
+	}
+	stream, ok := f.(format.StreamFormat)
+	if !ok || format.IsBinary(f) {
+		return nil
+	}
+	w.stream = stream
+	return w.write(stream.WriteHeader((*models.Subtitle)(&w.Subtitle)))
+}
+
+// write writes content in the character encoding of the subtitle, starting with a byte
+// order mark if BOM is set.
+func (w *Writer) write(content string) error {
+	bom := w.Subtitle.BOM && !w.written
+	if len(content) == 0 && !bom {
+		return nil
+	}
+	raw, err := EncodeText(content, w.Subtitle.Encoding, bom)
+	if err != nil {
+		return err
+	}
+	w.written = true
+	_, err = w.w.Write(raw)
+	return err
+}
@@ -2,10 +2,12 @@
 package subtitles
 
 import (
-	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -17,9 +19,21 @@ import (
 // It embeds the models.Subtitle type to provide the necessary data structure.
 type Subtitle models.Subtitle
 
-// LoadFile loads a subtitle file from the specified path and detects its format.
-// Currently supported formats: SRT, SSA.
-// If Verbose is set to true, it will print processing time information.
+// LoadFile loads a subtitle file from the specified path and detects its format by its
+// content and extension (see DetectFormat), using the formats registered in the format package.
+// The content decides over the extension: e.g., a .ssa file with an ASS script loads as ASS.
+// Built-in formats: SRT, SSA, ASS, VTT, MicroDVD, MPL2, TTML, SAMI, STL, SCC,
+// SubViewer, SBV, TMPlayer, LRC, JSON, CSV, Whisper.
+// Multi-language formats (SAMI) load the language named by Language, or the first one.
+// Frame-based formats (MicroDVD, STL) use the frame rate of the file, or FrameRate if the
+// file has none, and store the frame rate used in FrameRate.
+// Formats without end times (LRC) end each subtitle at the next one, after MaxDuration at most.
+// CSV files use the columns named by the header row, or CSVColumns without it.
+// Speech recognition transcripts (Whisper) split their words into subtitles by Segmentation.
+// The character encoding is detected (see DetectEncoding) unless InputEncoding is set,
+// and the content is converted to UTF-8. The encoding is stored in Encoding and BOM.
+// If Verbose is set to true, it will log the detected format and processing time information
+// with the standard logger.
 func (sub *Subtitle) LoadFile(filename string) (err error) {
 	sub.Filename = filename
 
@@ -28,77 +42,294 @@ func (sub *Subtitle) LoadFile(filename string) (err error) {
 	// Read the file content
 	raw, err := os.ReadFile(filename)
 	if err != nil {
-		log.Fatal(err)
+		return wrapFileError(err)
+	}
+	err = sub.load(raw, "", filename)
+
+	// Log processing time if verbose mode is enabled
+	if sub.Verbose {
+		log.Println("Processed in", time.Since(start).String())
+	}
+
+	return err
+}
+
+// LoadFS loads a subtitle file from the specified path of a file system (e.g., an embed.FS
+// or a zip archive) and detects its format by its content and extension like LoadFile.
+// If Verbose is set to true, it will log processing time information with the standard logger.
+func (sub *Subtitle) LoadFS(fsys fs.FS, filename string) (err error) {
+	sub.Filename = filename
+
+	start := time.Now()
+
+	// Read the file content
+	raw, err := fs.ReadFile(fsys, filename)
+	if err != nil {
+		return wrapFileError(err)
+	}
+	err = sub.load(raw, "", filename)
+
+	// Log processing time if verbose mode is enabled
+	if sub.Verbose {
+		log.Println("Processed in", time.Since(start).String())
+	}
+
+	return err
+}
+
+// Load loads subtitle content from a reader (e.g., an HTTP upload) and detects its format.
+// The hint is a format name (e.g., "SRT") or a filename whose extension names the format;
+// the hinted format is tried first when the content looks like it, then the detected formats.
+// An empty hint uses the detection only.
+// If Verbose is set to true, it will log processing time information with the standard logger.
+func (sub *Subtitle) Load(r io.Reader, hint string) (err error) {
+	start := time.Now()
+
+	// Read the content
+	raw, err := io.ReadAll(r)
+	if err != nil {
 		return err
 	}
-	content := string(raw)
+	err = sub.load(raw, hint, hint)
+
+	// Log processing time if verbose mode is enabled
+	if sub.Verbose {
+		log.Println("Processed in", time.Since(start).String())
+	}
+
+	return err
+}
+
+// load parses raw subtitle content with the hinted format or the registered format
+// with the highest detection confidence (see DetectFormat), with the extension of the
+// filename in the confidence. Binary formats (EBU STL) read the raw content, the other
+// formats the content decoded to UTF-8.
+func (sub *Subtitle) load(raw []byte, hint string, filename string) (err error) {
+	hinted := lookupHint(hint)
+
+	// Try the binary formats first, as their content has no character encoding
+	for _, c := range candidates(string(raw), filename, hinted, true) {
+		if sub.parse(c.Format, string(raw), "", false) {
+			sub.logDetected(c)
+			return nil
+		}
+	}
+
+	content, encoding, bom, err := sub.decode(raw)
+	if err != nil {
+		return err
+	}
+
+	// Try the formats from the highest to the lowest detection confidence
+	for _, c := range candidates(content, filename, hinted, false) {
+		if sub.parse(c.Format, content, encoding, bom) {
+			sub.logDetected(c)
+			return nil
+		}
+	}
+	return ErrUnsupportedFormat
+}
+
+// candidates returns the registered binary or text formats that detect the content, from the
+// highest to the lowest confidence, with the hinted format first when the content looks like it.
+func candidates(content string, filename string, hinted format.Format, binary bool) []FormatCandidate {
+	ret := rankFormats(content, filename, binary)
+	if hinted == nil {
+		return ret
+	}
+	for i := range ret {
+		if ret[i].Format.Name() == hinted.Name() {
+			hint := ret[i]
+			return append([]FormatCandidate{hint}, append(ret[:i:i], ret[i+1:]...)...)
+		}
+	}
+	return ret
+}
+
+// logDetected logs the detected format of the loaded content if Verbose is set to true.
+func (sub *Subtitle) logDetected(c FormatCandidate) {
+	if sub.Verbose {
+		log.Println("Detected format", c)
+	}
+}
+
+// parse reads the content with the format into the subtitle, keeping the load options.
+// Reports whether the content was valid for the format; the subtitle is unchanged otherwise.
+func (sub *Subtitle) parse(f format.Format, content string, encoding string, bom bool) bool {
+	parsed := models.Subtitle{
+		Filename:      sub.Filename,
+		Verbose:       sub.Verbose,
+		InputEncoding: sub.InputEncoding,
+		Language:      sub.Language,
+		FrameRate:     sub.FrameRate,
+		FrameRounding: sub.FrameRounding,
+		MaxDuration:   sub.MaxDuration,
+		CSVColumns:    sub.CSVColumns,
+		Segmentation:  sub.Segmentation,
+	}
+	if f.Read(content, &parsed) != nil {
+		return false
+	}
+	parsed.Format = f.Name()
+	parsed.Encoding = encoding
+	parsed.BOM = bom
+	*sub = Subtitle(parsed)
+	return true
+}
+
+// decode detects the character encoding of raw subtitle content, unless InputEncoding is set,
+// and converts the content to UTF-8 without byte order mark and with standard line breaks.
+// Returns ErrEmpty if the content has no text.
+func (sub *Subtitle) decode(raw []byte) (content string, encoding string, bom bool, err error) {
+	// Detect the character encoding, unless it is given, and convert the content to UTF-8
+	encoding = sub.InputEncoding
+	if len(encoding) == 0 {
+		encoding = DetectEncoding(raw)
+	}
+	content, err = DecodeText(raw, encoding)
+	if err != nil {
+		return "", encoding, false, err
+	}
+	bom = strings.HasPrefix(content, "\ufeff")
+	content = strings.TrimPrefix(content, "\ufeff")
+	if len(strings.TrimSpace(content)) == 0 {
+		return "", encoding, bom, ErrEmpty
+	}
 
 	// Standardize line breaks and ensure proper ending
 	content = strings.Replace(content, "\r\n", "\n", -1) // standardize line break
 	content += "\n\n"                                    // lastest line break
-	
-	// Try to parse as SRT format
-	ret, errSRT := format.ReadSRT(content)
-	if errSRT == nil {
-		sub.Format = "SRT"
-		sub.Lines = ret
-	}
-	
-	// If not SRT, try to parse as SSA format
-	if len(sub.Format) == 0 {
-		retSSA, errSSA := format.ReadSSA(content)
-		if errSSA == nil {
-			sub.Format = "SSA"
-			sub.Lines = retSSA
-			err = nil // Clear the error if SSA parsing succeeds
-		} else {
-			err = errors.New("unsupported subtitle format")
+	return content, encoding, bom, nil
+}
+
+// LoadLanguages loads every language of multi-language subtitle content (SAMI) from a
+// reader into separate subtitles, with the Language of each one set. Declared languages
+// without paragraphs are skipped. Content of other
+// formats is loaded into a single subtitle like Load.
+// The hint and the character encoding detection work like Load.
+func LoadLanguages(r io.Reader, hint string) (subs []Subtitle, err error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// List the languages of SAMI content
+	probe := Subtitle{}
+	content, _, _, err := probe.decode(raw)
+	if err != nil {
+		return nil, err
+	}
+	var languages []format.SAMILanguage
+	if format.Lookup("SAMI").Probe(content) > 0 {
+		languages, _ = format.ReadSAMILanguages(content)
+	}
+	if len(languages) == 0 {
+		sub := Subtitle{}
+		if err := sub.load(raw, hint, hint); err != nil {
+			return nil, err
 		}
-	} else {
-		err = errSRT // Use SRT error if that was the intended format
+		return []Subtitle{sub}, nil
 	}
 
-	// Print processing time if verbose mode is enabled
-	if sub.Verbose {
-		fmt.Println("Processed in ", time.Since(start).String())
+	// Load each language by its class, skipping the declared languages without paragraphs
+	for _, language := range languages {
+		sub := Subtitle{Language: language.Class}
+		if errLanguage := sub.load(raw, "SAMI", ""); errLanguage != nil {
+			err = errLanguage
+			continue
+		}
+		subs = append(subs, sub)
+	}
+	if len(subs) == 0 {
+		return nil, err
 	}
+	return subs, nil
+}
 
-	return err
+// lookupHint returns the registered format named by the hint, or the format of the
+// extension of the hint when it is a filename, or nil if there is none.
+func lookupHint(hint string) format.Format {
+	if len(hint) == 0 {
+		return nil
+	}
+	if f := format.Lookup(hint); f != nil {
+		return f
+	}
+	return format.LookupExtension(filepath.Ext(hint))
 }
 
 // SaveFile saves the subtitle data to a file in the specified format.
-// The format is determined by the Format field of the Subtitle struct.
-// Currently supported formats: SRT, SSA.
-// If Verbose is set to true, it will print processing time information.
+// The format is determined by the Format field of the Subtitle struct and must be
+// registered in the format package. Built-in formats: SRT, SSA, ASS, VTT, MicroDVD, MPL2, TTML, SAMI, STL, SCC,
+// SubViewer, SBV, TMPlayer, LRC, JSON, CSV, Whisper.
+// CSV files are written with the CSVColumns ("start,end,speaker,text" if empty).
+// Frame-based formats (MicroDVD, STL) convert the times to frames with FrameRate and FrameRounding.
+// The file is written in the character encoding named by Encoding (UTF-8 if empty),
+// starting with a byte order mark if BOM is set, except for binary formats (STL).
+// If Verbose is set to true, it will log processing time information with the standard logger.
 func (sub *Subtitle) SaveFile(filename string) (err error) {
 	start := time.Now()
 
-	// Check if format is specified
-	if len(sub.Format) == 0 {
-		return errors.New("Format not specified")
+	// Generate content based on the format
+	content, err := sub.render()
+	if err != nil {
+		return err
 	}
 
-	content := ""
-
-	// Generate content based on the format
-	if sub.Format == "SRT" {
-		content = format.WriteSRT(&models.Subtitle{Lines: sub.Lines})
+	// Write content to file
+	err = os.WriteFile(filename, content, 0644)
+	if err != nil {
+		return wrapFileError(err)
 	}
-	if sub.Format == "SSA" {
-		content = format.WriteSSA(&models.Subtitle{Lines: sub.Lines})
+
+	// Log processing time if verbose mode is enabled
+	if sub.Verbose {
+		log.Println("Processed in", time.Since(start).String())
 	}
 
-	// Write content to file
-	err = os.WriteFile(filename, []byte(content), 0644)
+	return err
+}
+
+// Save writes the subtitle data to a writer (e.g., an HTTP response) in the format
+// determined by the Format field of the Subtitle struct, like SaveFile.
+// If Verbose is set to true, it will log processing time information with the standard logger.
+func (sub *Subtitle) Save(w io.Writer) (err error) {
+	start := time.Now()
+
+	// Generate content based on the format
+	content, err := sub.render()
 	if err != nil {
-		log.Fatal(err)
 		return err
 	}
 
-	// Print processing time if verbose mode is enabled
+	// Write content to the writer
+	_, err = w.Write(content)
+
+	// Log processing time if verbose mode is enabled
 	if sub.Verbose {
-		fmt.Println("Processed in ", time.Since(start).String())
+		log.Println("Processed in", time.Since(start).String())
 	}
 
 	return err
 }
+
+// render converts the subtitle data to content with the registered format named by Format,
+// in the character encoding named by Encoding and with a byte order mark if BOM is set.
+func (sub *Subtitle) render() (content []byte, err error) {
+	// Check if format is specified
+	if len(sub.Format) == 0 {
+		return nil, fmt.Errorf("%w: format not specified", ErrUnsupportedFormat)
+	}
+
+	// Generate content with the registered format
+	f := format.Lookup(sub.Format)
+	if f == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, sub.Format)
+	}
+
+	// Binary formats have no character encoding
+	if format.IsBinary(f) {
+		return []byte(f.Write((*models.Subtitle)(sub))), nil
+	}
+	return EncodeText(f.Write((*models.Subtitle)(sub)), sub.Encoding, sub.BOM)
+}
@@ -0,0 +1,709 @@
+package subtitles
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/format"
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+)
+
+// testSRT is a SRT subtitle used by the tests
+const testSRT = `1
+00:01:30,000 --> 00:01:35,000
+Test line 1
+Test line 2
+
+2
+00:02:30,500 --> 00:02:35,753
+Another test line
+
+`
+
+// TestLoadSave tests loading from a reader and a file system and saving to a writer
+func TestLoadSave(t *testing.T) {
+	// Load from a reader, with Windows line breaks
+	sub := Subtitle{}
+	err := sub.Load(strings.NewReader(strings.Replace(testSRT, "\n", "\r\n", -1)), "upload.srt")
+	if err != nil {
+		t.Fatalf("Failed to load SRT content: %v", err)
+	}
+	if sub.Format != "SRT" {
+		t.Errorf("Expected format SRT, got %s", sub.Format)
+	}
+	if len(sub.Lines) != 2 {
+		t.Fatalf("Expected 2 subtitle entries, got %d", len(sub.Lines))
+	}
+	expectedEnd := 2*time.Minute + 35*time.Second + 753*time.Millisecond
+	if sub.Lines[1].End != expectedEnd {
+		t.Errorf("Expected end time %v, got %v", expectedEnd, sub.Lines[1].End)
+	}
+
+	// Save to a writer in another format
+	sub.Format = "VTT"
+	var buf bytes.Buffer
+	if err := sub.Save(&buf); err != nil {
+		t.Fatalf("Failed to save VTT content: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "WEBVTT\n\n00:01:30.000 --> 00:01:35.000\nTest line 1\n") {
+		t.Errorf("Unexpected VTT content:\n%s", buf.String())
+	}
+
+	// Load from a file system
+	fsys := fstest.MapFS{"subs/episode.vtt": &fstest.MapFile{Data: buf.Bytes()}}
+	sub2 := Subtitle{}
+	if err := sub2.LoadFS(fsys, "subs/episode.vtt"); err != nil {
+		t.Fatalf("Failed to load VTT file: %v", err)
+	}
+	if sub2.Filename != "subs/episode.vtt" || sub2.Format != "VTT" || len(sub2.Lines) != 2 {
+		t.Errorf("Unexpected subtitle loaded from file system: %v", sub2)
+	}
+	if err := sub2.LoadFS(fsys, "missing.srt"); err == nil {
+		t.Errorf("Expected error for missing file")
+	}
+
+	// Unsupported content and formats are reported
+	if err := (&Subtitle{}).Load(strings.NewReader("not a subtitle"), ""); err == nil {
+		t.Errorf("Expected error for unsupported content")
+	}
+	if err := (&Subtitle{Format: "XYZ"}).Save(&buf); err == nil {
+		t.Errorf("Expected error for unsupported format")
+	}
+}
+
+// TestErrors tests the errors returned when loading and saving
+func TestErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	// Missing files match ErrNotFound and the wrapped os error
+	err := (&Subtitle{}).LoadFile(filepath.Join(dir, "missing.srt"))
+	if !errors.Is(err, ErrNotFound) || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected ErrNotFound and fs.ErrNotExist, got %v", err)
+	}
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) {
+		t.Errorf("Expected *fs.PathError, got %T", err)
+	}
+
+	// Empty content matches ErrEmpty
+	if err := (&Subtitle{}).Load(strings.NewReader(" \r\n\n"), ""); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Expected ErrEmpty, got %v", err)
+	}
+
+	// Unknown content and formats match ErrUnsupportedFormat
+	if err := (&Subtitle{}).Load(strings.NewReader("not a subtitle"), ""); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat, got %v", err)
+	}
+	if err := (&Subtitle{}).SaveFile(filepath.Join(dir, "out.srt")); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat, got %v", err)
+	}
+	if err := (&Subtitle{Format: "XYZ"}).SaveFile(filepath.Join(dir, "out.srt")); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat, got %v", err)
+	}
+
+	// Write errors are returned instead of exiting
+	err = (&Subtitle{Format: "SRT"}).SaveFile(filepath.Join(dir, "missing", "out.srt"))
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+// TestEncodings tests the character encoding detection and conversion
+func TestEncodings(t *testing.T) {
+	// Encode sample texts in each encoding and detect them back
+	tests := []struct {
+		encoding string
+		bom      bool
+		text     string
+	}{
+		{"UTF-8", true, "1\n00:00:01,000 --> 00:00:02,000\nHola, ¿cómo estás?\n"},
+		{"UTF-8", false, "1\n00:00:01,000 --> 00:00:02,000\nHola, ¿cómo estás?\n"},
+		{"UTF-16LE", true, "1\n00:00:01,000 --> 00:00:02,000\nHola, ¿cómo estás?\n"},
+		{"UTF-16BE", false, "1\n00:00:01,000 --> 00:00:02,000\nHola, ¿cómo estás?\n"},
+		{"windows-1252", false, "1\n00:00:01,000 --> 00:00:02,000\nC’est l’été – déjà!\n"},
+		{"ISO-8859-1", false, "1\n00:00:01,000 --> 00:00:02,000\nÇa va très bien.\n"},
+		{"Shift_JIS", false, "1\n00:00:01,000 --> 00:00:02,000\n私の名前は中野です。これは日本語の字幕です。\n"},
+		{"GB18030", false, "1\n00:00:01,000 --> 00:00:02,000\n我们在这里说的是中国的字幕，你知道吗？\n"},
+		{"Big5", false, "1\n00:00:01,000 --> 00:00:02,000\n我們在這裡說的是中國的字幕，你知道嗎？\n"},
+		{"EUC-KR", false, "1\n00:00:01,000 --> 00:00:02,000\n우리는 지금 여기서 무엇을 하고 있어요?\n"},
+	}
+	for _, test := range tests {
+		raw, err := EncodeText(test.text, test.encoding, test.bom)
+		if err != nil {
+			t.Fatalf("Failed to encode %s: %v", test.encoding, err)
+		}
+		if detected := DetectEncoding(raw); detected != test.encoding {
+			t.Errorf("DetectEncoding(%s) = %s", test.encoding, detected)
+		}
+
+		// Load the content and check the text, encoding and byte order mark
+		sub := Subtitle{}
+		if err := sub.Load(bytes.NewReader(raw), ""); err != nil {
+			t.Fatalf("Failed to load %s content: %v", test.encoding, err)
+		}
+		if sub.Encoding != test.encoding || sub.BOM != test.bom {
+			t.Errorf("Expected encoding %s (BOM %v), got %s (BOM %v)", test.encoding, test.bom, sub.Encoding, sub.BOM)
+		}
+		if expected := strings.Split(test.text, "\n")[2]; sub.Lines[0].Text[0] != expected {
+			t.Errorf("Expected text %q, got %q", expected, sub.Lines[0].Text[0])
+		}
+
+		// Saving writes the content back in the same encoding
+		var buf bytes.Buffer
+		if err := sub.Save(&buf); err != nil {
+			t.Fatalf("Failed to save %s content: %v", test.encoding, err)
+		}
+		written := strings.NewReplacer("00:00:0", "0:00:0", ",000", ".000").Replace(test.text) + "\n"
+		if expected, _ := EncodeText(written, test.encoding, test.bom); !bytes.Equal(buf.Bytes(), expected) {
+			t.Errorf("Unexpected %s content saved: %q", test.encoding, buf.String())
+		}
+	}
+
+	// The encoding can be given instead of detected
+	raw, _ := EncodeText("1\n00:00:01,000 --> 00:00:02,000\nΚαλημέρα\n", "ISO-8859-7", false)
+	sub := Subtitle{InputEncoding: "ISO-8859-7"}
+	if err := sub.Load(bytes.NewReader(raw), ""); err != nil || sub.Lines[0].Text[0] != "Καλημέρα" {
+		t.Errorf("Failed to load ISO-8859-7 content: %v %v", err, sub.Lines)
+	}
+
+	// Unknown encodings and characters that cannot be encoded are reported
+	if err := (&Subtitle{InputEncoding: "no-such-encoding"}).Load(bytes.NewReader(raw), ""); !errors.Is(err, ErrUnsupportedEncoding) {
+		t.Errorf("Expected ErrUnsupportedEncoding, got %v", err)
+	}
+	sub.Encoding = "windows-1252"
+	if err := sub.Save(&bytes.Buffer{}); err == nil {
+		t.Errorf("Expected error when saving Greek text in windows-1252")
+	}
+}
+
+// TestFrameRate tests loading and saving frame-based subtitles with a frame rate
+func TestFrameRate(t *testing.T) {
+	// Load MicroDVD content without header at the given frame rate
+	sub := Subtitle{FrameRate: 25}
+	if err := sub.Load(strings.NewReader("{25}{50}Hello|World\n"), "movie.sub"); err != nil {
+		t.Fatalf("Failed to load MicroDVD content: %v", err)
+	}
+	if sub.Format != "MicroDVD" || sub.FrameRate != 25 {
+		t.Errorf("Expected format MicroDVD at 25 fps, got %s at %v fps", sub.Format, sub.FrameRate)
+	}
+	if sub.Lines[0].Start != time.Second || sub.Lines[0].End != 2*time.Second {
+		t.Errorf("Expected 1s --> 2s, got %v --> %v", sub.Lines[0].Start, sub.Lines[0].End)
+	}
+
+	// Save at another frame rate
+	sub.FrameRate = 50
+	var buf bytes.Buffer
+	if err := sub.Save(&buf); err != nil {
+		t.Fatalf("Failed to save MicroDVD content: %v", err)
+	}
+	if buf.String() != "{1}{1}50\n{50}{100}Hello|World\n" {
+		t.Errorf("Unexpected MicroDVD content:\n%s", buf.String())
+	}
+}
+
+// TestBinaryFormat tests saving and loading EBU STL binary content without character encoding
+func TestBinaryFormat(t *testing.T) {
+	sub := Subtitle{}
+	if err := sub.Load(strings.NewReader("1\n00:00:01,000 --> 00:00:02,000\nSeñor\n"), ""); err != nil {
+		t.Fatalf("Failed to load SRT content: %v", err)
+	}
+
+	// The encoding and byte order mark are ignored by binary formats
+	sub.Format, sub.Encoding, sub.BOM = "STL", "UTF-16LE", true
+	var buf bytes.Buffer
+	if err := sub.Save(&buf); err != nil {
+		t.Fatalf("Failed to save STL content: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "850STL25.01") {
+		t.Errorf("Expected binary STL content, got %q", buf.String()[:16])
+	}
+
+	// The format is detected from the raw content
+	loaded := Subtitle{}
+	if err := loaded.Load(&buf, ""); err != nil {
+		t.Fatalf("Failed to load STL content: %v", err)
+	}
+	if loaded.Format != "STL" || loaded.Encoding != "" || loaded.FrameRate != 25 {
+		t.Errorf("Expected format STL at 25 fps without encoding, got %s %q %v", loaded.Format, loaded.Encoding, loaded.FrameRate)
+	}
+	if loaded.Lines[0].Text[0] != "Señor" {
+		t.Errorf("Expected text Señor, got %v", loaded.Lines[0].Text)
+	}
+}
+
+// TestLoadLanguages tests loading every language of a SAMI file
+func TestLoadLanguages(t *testing.T) {
+	samiContent := `<SAMI>
+<HEAD>
+<STYLE TYPE="text/css">
+<!--
+.KRCC { Name: Korean; lang: ko-KR; SAMIType: CC; }
+.ENCC { Name: English; lang: en-US; SAMIType: CC; }
+-->
+</STYLE>
+</HEAD>
+<BODY>
+<SYNC Start=1000><P Class=KRCC>안녕하세요
+<SYNC Start=1000><P Class=ENCC>Hello
+<SYNC Start=3000><P Class=KRCC>&nbsp;
+<SYNC Start=3000><P Class=ENCC>&nbsp;
+</BODY>
+</SAMI>
+`
+	raw, _ := EncodeText(samiContent, "EUC-KR", false)
+	subs, err := LoadLanguages(bytes.NewReader(raw), "movie.smi")
+	if err != nil {
+		t.Fatalf("Failed to load SAMI languages: %v", err)
+	}
+	if len(subs) != 2 {
+		t.Fatalf("Expected 2 languages, got %d", len(subs))
+	}
+	for i, expected := range []struct {
+		language string
+		text     string
+	}{{"ko-KR", "안녕하세요"}, {"en-US", "Hello"}} {
+		if subs[i].Format != "SAMI" || subs[i].Encoding != "EUC-KR" || subs[i].Language != expected.language {
+			t.Errorf("Expected SAMI %s in EUC-KR, got %s %s in %s", expected.language, subs[i].Format, subs[i].Language, subs[i].Encoding)
+		}
+		if len(subs[i].Lines) != 1 || subs[i].Lines[0].Text[0] != expected.text || subs[i].Lines[0].End != 3*time.Second {
+			t.Errorf("Expected %q until 3s, got %v", expected.text, subs[i].Lines)
+		}
+	}
+
+	// One language is loaded by its class or language tag
+	sub := Subtitle{Language: "en-us"}
+	if err := sub.Load(bytes.NewReader(raw), ""); err != nil || sub.Lines[0].Text[0] != "Hello" {
+		t.Errorf("Expected English text, got %v (%v)", sub.Lines, err)
+	}
+
+	// Other formats are loaded as one subtitle
+	subs, err = LoadLanguages(strings.NewReader(testSRT), "")
+	if err != nil || len(subs) != 1 || subs[0].Format != "SRT" {
+		t.Errorf("Expected one SRT subtitle, got %v (%v)", subs, err)
+	}
+}
+
+// TestDetectFormat tests the ranking of the detected formats by content and extension
+func TestDetectFormat(t *testing.T) {
+	// WebVTT content with SRT-like cues named as SRT is still WebVTT first
+	vttContent := []byte("WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.000\nText\n")
+	candidates := DetectFormat(vttContent, "movie.srt")
+	if len(candidates) != 2 || candidates[0].Format.Name() != "VTT" || candidates[1].Format.Name() != "SRT" {
+		t.Fatalf("Expected VTT then SRT, got %v", candidates)
+	}
+	if candidates[0].Extension || !candidates[1].Extension || candidates[1].Probe != 0.6 {
+		t.Errorf("Unexpected detection details %v", candidates)
+	}
+	if got := candidates[1].String(); got != "SRT 0.64 (content 0.60, extension)" {
+		t.Errorf("Unexpected candidate description %q", got)
+	}
+
+	// The extension breaks the ties, without selecting formats not recognizing the content
+	jsonContent := []byte(`{"lines": [{"start": 1000, "end": 2000, "text": ["Text"]}], "segments": []}`)
+	if candidates := DetectFormat(jsonContent, "transcript.json"); len(candidates) != 2 || candidates[0].Confidence != candidates[1].Confidence {
+		t.Errorf("Expected JSON and Whisper with the same confidence, got %v", candidates)
+	}
+	if candidates := DetectFormat([]byte("1\n00:00:01,000 --> 00:00:02,000\nText\n"), "movie.vtt"); len(candidates) != 1 || candidates[0].Format.Name() != "SRT" {
+		t.Errorf("Expected SRT only, got %v", candidates)
+	}
+	if candidates := DetectFormat([]byte("Unknown"), "movie.srt"); candidates != nil {
+		t.Errorf("Expected no format, got %v", candidates)
+	}
+
+	// LoadFile uses the detection
+	filename := filepath.Join(t.TempDir(), "movie.srt")
+	if err := os.WriteFile(filename, vttContent, 0644); err != nil {
+		t.Fatal(err)
+	}
+	sub := Subtitle{}
+	if err := sub.LoadFile(filename); err != nil || sub.Format != "VTT" {
+		t.Errorf("Expected format VTT, got %s (%v)", sub.Format, err)
+	}
+
+	// A .ssa file with an ASS script loads as ASS, and is written as SSA once its format is set
+	filename = filepath.Join(t.TempDir(), "movie.ssa")
+	assContent := "[Script Info]\nScriptType: v4.00+\n\n[V4+ Styles]\n" +
+		"Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding\n" +
+		"Style: Default,Arial,28,&H00FFFFFF,&H000000FF,&H00000000,&H80000000,0,0,0,0,100,100,0,0,1,2,2,2,10,10,10,1\n\n[Events]\n" +
+		"Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\nDialogue: 0,0:00:01.00,0:00:02.00,Default,,0,0,0,,Text\n"
+	if err := os.WriteFile(filename, []byte(assContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sub = Subtitle{}
+	if err := sub.LoadFile(filename); err != nil || sub.Format != "ASS" {
+		t.Errorf("Expected format ASS, got %s (%v)", sub.Format, err)
+	}
+	sub.Format = "SSA"
+	var buf bytes.Buffer
+	if err := sub.Save(&buf); err != nil || !strings.Contains(buf.String(), "ScriptType: v4.00\n") || !strings.Contains(buf.String(), "[V4 Styles]") {
+		t.Errorf("Expected a SSA script, got %q (%v)", buf.String(), err)
+	}
+}
+
+// TestStream tests reading and writing cues one at a time
+func TestStream(t *testing.T) {
+	// Generate a long SRT content in ISO-8859-1 with Windows line breaks
+	var content strings.Builder
+	for i := 1; i <= 1000; i++ {
+		start := time.Duration(i) * time.Second
+		fmt.Fprintf(&content, "%d\r\n0:%02d:%02d,000 --> 0:%02d:%02d,500\r\nCue número %d\r\n\r\n", i, start/time.Minute, start/time.Second%60, start/time.Minute, start/time.Second%60, i)
+	}
+	raw, err := EncodeText(content.String(), "ISO-8859-1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Convert the cues to WebVTT one at a time
+	r := NewReader(bytes.NewReader(raw), "")
+	var buf bytes.Buffer
+	w := NewWriter(&buf, "VTT")
+	count := 0
+	for {
+		item, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read cue %d: %v", count+1, err)
+		}
+		count++
+		if err := w.Write(item); err != nil {
+			t.Fatalf("Failed to write cue %d: %v", count, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1000 || r.Subtitle.Format != "SRT" || r.Subtitle.Encoding != "ISO-8859-1" {
+		t.Errorf("Expected 1000 SRT cues in ISO-8859-1, got %d %s %s", count, r.Subtitle.Format, r.Subtitle.Encoding)
+	}
+
+	// The streamed content is the same as the content converted whole
+	sub := Subtitle{}
+	if err := sub.Load(bytes.NewReader(raw), ""); err != nil {
+		t.Fatal(err)
+	}
+	sub.Format, sub.Encoding = "VTT", ""
+	var expected bytes.Buffer
+	if err := sub.Save(&expected); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expected.String() {
+		t.Errorf("Streamed content differs from the converted content:\n%.200s", buf.String())
+	}
+
+	// The warnings have the position of the problem in the content
+	r = NewReader(strings.NewReader("1\n00:00:05,000 --> 00:00:06,000\nFirst\n\n2\n00:00:01,000 --> 00:00:02,000\nSecond\n"), "")
+	for _, err = r.Read(); err == nil; _, err = r.Read() {
+	}
+	var parseErr *format.ParseError
+	if err != io.EOF || len(r.Subtitle.Warnings) != 1 || !errors.As(r.Subtitle.Warnings[0], &parseErr) || parseErr.Line != 6 || parseErr.Cue != 2 {
+		t.Errorf("Expected an out of order warning at line 6, got %v (%v)", r.Subtitle.Warnings, err)
+	}
+
+	// Formats that can't be streamed are read and written whole
+	r = NewReader(strings.NewReader("{1}{1}25\n{25}{50}Hello|World\n"), "")
+	item, err := r.Read()
+	if err != nil || item.Text[1] != "World" || r.Subtitle.Format != "MicroDVD" {
+		t.Errorf("Expected a MicroDVD cue, got %v (%v)", item, err)
+	}
+	if _, err = r.Read(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+	buf.Reset()
+	w = NewWriter(&buf, "MPL2")
+	if err := w.Write(item); err != nil || buf.Len() != 0 {
+		t.Errorf("Expected no content before Close, got %q (%v)", buf.String(), err)
+	}
+	if err := w.Close(); err != nil || buf.String() != "[10][20]Hello|World\n" {
+		t.Errorf("Unexpected MPL2 content %q (%v)", buf.String(), err)
+	}
+	if err := NewWriter(&buf, "Unknown").Write(item); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat, got %v", err)
+	}
+}
+
+// TestShiftScale tests shifting and scaling the times of the cues, with and without selection
+func TestShiftScale(t *testing.T) {
+	sub := Subtitle{}
+	if err := sub.Load(strings.NewReader(testSRT), "SRT"); err != nil {
+		t.Fatalf("Failed to load SRT content: %v", err)
+	}
+
+	// Shift the cues 10 seconds later, then the second cue only
+	if dropped := sub.Shift(10 * time.Second); len(dropped) != 0 {
+		t.Errorf("Expected no dropped cues, got %d", len(dropped))
+	}
+	if sub.Lines[0].Start != 100*time.Second || sub.Lines[1].End != 165753*time.Millisecond {
+		t.Errorf("Unexpected shifted times: %v, %v", sub.Lines[0].Start, sub.Lines[1].End)
+	}
+	sub.ShiftSelection(-time.Second, Selection{First: 2})
+	if sub.Lines[0].Start != 100*time.Second || sub.Lines[1].Start != 159500*time.Millisecond {
+		t.Errorf("Unexpected shifted selection: %v, %v", sub.Lines[0].Start, sub.Lines[1].Start)
+	}
+
+	// Scale the cues twice as long around the start of the first cue
+	sub.Scale(2, 100*time.Second)
+	if sub.Lines[0].Start != 100*time.Second || sub.Lines[0].End != 110*time.Second || sub.Lines[1].Start != 219*time.Second {
+		t.Errorf("Unexpected scaled times: %v, %v, %v", sub.Lines[0].Start, sub.Lines[0].End, sub.Lines[1].Start)
+	}
+	sub.ScaleSelection(0.5, 0, Selection{From: 200 * time.Second})
+	if sub.Lines[0].End != 110*time.Second || sub.Lines[1].Start != 109500*time.Millisecond {
+		t.Errorf("Unexpected scaled selection: %v, %v", sub.Lines[0].End, sub.Lines[1].Start)
+	}
+
+	// Shift the first cue before zero, which is dropped, and the second partly before zero
+	dropped := sub.Shift(-110 * time.Second)
+	if len(dropped) != 1 || dropped[0].Seq != 1 || len(sub.Lines) != 1 {
+		t.Fatalf("Expected the first cue dropped, got %d dropped and %d left", len(dropped), len(sub.Lines))
+	}
+	if sub.Lines[0].Seq != 2 || sub.Lines[0].Start != 0 || sub.Lines[0].End != 4753*time.Millisecond {
+		t.Errorf("Unexpected clamped cue %d: %v --> %v", sub.Lines[0].Seq, sub.Lines[0].Start, sub.Lines[0].End)
+	}
+}
+
+// TestConvertFrameRate tests converting the times between frame rates and snapping them to frames
+func TestConvertFrameRate(t *testing.T) {
+	// Speed up a film from 23.976 fps to 25 fps, and back without drift
+	sub := Subtitle{}
+	sub.Lines = []models.ModelItemSubtitle{{Seq: 1, Start: 25 * time.Second, End: 2 * time.Hour, Text: []string{"Hello"}}}
+	if err := sub.ConvertFrameRate(23.976, 25); err != nil {
+		t.Fatalf("Failed to convert the frame rate: %v", err)
+	}
+	if sub.Lines[0].Start != 23976023976 || sub.FrameRate != 25 {
+		t.Errorf("Expected 23.976023976s at 25 fps, got %v at %v fps", sub.Lines[0].Start, sub.FrameRate)
+	}
+	if sub.Lines[0].End != 6905094905095 {
+		t.Errorf("Unexpected converted end time %v", sub.Lines[0].End)
+	}
+	if err := sub.ConvertFrameRate(25, 24000.0/1001); err != nil {
+		t.Fatalf("Failed to convert the frame rate: %v", err)
+	}
+	if sub.Lines[0].Start != 25*time.Second || sub.Lines[0].End != 2*time.Hour {
+		t.Errorf("Expected the original times, got %v --> %v", sub.Lines[0].Start, sub.Lines[0].End)
+	}
+	if err := sub.ConvertFrameRate(0, 25); !errors.Is(err, ErrInvalidFrameRate) {
+		t.Errorf("Expected ErrInvalidFrameRate, got %v", err)
+	}
+
+	// Snap to the frames of a 29.97 fps video, twice without change
+	sub.Lines[0].Start, sub.Lines[0].End = 1010*time.Millisecond, 2*time.Second
+	for _, rounding := range []models.FrameRounding{models.RoundDown, models.RoundUp, models.RoundNearest} {
+		if _, err := sub.SnapToFrames(29.97, rounding); err != nil {
+			t.Fatalf("Failed to snap to frames: %v", err)
+		}
+	}
+	if sub.Lines[0].Start != 1001*time.Millisecond || sub.Lines[0].End != 1968633333 {
+		t.Errorf("Expected frames 30 --> 59 at 1.001s --> 1.968633333s, got %v --> %v", sub.Lines[0].Start, sub.Lines[0].End)
+	}
+	sub.Lines[0].Start = 1010 * time.Millisecond
+	sub.SnapToFrames(29.97, models.RoundUp)
+	if sub.Lines[0].Start != 1034366667 {
+		t.Errorf("Expected frame 31 at 1.034366667s, got %v", sub.Lines[0].Start)
+	}
+}
+
+// TestSync tests retiming the cues from the correct start times of some of them
+func TestSync(t *testing.T) {
+	sub := Subtitle{}
+	for i := 0; i < 5; i++ {
+		start := time.Duration(10*(i+1)) * time.Second
+		sub.Lines = append(sub.Lines, models.ModelItemSubtitle{Seq: i + 1, Start: start, End: start + 2*time.Second, Text: []string{"Line"}})
+	}
+
+	// Fix an offset of 1s and a drift of 2x from the first and last cues, and the middle one
+	if _, err := sub.Sync(SyncPoint{Cue: 5, Time: 91 * time.Second}, SyncPoint{Cue: 1, Time: 11 * time.Second}); err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+	if sub.Lines[0].Start != 11*time.Second || sub.Lines[2].Start != 51*time.Second || sub.Lines[2].End != 55*time.Second || sub.Lines[4].Start != 91*time.Second {
+		t.Errorf("Unexpected synced times: %v, %v --> %v, %v", sub.Lines[0].Start, sub.Lines[2].Start, sub.Lines[2].End, sub.Lines[4].Start)
+	}
+	if _, err := sub.Sync(SyncPoint{Cue: 1, Time: 11 * time.Second}, SyncPoint{Cue: 3, Time: 41 * time.Second}, SyncPoint{Cue: 5, Time: 91 * time.Second}); err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+	if sub.Lines[1].Start != 26*time.Second || sub.Lines[3].Start != 66*time.Second {
+		t.Errorf("Unexpected synced times: %v, %v", sub.Lines[1].Start, sub.Lines[3].Start)
+	}
+
+	// A single sync point shifts every cue, dropping the cues ending before zero
+	dropped, err := sub.Sync(SyncPoint{Cue: 2, Time: 0})
+	if err != nil || len(dropped) != 1 || sub.Lines[0].Start != 0 || sub.Lines[0].Seq != 2 {
+		t.Errorf("Expected the first cue dropped, got %d dropped and %v (%v)", len(dropped), sub.Lines[0].Start, err)
+	}
+
+	// Invalid sync points
+	for _, points := range [][]SyncPoint{nil, {{Cue: 9}}, {{Cue: 1, Time: 9 * time.Second}, {Cue: 2, Time: time.Second}}} {
+		if _, err := sub.Sync(points...); !errors.Is(err, ErrInvalidSync) {
+			t.Errorf("Expected ErrInvalidSync for %v, got %v", points, err)
+		}
+	}
+}
+
+// TestAlign tests aligning a subtitle to a reference subtitle with other cuts and cues
+func TestAlign(t *testing.T) {
+	// The reference has an irregular rhythm, and the subtitle is 5s late, then 7s late after
+	// a cut, with a cue missing and a cue added
+	ref, sub := Subtitle{}, Subtitle{}
+	start := 10 * time.Second
+	for i := 0; i < 40; i++ {
+		start += time.Duration(1500+(i*7919)%4000) * time.Millisecond
+		item := models.ModelItemSubtitle{Seq: i + 1, Start: start, End: start + time.Duration(1000+(i*104729)%2000)*time.Millisecond, Text: []string{"Line"}}
+		ref.Lines = append(ref.Lines, item)
+		if i == 12 {
+			continue
+		}
+		offset := 5 * time.Second
+		if i >= 20 {
+			offset = 7 * time.Second
+		}
+		item.Start, item.End = item.Start+offset, item.End+offset
+		sub.Lines = append(sub.Lines, item)
+		if i == 30 {
+			sub.Lines = append(sub.Lines, models.ModelItemSubtitle{Start: item.End + 100*time.Millisecond, End: item.End + 600*time.Millisecond, Text: []string{"Extra"}})
+		}
+	}
+
+	alignment, dropped, err := sub.Align(&ref)
+	if err != nil {
+		t.Fatalf("Failed to align: %v", err)
+	}
+	if len(dropped) != 0 || alignment.Confidence < 0.9 || len(alignment.Segments) != 2 {
+		t.Fatalf("Unexpected alignment: %+v", alignment)
+	}
+	if s := alignment.Segments[0]; s.First != 1 || s.Last != 19 || s.Offset != -5*time.Second {
+		t.Errorf("Unexpected first segment: %+v", s)
+	}
+	if s := alignment.Segments[1]; s.First != 20 || s.Last != 40 || s.Offset != -7*time.Second {
+		t.Errorf("Unexpected second segment: %+v", s)
+	}
+	if sub.Lines[0].Start != ref.Lines[0].Start || sub.Lines[39].End != ref.Lines[39].End {
+		t.Errorf("Expected the reference times, got %v and %v", sub.Lines[0].Start, sub.Lines[39].End)
+	}
+
+	// Subtitles without cues can't be aligned
+	if _, err := sub.FindAlignment(&Subtitle{}); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Expected ErrEmpty, got %v", err)
+	}
+
+	// Large subtitles are aligned within a band of the reference cues, the cue 100 missing
+	ref, sub = Subtitle{}, Subtitle{}
+	start = 0
+	for i := 0; i < 10000; i++ {
+		start += time.Duration(1500+(i*7919)%4000) * time.Millisecond
+		item := models.ModelItemSubtitle{Seq: i + 1, Start: start, End: start + time.Duration(1000+(i*104729)%2000)*time.Millisecond, Text: []string{"Line"}}
+		ref.Lines = append(ref.Lines, item)
+		if i != 100 {
+			item.Start, item.End = item.Start+3*time.Second, item.End+3*time.Second
+			sub.Lines = append(sub.Lines, item)
+		}
+	}
+	alignment, err = sub.FindAlignment(&ref)
+	if err != nil || alignment.Confidence < 0.99 || len(alignment.Segments) != 1 || alignment.Segments[0].Offset != -3*time.Second {
+		t.Errorf("Unexpected alignment of large subtitles: %+v (%v)", alignment, err)
+	}
+
+	// Too many cues can't be aligned
+	sub.Lines = make([]models.ModelItemSubtitle, alignMaxCells/(2*alignBand+1)+1)
+	if _, err := sub.FindAlignment(&ref); !errors.Is(err, ErrNoAlignment) {
+		t.Errorf("Expected ErrNoAlignment, got %v", err)
+	}
+}
+
+// writeTestWAV writes a 16-bit mono WAV file of faint noise with a tone in the speech regions.
+func writeTestWAV(t *testing.T, filename string, duration time.Duration, speech []SpeechRegion) {
+	const sampleRate = 8000
+	samples := int(duration.Seconds() * sampleRate)
+	data := make([]byte, 2*samples)
+	noise := uint32(1)
+	for i := 0; i < samples; i++ {
+		noise = noise*1664525 + 1013904223
+		v := float64(int32(noise)>>16) / (1 << 15) * 0.002
+		at := time.Duration(i) * time.Second / sampleRate
+		for _, s := range speech {
+			if at >= s.Start && at < s.End {
+				v += 0.3 * math.Sin(2*math.Pi*300*float64(i)/sampleRate)
+			}
+		}
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(int16(v*(1<<15))))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+len(data)))
+	buf.WriteString("WAVEfmt ")
+	for _, field := range []interface{}{uint32(16), uint16(1), uint16(1), uint32(sampleRate), uint32(2 * sampleRate), uint16(2), uint16(16)} {
+		binary.Write(&buf, binary.LittleEndian, field)
+	}
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
+	if err := os.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write the WAV file: %v", err)
+	}
+}
+
+// TestSyncAudio tests detecting the speech of a WAV file and syncing a subtitle to it
+func TestSyncAudio(t *testing.T) {
+	// Cues with an irregular rhythm, spoken 2.5s later in the audio
+	sub := Subtitle{}
+	var speech []SpeechRegion
+	start := time.Second
+	for i := 0; i < 30; i++ {
+		end := start + time.Duration(80+(i*7919)%200)*10*time.Millisecond
+		sub.Lines = append(sub.Lines, models.ModelItemSubtitle{Seq: i + 1, Start: start, End: end, Text: []string{"Line"}})
+		speech = append(speech, SpeechRegion{Start: start + 2500*time.Millisecond, End: end + 2500*time.Millisecond})
+		start = end + time.Duration(40+(i*104729)%150)*10*time.Millisecond
+	}
+	filename := filepath.Join(t.TempDir(), "speech.wav")
+	writeTestWAV(t, filename, start+5*time.Second, speech)
+
+	// The speech regions are detected
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	regions, err := DetectSpeech(f)
+	f.Close()
+	if err != nil || len(regions) != len(speech) || regions[3] != speech[3] {
+		t.Fatalf("Expected %d speech regions like %v, got %d like %v (%v)", len(speech), speech[3], len(regions), regions, err)
+	}
+
+	// The cues are moved to the speech
+	sync, dropped, err := sub.SyncAudio(filename, AudioSyncOptions{})
+	if err != nil || len(dropped) != 0 {
+		t.Fatalf("Failed to sync to the audio: %v", err)
+	}
+	if sync.Offset != 2500*time.Millisecond || sync.Scale != 1 || sync.Coverage < 0.95 {
+		t.Errorf("Unexpected audio sync: %+v", sync)
+	}
+	if sub.Lines[10].Start != speech[10].Start || sub.Lines[10].End != speech[10].End {
+		t.Errorf("Expected %v --> %v, got %v --> %v", speech[10].Start, speech[10].End, sub.Lines[10].Start, sub.Lines[10].End)
+	}
+
+	// The drift of a subtitle made for a 25 fps video, played at 23.976 fps, is found
+	sub.Shift(-2500 * time.Millisecond)
+	sub.Scale(23.976/25, 0)
+	sync, err = sub.FindAudioSync(filename, AudioSyncOptions{MaxOffset: 5 * time.Second, MaxDrift: 0.05})
+	if err != nil || sync.Offset != 2500*time.Millisecond || math.Abs(sync.Scale*24000/1001/25-1) > 1e-6 {
+		t.Errorf("Unexpected audio sync with drift: %+v (%v)", sync, err)
+	}
+
+	// Other files are not audio, and a format chunk larger than the file is truncated
+	if _, err := DetectSpeech(strings.NewReader(testSRT)); !errors.Is(err, ErrUnsupportedAudio) {
+		t.Errorf("Expected ErrUnsupportedAudio, got %v", err)
+	}
+	if _, err := DetectSpeech(strings.NewReader("RIFF\x20\x00\x00\x00WAVEfmt \xff\xff\xff\xff\x01\x00\x01\x00\x40\x1f\x00\x00\x80\x3e\x00\x00\x02\x00\x10\x00")); !errors.Is(err, ErrUnsupportedAudio) {
+		t.Errorf("Expected ErrUnsupportedAudio for a truncated format chunk, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package subtitles
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+)
+
+// SyncPoint is a cue of a subtitle with the time at which it should start.
+type SyncPoint struct {
+	Cue  int           // Index of the cue in Lines, starting at 1
+	Time time.Duration // Correct start time of the cue
+}
+
+// Sync retimes every cue from the correct start times of some of them, so a subtitle that is
+// both offset and drifting is fixed by correcting its first and last cues. Between two sync
+// points, the times are moved and stretched linearly so the cues of the points start at
+// their correct times; before the first point and after the last one, the times follow the
+// first and last stretches. A single sync point shifts every cue like Shift.
+// The times before zero are clamped to zero, and the cues ending at zero or before are
+// removed from Lines and returned.
+// Returns an error matching ErrInvalidSync if there is no sync point, a cue index is out of
+// range, or the sync points don't keep the order of their cues.
+func (sub *Subtitle) Sync(points ...SyncPoint) (dropped []models.ModelItemSubtitle, err error) {
+	if len(points) == 0 {
+		return nil, fmt.Errorf("%w: no sync point", ErrInvalidSync)
+	}
+
+	// Map the current start times of the cues of the points to their correct times
+	type anchor struct{ from, to time.Duration }
+	anchors := make([]anchor, len(points))
+	for i, p := range points {
+		if p.Cue < 1 || p.Cue > len(sub.Lines) {
+			return nil, fmt.Errorf("%w: cue %d out of range 1-%d", ErrInvalidSync, p.Cue, len(sub.Lines))
+		}
+		anchors[i] = anchor{from: sub.Lines[p.Cue-1].Start, to: p.Time}
+	}
+	sort.SliceStable(anchors, func(i, j int) bool {
+		return anchors[i].from < anchors[j].from
+	})
+	for i := 1; i < len(anchors); i++ {
+		if anchors[i].from == anchors[i-1].from {
+			return nil, fmt.Errorf("%w: two sync points for cues starting at %v", ErrInvalidSync, anchors[i].from)
+		}
+		if anchors[i].to <= anchors[i-1].to {
+			return nil, fmt.Errorf("%w: time %v not after %v of the previous cue", ErrInvalidSync, anchors[i].to, anchors[i-1].to)
+		}
+	}
+
+	// A single sync point shifts every cue
+	if len(anchors) == 1 {
+		return sub.Shift(anchors[0].to - anchors[0].from), nil
+	}
+
+	return sub.retime(Selection{}, func(t time.Duration) time.Duration {
+		// Find the stretch of the time: between the anchors k and k+1
+		k := sort.Search(len(anchors), func(i int) bool {
+			return anchors[i].from > t
+		}) - 1
+		if k < 0 {
+			k = 0
+		} else if k > len(anchors)-2 {
+			k = len(anchors) - 2
+		}
+		a, b := anchors[k], anchors[k+1]
+		scale := float64(b.to-a.to) / float64(b.from-a.from)
+		return a.to + time.Duration(math.Round(float64(t-a.from)*scale))
+	}), nil
+}
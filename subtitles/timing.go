@@ -0,0 +1,100 @@
+package subtitles
+
+import (
+	"math"
+	"time"
+
+	"github.com/jonathanhecl/subtitle-processor/subtitles/models"
+)
+
+// Selection selects the cues of a subtitle by their start time and their index.
+// The zero Selection selects every cue.
+type Selection struct {
+	From  time.Duration // Start time of the first cue selected (inclusive)
+	To    time.Duration // Start time after the last cue selected (exclusive), no limit if zero
+	First int           // Index of the first cue selected, starting at 1 (from the first cue if zero)
+	Last  int           // Index of the last cue selected (inclusive), to the last cue if zero
+}
+
+// selects reports whether the cue with the given index, starting at 0, is selected.
+func (s Selection) selects(index int, item *models.ModelItemSubtitle) bool {
+	if index+1 < s.First || (s.Last > 0 && index+1 > s.Last) {
+		return false
+	}
+	return item.Start >= s.From && (s.To <= 0 || item.Start < s.To)
+}
+
+// Shift moves every cue by d, which is negative to show the cues sooner.
+// The times before zero are clamped to zero, and the cues ending at zero or before are
+// removed from Lines and returned. The sequence numbers are unchanged.
+func (sub *Subtitle) Shift(d time.Duration) (dropped []models.ModelItemSubtitle) {
+	return sub.ShiftSelection(d, Selection{})
+}
+
+// ShiftSelection moves the selected cues by d like Shift.
+func (sub *Subtitle) ShiftSelection(d time.Duration, sel Selection) (dropped []models.ModelItemSubtitle) {
+	return sub.retime(sel, func(t time.Duration) time.Duration {
+		return t + d
+	})
+}
+
+// Scale stretches the times of every cue by factor around the anchor time, which keeps its
+// place: a time t becomes anchor + (t - anchor) * factor (e.g., 25/23.976 converts the times
+// of a subtitle made for a 23.976 fps video to a 25 fps one, with the anchor at zero).
+// The times before zero are clamped to zero, and the cues ending at zero or before are
+// removed from Lines and returned. The sequence numbers are unchanged.
+// The subtitle is unchanged if the factor is not positive.
+func (sub *Subtitle) Scale(factor float64, anchor time.Duration) (dropped []models.ModelItemSubtitle) {
+	return sub.ScaleSelection(factor, anchor, Selection{})
+}
+
+// ScaleSelection stretches the times of the selected cues by factor around the anchor time
+// like Scale.
+func (sub *Subtitle) ScaleSelection(factor float64, anchor time.Duration, sel Selection) (dropped []models.ModelItemSubtitle) {
+	if factor <= 0 {
+		return nil
+	}
+	return sub.retime(sel, func(t time.Duration) time.Duration {
+		return anchor + time.Duration(math.Round(float64(t-anchor)*factor))
+	})
+}
+
+// retime converts the times of the selected cues and their words with convert, clamping the
+// times before zero. The cues ending at zero or before are removed and returned.
+func (sub *Subtitle) retime(sel Selection, convert func(t time.Duration) time.Duration) (dropped []models.ModelItemSubtitle) {
+	// clamp converts a time, clamping it to zero
+	clamp := func(t time.Duration) time.Duration {
+		if t = convert(t); t < 0 {
+			return 0
+		}
+		return t
+	}
+
+	lines := sub.Lines[:0]
+	for i := range sub.Lines {
+		item := sub.Lines[i]
+		if !sel.selects(i, &item) {
+			lines = append(lines, item)
+			continue
+		}
+
+		item.Start, item.End = clamp(item.Start), clamp(item.End)
+		if len(item.Words) > 0 {
+			words := make([]models.ModelWord, len(item.Words))
+			for j, word := range item.Words {
+				word.Start, word.End = clamp(word.Start), clamp(word.End)
+				words[j] = word
+			}
+			item.Words = words
+		}
+
+		// Drop the cues that are no longer shown
+		if item.End <= 0 {
+			dropped = append(dropped, sub.Lines[i])
+			continue
+		}
+		lines = append(lines, item)
+	}
+	sub.Lines = lines
+	return dropped
+}